@@ -0,0 +1,156 @@
+package audit
+
+import (
+	"betest/internal/database"
+	"betest/internal/middleware"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net"
+	"net/http"
+	"reflect"
+)
+
+// Daftar action yang dicatat di audit_log
+const (
+	ActionUserCreate = "user.create"
+	ActionUserUpdate = "user.update"
+	ActionUserDelete = "user.delete"
+	ActionUserImport = "user.import"
+
+	ActionWebhookCreate = "webhook.create"
+	ActionWebhookUpdate = "webhook.update"
+	ActionWebhookDelete = "webhook.delete"
+
+	ActionOAuthClientCreate = "oauth_client.create"
+	ActionOAuthClientDelete = "oauth_client.delete"
+	ActionOAuthAuthorize    = "oauth.authorize"
+
+	ActionTokenRevoke = "token.revoke"
+
+	ActionRegister      = "auth.register"
+	ActionLogin         = "auth.login"
+	ActionLoginFailed   = "auth.login_failed"
+	ActionLogout        = "auth.logout"
+	ActionRefresh       = "auth.refresh"
+	ActionRefreshReused = "auth.refresh_reuse_detected"
+)
+
+const (
+	ResourceUser        = "user"
+	ResourceWebhook     = "webhook"
+	ResourceOAuthClient = "oauth_client"
+	ResourceToken       = "token"
+)
+
+// Entry adalah satu kejadian yang akan dicatat.
+// Jika ActorID kosong, actor diambil dari user_id di context request (hasil JWTMiddleware).
+type Entry struct {
+	ActorID      *int
+	Action       string
+	ResourceType string
+	ResourceID   string
+	Before       interface{}
+	After        interface{}
+}
+
+// Change adalah perubahan satu field antara Before dan After
+type Change struct {
+	From interface{} `json:"from"`
+	To   interface{} `json:"to"`
+}
+
+// Record menulis entry ke tabel audit_log beserta IP, user agent dan request ID dari request.
+// Kegagalan menulis audit hanya di-log supaya tidak menggagalkan request utamanya.
+func Record(r *http.Request, e Entry) {
+	actorID := e.ActorID
+	if actorID == nil {
+		if userID, ok := middleware.UserID(r.Context()); ok {
+			actorID = &userID
+		}
+	}
+
+	before := toMap(e.Before)
+	after := toMap(e.After)
+
+	// WithoutCancel: audit tetap ditulis walaupun client sudah memutus koneksi,
+	// tapi tetap dibatasi QueryTimeout supaya tidak menggantung
+	ctx, cancel := database.WithTimeout(context.WithoutCancel(r.Context()))
+	defer cancel()
+
+	_, err := database.DB.ExecContext(ctx,
+		`INSERT INTO audit_log (actor_id, action, resource_type, resource_id, before, after, changes, ip, user_agent, request_id)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)`,
+		actorID, e.Action, e.ResourceType, nullString(e.ResourceID),
+		jsonOrNull(before), jsonOrNull(after), jsonOrNull(diff(before, after)),
+		clientIP(r), r.UserAgent(), nullString(middleware.RequestID(r.Context())))
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Error writing audit log", "action", e.Action, "error", err)
+	}
+}
+
+// diff mengembalikan field yang berubah antara before dan after.
+// Field yang hanya ada di salah satu sisi juga dianggap berubah.
+func diff(before, after map[string]interface{}) map[string]Change {
+	if before == nil || after == nil {
+		return nil
+	}
+
+	changes := make(map[string]Change)
+	for k, b := range before {
+		if a, ok := after[k]; !ok || !reflect.DeepEqual(a, b) {
+			changes[k] = Change{From: b, To: after[k]}
+		}
+	}
+	for k, a := range after {
+		if _, ok := before[k]; !ok {
+			changes[k] = Change{From: nil, To: a}
+		}
+	}
+	if len(changes) == 0 {
+		return nil
+	}
+	return changes
+}
+
+// toMap mengubah struct menjadi map lewat JSON supaya tag `json:"-"` (misalnya password) ikut diabaikan
+func toMap(v interface{}) map[string]interface{} {
+	if v == nil {
+		return nil
+	}
+	b, err := json.Marshal(v)
+	if err != nil {
+		return nil
+	}
+	var m map[string]interface{}
+	if err := json.Unmarshal(b, &m); err != nil {
+		return nil
+	}
+	return m
+}
+
+func jsonOrNull(v interface{}) interface{} {
+	if v == nil || reflect.ValueOf(v).IsNil() {
+		return nil
+	}
+	b, err := json.Marshal(v)
+	if err != nil {
+		return nil
+	}
+	return string(b)
+}
+
+func nullString(s string) interface{} {
+	if s == "" {
+		return nil
+	}
+	return s
+}
+
+func clientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
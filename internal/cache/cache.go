@@ -0,0 +1,229 @@
+package cache
+
+import (
+	"betest/internal/metrics"
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"math"
+	"math/rand/v2"
+	"time"
+
+	"golang.org/x/sync/singleflight"
+)
+
+// ErrMiss dikembalikan Store.Get jika key tidak ada atau sudah expired
+var ErrMiss = errors.New("cache: miss")
+
+// Store adalah backend penyimpanan cache (Redis atau in-memory LRU)
+type Store interface {
+	Get(ctx context.Context, key string) ([]byte, error)
+	Set(ctx context.Context, key string, value []byte, ttl time.Duration) error
+	Delete(ctx context.Context, keys ...string) error
+	Incr(ctx context.Context, key string, ttl time.Duration) (int64, error) // ttl 0 berarti tanpa expiry
+}
+
+// entry adalah nilai yang disimpan di Store. Delta (lama load terakhir) dan Expiry dipakai
+// untuk probabilistic early expiration (XFetch): semakin dekat ke expiry dan semakin mahal
+// load-nya, semakin besar peluang satu request me-refresh lebih awal sebelum key benar-benar
+// expired, sehingga tidak semua request miss bersamaan.
+type entry struct {
+	Value  json.RawMessage `json:"v"`
+	Delta  int64           `json:"d"` // nanodetik
+	Expiry int64           `json:"e"` // unix milidetik
+}
+
+// Cache adalah cache read-through untuk satu jenis data. Load untuk key yang sama
+// di-dedup dengan singleflight supaya cache miss tidak membanjiri database (stampede).
+type Cache struct {
+	name  string
+	store Store
+	ttl   time.Duration
+	beta  float64
+	group singleflight.Group
+	now   func() time.Time
+}
+
+// New membuat cache dengan nama (dipakai sebagai prefix key dan label metric) dan TTL.
+// beta mengatur agresivitas early refresh XFetch (1 = default, 0 = nonaktif).
+// Jika store nil cache dinonaktifkan dan setiap Fetch langsung memanggil load.
+func New(store Store, name string, ttl time.Duration, beta float64) *Cache {
+	return &Cache{name: name, store: store, ttl: ttl, beta: beta, now: time.Now}
+}
+
+func (c *Cache) enabled() bool {
+	return c != nil && c.store != nil && c.ttl > 0
+}
+
+func (c *Cache) key(key string) string {
+	return "cache:" + c.name + ":" + key
+}
+
+// Fetch mengembalikan nilai key dari cache, atau memanggil load lalu menyimpan hasilnya.
+// Error dari load tidak di-cache. Jika backend cache bermasalah request tetap dilayani dari load.
+func Fetch[T any](ctx context.Context, c *Cache, key string, load func(ctx context.Context) (T, error)) (T, error) {
+	if !c.enabled() || key == "" {
+		return load(ctx)
+	}
+
+	fullKey := c.key(key)
+	if v, ok := get[T](ctx, c, fullKey); ok {
+		return v, nil
+	}
+
+	// Context load dilepas dari request supaya pembatalan oleh satu client tidak
+	// menggagalkan request lain yang menunggu hasil singleflight yang sama
+	v, err, _ := c.group.Do(fullKey, func() (interface{}, error) {
+		// Versi key dicatat sebelum load, supaya hasil load yang balapan dengan Delete tidak disimpan
+		version, ok := c.version(ctx, key)
+		start := c.now()
+		v, err := load(context.WithoutCancel(ctx))
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			c.setIfUnchanged(ctx, key, version, v, c.now().Sub(start))
+		}
+		return v, nil
+	})
+	if err != nil {
+		var zero T
+		return zero, err
+	}
+	return v.(T), nil
+}
+
+func get[T any](ctx context.Context, c *Cache, fullKey string) (T, bool) {
+	var v T
+
+	raw, err := c.store.Get(ctx, fullKey)
+	if errors.Is(err, ErrMiss) {
+		c.observe(metrics.ResultMiss)
+		return v, false
+	}
+	if err != nil {
+		slog.WarnContext(ctx, "Cache get failed", "cache", c.name, "error", err)
+		c.observe(metrics.ResultError)
+		return v, false
+	}
+
+	var e entry
+	if err := json.Unmarshal(raw, &e); err != nil || json.Unmarshal(e.Value, &v) != nil {
+		c.observe(metrics.ResultMiss)
+		return v, false
+	}
+	if c.refreshEarly(e) {
+		c.observe(metrics.ResultEarlyRefresh)
+		return v, false
+	}
+
+	c.observe(metrics.ResultHit)
+	return v, true
+}
+
+// refreshEarly mengimplementasikan XFetch: refresh jika now - delta * beta * ln(rand) >= expiry
+func (c *Cache) refreshEarly(e entry) bool {
+	if c.beta <= 0 || e.Delta <= 0 {
+		return false
+	}
+	gap := float64(e.Delta) * c.beta * -math.Log(1-rand.Float64())
+	return c.now().Add(time.Duration(gap)).UnixMilli() >= e.Expiry
+}
+
+// version membaca versi per key yang dinaikkan setiap Delete. ok false jika versi tidak bisa dibaca.
+func (c *Cache) version(ctx context.Context, key string) (string, bool) {
+	raw, err := c.store.Get(ctx, c.key("version:"+key))
+	if errors.Is(err, ErrMiss) {
+		return "0", true
+	}
+	if err != nil {
+		slog.WarnContext(ctx, "Cache version lookup failed", "cache", c.name, "error", err)
+		return "", false
+	}
+	return string(raw), true
+}
+
+// setIfUnchanged menyimpan hasil load hanya jika key tidak di-Delete sejak load dimulai. Versi
+// dicek lagi setelah set: Delete menaikkan versi sebelum menghapus key, jadi nilai lama yang
+// tersimpan di sela-selanya selalu terhapus, oleh Delete itu sendiri atau di sini.
+func (c *Cache) setIfUnchanged(ctx context.Context, key, version string, v interface{}, delta time.Duration) {
+	if current, ok := c.version(ctx, key); !ok || current != version {
+		return
+	}
+	fullKey := c.key(key)
+	c.set(ctx, fullKey, v, delta)
+	if current, ok := c.version(ctx, key); !ok || current != version {
+		if err := c.store.Delete(ctx, fullKey); err != nil {
+			slog.WarnContext(ctx, "Cache invalidation failed", "cache", c.name, "error", err)
+		}
+	}
+}
+
+func (c *Cache) set(ctx context.Context, fullKey string, v interface{}, delta time.Duration) {
+	value, err := json.Marshal(v)
+	if err != nil {
+		slog.WarnContext(ctx, "Cache encode failed", "cache", c.name, "error", err)
+		return
+	}
+	raw, _ := json.Marshal(entry{
+		Value:  value,
+		Delta:  int64(delta),
+		Expiry: c.now().Add(c.ttl).UnixMilli(),
+	})
+	if err := c.store.Set(ctx, fullKey, raw, c.ttl); err != nil {
+		slog.WarnContext(ctx, "Cache set failed", "cache", c.name, "error", err)
+	}
+}
+
+// Delete menghapus key dari cache, dipanggil setelah data berubah. Versi key dinaikkan lebih dulu
+// supaya load yang sedang berjalan (dan mungkin membaca data sebelum perubahan) tidak menyimpan hasilnya.
+func (c *Cache) Delete(ctx context.Context, keys ...string) {
+	if !c.enabled() || len(keys) == 0 {
+		return
+	}
+	fullKeys := make([]string, len(keys))
+	for i, k := range keys {
+		fullKeys[i] = c.key(k)
+		// Versi cukup hidup selama TTL, load yang lebih lama dari itu dianggap usang
+		if _, err := c.store.Incr(ctx, c.key("version:"+k), c.ttl); err != nil {
+			slog.WarnContext(ctx, "Cache version bump failed", "cache", c.name, "error", err)
+		}
+	}
+	if err := c.store.Delete(ctx, fullKeys...); err != nil {
+		slog.WarnContext(ctx, "Cache invalidation failed", "cache", c.name, "error", err)
+	}
+}
+
+// Versioned menambahkan versi cache saat ini ke key. Dipakai untuk data yang tidak bisa
+// dihapus satu per satu (misalnya halaman list): cukup Bump dan semua key versi lama
+// tidak terpakai lagi sampai expired sendiri. Mengembalikan "" jika versi tidak bisa dibaca,
+// sehingga Fetch melewati cache.
+func (c *Cache) Versioned(ctx context.Context, key string) string {
+	if !c.enabled() {
+		return ""
+	}
+	raw, err := c.store.Get(ctx, c.key("version"))
+	if errors.Is(err, ErrMiss) {
+		return "v0:" + key
+	}
+	if err != nil {
+		slog.WarnContext(ctx, "Cache version lookup failed", "cache", c.name, "error", err)
+		return ""
+	}
+	return "v" + string(raw) + ":" + key
+}
+
+// Bump menaikkan versi cache sehingga semua key dari Versioned menjadi usang
+func (c *Cache) Bump(ctx context.Context) {
+	if !c.enabled() {
+		return
+	}
+	if _, err := c.store.Incr(ctx, c.key("version"), 0); err != nil {
+		slog.WarnContext(ctx, "Cache version bump failed", "cache", c.name, "error", err)
+	}
+}
+
+func (c *Cache) observe(result string) {
+	metrics.CacheRequestsTotal.WithLabelValues(c.name, result).Inc()
+}
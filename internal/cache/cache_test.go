@@ -0,0 +1,125 @@
+package cache
+
+import (
+	"betest/internal/config"
+	"context"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/go-redis/redis/v8"
+)
+
+// testStores mengembalikan kedua backend supaya perilaku invalidation diuji sama persis
+func testStores(t *testing.T) map[string]Store {
+	t.Helper()
+	mr := miniredis.RunT(t)
+	rdb := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	t.Cleanup(func() { rdb.Close() })
+	return map[string]Store{
+		"memory": NewMemoryStore(100),
+		"redis":  NewRedisStore(rdb),
+	}
+}
+
+func TestFetchCachesLoad(t *testing.T) {
+	for name, store := range testStores(t) {
+		t.Run(name, func(t *testing.T) {
+			c := New(store, "user", time.Minute, 0)
+			ctx := context.Background()
+			loads := 0
+			load := func(context.Context) (string, error) {
+				loads++
+				return "alice", nil
+			}
+
+			for range 3 {
+				if v, err := Fetch(ctx, c, "1", load); err != nil || v != "alice" {
+					t.Fatalf("Fetch = %q, %v", v, err)
+				}
+			}
+			if loads != 1 {
+				t.Errorf("loads = %d; want 1", loads)
+			}
+
+			c.Delete(ctx, "1")
+			Fetch(ctx, c, "1", load)
+			if loads != 2 {
+				t.Errorf("loads after Delete = %d; want 2", loads)
+			}
+		})
+	}
+}
+
+func TestFetchInvalidateDuringLoad(t *testing.T) {
+	for name, store := range testStores(t) {
+		t.Run(name, func(t *testing.T) {
+			c := New(store, "user", time.Minute, 0)
+			ctx := context.Background()
+
+			// Load membaca data lama, lalu data berubah dan di-invalidate sebelum load selesai
+			started := make(chan struct{})
+			release := make(chan struct{})
+			done := make(chan string)
+			go func() {
+				v, _ := Fetch(ctx, c, "1", func(context.Context) (string, error) {
+					close(started)
+					<-release
+					return "stale", nil
+				})
+				done <- v
+			}()
+			<-started
+			c.Delete(ctx, "1")
+			close(release)
+
+			// Request yang sedang berjalan tetap menerima hasil load-nya
+			if v := <-done; v != "stale" {
+				t.Errorf("in-flight Fetch = %q; want stale", v)
+			}
+
+			v, err := Fetch(ctx, c, "1", func(context.Context) (string, error) {
+				return "fresh", nil
+			})
+			if err != nil || v != "fresh" {
+				t.Errorf("Fetch after invalidation = %q, %v; want fresh (stale value must not be cached)", v, err)
+			}
+		})
+	}
+}
+
+func TestVersionedBump(t *testing.T) {
+	c := New(NewMemoryStore(100), "user_list", time.Minute, 0)
+	ctx := context.Background()
+
+	before := c.Versioned(ctx, "page:1")
+	c.Bump(ctx)
+	if after := c.Versioned(ctx, "page:1"); after == before || after == "" {
+		t.Errorf("Versioned after Bump = %q; want a key different from %q", after, before)
+	}
+}
+
+func TestStoreFromConfig(t *testing.T) {
+	mr := miniredis.RunT(t)
+	rdb := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	t.Cleanup(func() { rdb.Close() })
+	ctx := context.Background()
+
+	store, err := StoreFromConfig(ctx, config.CacheConfig{Backend: "redis", MemorySize: 10}, rdb)
+	if _, ok := store.(*RedisStore); !ok || err != nil {
+		t.Errorf("redis backend = %T, %v; want *RedisStore", store, err)
+	}
+	if store, err := StoreFromConfig(ctx, config.CacheConfig{Backend: "none"}, rdb); store != nil || err != nil {
+		t.Errorf("none backend = %T, %v; want nil", store, err)
+	}
+	if _, err := StoreFromConfig(ctx, config.CacheConfig{Backend: "memcached"}, rdb); err == nil {
+		t.Error("unknown backend succeeded; want error")
+	}
+
+	// Redis yang tidak bisa di-ping saat startup tidak boleh mematikan cache
+	mr.Close()
+	store, err = StoreFromConfig(ctx, config.CacheConfig{Backend: "redis", MemorySize: 10}, rdb)
+	if _, ok := store.(*MemoryStore); !ok || err != nil {
+		t.Errorf("redis backend while Redis is down = %T, %v; want *MemoryStore", store, err)
+	}
+}
@@ -0,0 +1,129 @@
+package cache
+
+import (
+	"container/list"
+	"context"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// MemoryStore adalah cache LRU in-memory per proses, dipakai jika Redis tidak tersedia.
+// Invalidation hanya berlaku di instance yang sama, jadi dengan banyak instance
+// data bisa usang sampai TTL habis.
+type MemoryStore struct {
+	mu       sync.Mutex
+	capacity int
+	items    map[string]*list.Element
+	lru      *list.List               // Depan = paling baru dipakai
+	counters map[string]memoryCounter // Counter versi disimpan terpisah supaya tidak ikut di-evict
+	now      func() time.Time
+}
+
+type memoryCounter struct {
+	n         int64
+	expiresAt time.Time // Zero berarti tidak expired
+}
+
+type memoryItem struct {
+	key       string
+	value     []byte
+	expiresAt time.Time // Zero berarti tidak expired
+}
+
+func NewMemoryStore(capacity int) *MemoryStore {
+	return &MemoryStore{
+		capacity: capacity,
+		items:    make(map[string]*list.Element),
+		lru:      list.New(),
+		counters: make(map[string]memoryCounter),
+		now:      time.Now,
+	}
+}
+
+func (s *MemoryStore) Get(_ context.Context, key string) ([]byte, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if c, ok := s.counters[key]; ok {
+		if c.expiresAt.IsZero() || s.now().Before(c.expiresAt) {
+			return []byte(strconv.FormatInt(c.n, 10)), nil
+		}
+		delete(s.counters, key)
+		return nil, ErrMiss
+	}
+
+	el, ok := s.items[key]
+	if !ok {
+		return nil, ErrMiss
+	}
+	item := el.Value.(*memoryItem)
+	if !item.expiresAt.IsZero() && !s.now().Before(item.expiresAt) {
+		s.remove(el)
+		return nil, ErrMiss
+	}
+	s.lru.MoveToFront(el)
+	return item.value, nil
+}
+
+func (s *MemoryStore) Set(_ context.Context, key string, value []byte, ttl time.Duration) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var expiresAt time.Time
+	if ttl > 0 {
+		expiresAt = s.now().Add(ttl)
+	}
+	s.put(key, value, expiresAt)
+	return nil
+}
+
+func (s *MemoryStore) Delete(_ context.Context, keys ...string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, key := range keys {
+		delete(s.counters, key)
+		if el, ok := s.items[key]; ok {
+			s.remove(el)
+		}
+	}
+	return nil
+}
+
+func (s *MemoryStore) Incr(_ context.Context, key string, ttl time.Duration) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	c := s.counters[key]
+	if !c.expiresAt.IsZero() && !s.now().Before(c.expiresAt) {
+		c = memoryCounter{}
+	}
+	c.n++
+	c.expiresAt = time.Time{}
+	if ttl > 0 {
+		c.expiresAt = s.now().Add(ttl)
+	}
+	s.counters[key] = c
+	return c.n, nil
+}
+
+func (s *MemoryStore) put(key string, value []byte, expiresAt time.Time) {
+	if el, ok := s.items[key]; ok {
+		item := el.Value.(*memoryItem)
+		item.value = value
+		item.expiresAt = expiresAt
+		s.lru.MoveToFront(el)
+		return
+	}
+
+	s.items[key] = s.lru.PushFront(&memoryItem{key: key, value: value, expiresAt: expiresAt})
+	for s.capacity > 0 && s.lru.Len() > s.capacity {
+		s.remove(s.lru.Back())
+	}
+}
+
+func (s *MemoryStore) remove(el *list.Element) {
+	s.lru.Remove(el)
+	delete(s.items, el.Value.(*memoryItem).key)
+}
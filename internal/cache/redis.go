@@ -0,0 +1,57 @@
+package cache
+
+import (
+	"betest/internal/redisclient"
+	"context"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+// RedisStore menyimpan cache di Redis sehingga dipakai bersama oleh semua instance.
+// Semua key diberi prefix REDIS_KEY_PREFIX.
+type RedisStore struct {
+	rdb redis.Cmdable
+}
+
+func NewRedisStore(rdb redis.Cmdable) *RedisStore {
+	return &RedisStore{rdb: rdb}
+}
+
+func (s *RedisStore) Get(ctx context.Context, key string) ([]byte, error) {
+	b, err := s.rdb.Get(ctx, redisclient.Key(key)).Bytes()
+	if err == redis.Nil {
+		return nil, ErrMiss
+	}
+	return b, err
+}
+
+func (s *RedisStore) Set(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+	return s.rdb.Set(ctx, redisclient.Key(key), value, ttl).Err()
+}
+
+func (s *RedisStore) Delete(ctx context.Context, keys ...string) error {
+	prefixed := make([]string, len(keys))
+	for i, k := range keys {
+		prefixed[i] = redisclient.Key(k)
+	}
+	return s.rdb.Del(ctx, prefixed...).Err()
+}
+
+func (s *RedisStore) Incr(ctx context.Context, key string, ttl time.Duration) (int64, error) {
+	key = redisclient.Key(key)
+	if ttl <= 0 {
+		return s.rdb.Incr(ctx, key).Result()
+	}
+
+	var incr *redis.IntCmd
+	_, err := s.rdb.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		incr = pipe.Incr(ctx, key)
+		pipe.Expire(ctx, key, ttl)
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	return incr.Val(), nil
+}
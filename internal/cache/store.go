@@ -0,0 +1,30 @@
+package cache
+
+import (
+	"betest/internal/config"
+	"context"
+	"fmt"
+	"log/slog"
+
+	"github.com/go-redis/redis/v8"
+)
+
+// StoreFromConfig membuat backend cache sesuai CACHE_BACKEND. Mengembalikan nil untuk "none"
+// (cache nonaktif). Backend redis jatuh ke LRU in-memory jika Redis tidak bisa di-ping saat startup;
+// pilihan ini tidak berubah lagi sampai proses di-restart.
+func StoreFromConfig(ctx context.Context, cfg config.CacheConfig, rdb redis.UniversalClient) (Store, error) {
+	switch cfg.Backend {
+	case "redis":
+		if err := rdb.Ping(ctx).Err(); err != nil {
+			slog.Warn("Redis is not reachable, falling back to in-memory cache", "error", err)
+			return NewMemoryStore(cfg.MemorySize), nil
+		}
+		return NewRedisStore(rdb), nil
+	case "memory":
+		return NewMemoryStore(cfg.MemorySize), nil
+	case "none":
+		return nil, nil
+	default:
+		return nil, fmt.Errorf("unknown cache backend %q", cfg.Backend)
+	}
+}
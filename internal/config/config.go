@@ -0,0 +1,335 @@
+package config
+
+import "time"
+
+// Config berisi seluruh konfigurasi aplikasi yang dibaca dari environment variable
+type Config struct {
+	Server   ServerConfig
+	Database DatabaseConfig
+	Redis    RedisConfig
+	Cache    CacheConfig
+	Log      LogConfig
+	Tracing  TracingConfig
+	Outbox   OutboxConfig
+	Webhook  WebhookConfig
+	Health   HealthConfig
+	Cookie   CookieConfig
+	CORS     CORSConfig
+	Security SecurityConfig
+	TLS      TLSConfig
+	JWT      JWTConfig
+	Token    TokenConfig
+
+	Introspection IntrospectionConfig
+}
+
+// ServerConfig mengatur timeout http.Server dan budget waktu per request
+type ServerConfig struct {
+	ReadHeaderTimeout time.Duration // SERVER_READ_HEADER_TIMEOUT
+	ReadTimeout       time.Duration // SERVER_READ_TIMEOUT
+	WriteTimeout      time.Duration // SERVER_WRITE_TIMEOUT, harus lebih besar dari HandlerTimeout
+	IdleTimeout       time.Duration // SERVER_IDLE_TIMEOUT untuk koneksi keep-alive
+	HandlerTimeout    time.Duration // SERVER_HANDLER_TIMEOUT, lewat dari ini request dijawab 503
+	ShutdownTimeout   time.Duration // SERVER_SHUTDOWN_TIMEOUT, batas waktu menunggu request selesai saat shutdown
+	MaxHeaderBytes    int           // SERVER_MAX_HEADER_BYTES, ukuran maksimum header request
+	MaxBodyBytes      int64         // SERVER_MAX_BODY_BYTES, ukuran maksimum body JSON (tidak berlaku untuk import)
+	ImportMaxBytes    int64         // IMPORT_MAX_BYTES, ukuran maksimum body import CSV/NDJSON
+}
+
+// DatabaseConfig mengatur koneksi Postgres
+type DatabaseConfig struct {
+	URL             string        // DATABASE_URL, DSN primary (format URL atau key=value)
+	ReplicaURLs     []string      // DATABASE_REPLICA_URLS, DSN read replica dipisah koma
+	MaxConns        int           // DB_MAX_CONNS (dulu DB_MAX_OPEN_CONNS), 0 berarti default pgxpool (jumlah CPU, minimal 4)
+	MinConns        int           // DB_MIN_CONNS, koneksi yang selalu dijaga tetap terbuka
+	ConnMaxLifetime time.Duration // DB_CONN_MAX_LIFETIME
+	ConnMaxIdleTime time.Duration // DB_CONN_MAX_IDLE_TIME
+	ConnectTimeout  time.Duration // DB_CONNECT_TIMEOUT, total waktu retry koneksi saat startup
+	QueryTimeout    time.Duration // DB_QUERY_TIMEOUT, deadline per operasi (query atau transaksi)
+}
+
+// RedisConfig mengatur koneksi Redis
+type RedisConfig struct {
+	Mode             string        // REDIS_MODE: standalone, sentinel atau cluster
+	Addrs            []string      // REDIS_ADDRS dipisah koma (node cluster / sentinel), default REDIS_ADDR
+	DB               int           // REDIS_DB, hanya standalone dan sentinel
+	Username         string        // REDIS_USERNAME, user ACL
+	Password         string        // REDIS_PASSWORD
+	MasterName       string        // REDIS_SENTINEL_MASTER, nama master yang dipantau sentinel
+	SentinelUsername string        // REDIS_SENTINEL_USERNAME
+	SentinelPassword string        // REDIS_SENTINEL_PASSWORD
+	KeyPrefix        string        // REDIS_KEY_PREFIX, namespace semua key (misalnya "prod:")
+	TLS              bool          // REDIS_TLS
+	TLSCAFile        string        // REDIS_TLS_CA_FILE, CA untuk verifikasi server (default CA sistem)
+	TLSCertFile      string        // REDIS_TLS_CERT_FILE, client certificate (opsional)
+	TLSKeyFile       string        // REDIS_TLS_KEY_FILE
+	TLSServerName    string        // REDIS_TLS_SERVER_NAME, override SNI/hostname yang diverifikasi
+	TLSSkipVerify    bool          // REDIS_TLS_INSECURE_SKIP_VERIFY, hanya untuk development
+	DialTimeout      time.Duration // REDIS_DIAL_TIMEOUT
+	ReadTimeout      time.Duration // REDIS_READ_TIMEOUT, deadline per command
+	WriteTimeout     time.Duration // REDIS_WRITE_TIMEOUT, deadline per command
+	PoolTimeout      time.Duration // REDIS_POOL_TIMEOUT, lama menunggu koneksi kosong di pool
+}
+
+// CacheConfig mengatur cache read-through data user
+type CacheConfig struct {
+	Backend     string        // CACHE_BACKEND: redis, memory atau none
+	UserTTL     time.Duration // CACHE_USER_TTL, TTL cache user per ID
+	UserListTTL time.Duration // CACHE_USER_LIST_TTL, TTL cache halaman list user
+	MemorySize  int           // CACHE_MEMORY_SIZE, jumlah maksimum key di cache in-memory
+	Beta        float64       // CACHE_XFETCH_BETA, agresivitas early refresh (0 = nonaktif)
+	ReplicaLag  time.Duration // CACHE_REPLICA_LAG, jeda invalidation kedua jika ada read replica (0 = nonaktif)
+}
+
+// LogConfig mengatur output log
+type LogConfig struct {
+	Level  string // LOG_LEVEL: debug, info, warn, error
+	Format string // LOG_FORMAT: json atau text
+}
+
+// TracingConfig mengatur OpenTelemetry tracing
+type TracingConfig struct {
+	Exporter     string  // TRACING_EXPORTER: none, stdout atau otlp
+	OTLPEndpoint string  // TRACING_OTLP_ENDPOINT, kosong berarti pakai OTEL_EXPORTER_OTLP_* atau default localhost:4318
+	ServiceName  string  // OTEL_SERVICE_NAME
+	SampleRatio  float64 // TRACING_SAMPLE_RATIO, 0 sampai 1 (trace dari client yang sudah di-sample tetap diikuti)
+}
+
+// OutboxConfig mengatur dispatcher transactional outbox
+type OutboxConfig struct {
+	Sinks          []string      // OUTBOX_SINKS: log, redis, webhook (dipisah koma)
+	PollInterval   time.Duration // OUTBOX_POLL_INTERVAL
+	BatchSize      int           // OUTBOX_BATCH_SIZE
+	MaxAttempts    int           // OUTBOX_MAX_ATTEMPTS, setelah itu event ditandai failed
+	RedisStream    string        // OUTBOX_REDIS_STREAM
+	RedisMaxLen    int64         // OUTBOX_REDIS_MAXLEN, 0 berarti tidak dibatasi
+	WebhookURL     string        // OUTBOX_WEBHOOK_URL
+	WebhookTimeout time.Duration // OUTBOX_WEBHOOK_TIMEOUT
+	LeaseDuration  time.Duration // OUTBOX_LEASE_DURATION, lama batch di-claim sebelum bisa diambil instance lain
+}
+
+// WebhookConfig mengatur pengiriman webhook ke partner
+type WebhookConfig struct {
+	Enabled       bool          // WEBHOOK_ENABLED
+	PollInterval  time.Duration // WEBHOOK_POLL_INTERVAL
+	BatchSize     int           // WEBHOOK_BATCH_SIZE
+	MaxAttempts   int           // WEBHOOK_MAX_ATTEMPTS, setelah itu delivery ditandai failed
+	Timeout       time.Duration // WEBHOOK_TIMEOUT per request
+	DisableAfter  int           // WEBHOOK_DISABLE_AFTER, jumlah kegagalan berturut-turut sebelum endpoint dinonaktifkan
+	LeaseDuration time.Duration // WEBHOOK_LEASE_DURATION, lama batch di-claim sebelum bisa diambil instance lain
+}
+
+// HealthConfig mengatur readiness check dan graceful shutdown
+type HealthConfig struct {
+	CheckTimeout time.Duration // HEALTH_CHECK_TIMEOUT, batas waktu ping per dependency di /readyz
+	DrainDelay   time.Duration // SHUTDOWN_DRAIN_DELAY, jeda antara /readyz gagal dan server.Shutdown
+}
+
+// CookieConfig mengatur atribut cookie refresh token dan CSRF
+type CookieConfig struct {
+	Secure     bool   // COOKIE_SECURE, cookie hanya dikirim lewat HTTPS
+	SameSite   string // COOKIE_SAMESITE: lax, strict atau none
+	Domain     string // COOKIE_DOMAIN, kosong berarti host-only
+	Path       string // COOKIE_PATH, path cookie refresh token
+	HostPrefix bool   // COOKIE_HOST_PREFIX, pakai prefix __Host- (butuh Secure, Path "/" dan tanpa Domain)
+	CSRF       bool   // CSRF_ENABLED, double-submit CSRF token untuk endpoint yang memakai cookie
+}
+
+// CORSConfig mengatur header CORS untuk frontend di origin lain
+type CORSConfig struct {
+	AllowedOrigins   []string      // CORS_ALLOWED_ORIGINS dipisah koma, boleh "*" atau wildcard subdomain ("https://*.example.com"); kosong = CORS nonaktif
+	AllowedMethods   []string      // CORS_ALLOWED_METHODS
+	AllowedHeaders   []string      // CORS_ALLOWED_HEADERS, request header yang boleh dikirim
+	ExposedHeaders   []string      // CORS_EXPOSED_HEADERS, response header yang boleh dibaca JavaScript
+	AllowCredentials bool          // CORS_ALLOW_CREDENTIALS, izinkan cookie (tidak berlaku untuk origin "*")
+	MaxAge           time.Duration // CORS_MAX_AGE, lama browser menyimpan hasil preflight
+}
+
+// SecurityConfig mengatur security header di setiap response
+type SecurityConfig struct {
+	HSTSMaxAge            time.Duration // SECURITY_HSTS_MAX_AGE, 0 = tanpa HSTS; hanya dikirim untuk request HTTPS
+	HSTSIncludeSubdomains bool          // SECURITY_HSTS_INCLUDE_SUBDOMAINS
+	FrameOptions          string        // SECURITY_FRAME_OPTIONS
+	ReferrerPolicy        string        // SECURITY_REFERRER_POLICY
+	ContentSecurityPolicy string        // SECURITY_CSP
+}
+
+// TLSConfig mengatur HTTPS dan mutual TLS di server. TLS aktif jika CertFile dan KeyFile diisi.
+type TLSConfig struct {
+	CertFile       string        // TLS_CERT_FILE
+	KeyFile        string        // TLS_KEY_FILE
+	MinVersion     string        // TLS_MIN_VERSION: 1.2 atau 1.3
+	CipherSuites   []string      // TLS_CIPHER_SUITES, nama cipher suite Go dipisah koma (hanya TLS 1.2)
+	ClientCAFile   string        // TLS_CLIENT_CA_FILE, CA untuk verifikasi client certificate (mTLS)
+	ClientAuth     string        // TLS_CLIENT_AUTH: none, request, verify_if_given atau require (kosong = verify_if_given)
+	ReloadInterval time.Duration // TLS_RELOAD_INTERVAL, interval cek perubahan file certificate
+}
+
+// JWTConfig mengatur claim yang diisi dan divalidasi di access dan refresh token
+type JWTConfig struct {
+	Issuer         string        // JWT_ISSUER, claim iss
+	Audience       string        // JWT_AUDIENCE, claim aud
+	Leeway         time.Duration // JWT_LEEWAY, toleransi selisih jam untuk exp, nbf dan iat
+	Algorithm      string        // JWT_ALGORITHM: RS256, ES256, EdDSA atau HS256
+	PrivateKeyFile string        // JWT_PRIVATE_KEY_FILE, private key PEM untuk RS256/ES256/EdDSA
+	Secret         string        // JWT_SECRET, secret HS256 (minimal 32 byte)
+}
+
+// TokenConfig mengatur access token yang diterbitkan dan umur sesi login
+type TokenConfig struct {
+	AccessFormat       string        // TOKEN_ACCESS_FORMAT: jwt (self-contained) atau opaque (reference token di Redis)
+	AccessTTL          time.Duration // TOKEN_ACCESS_TTL
+	RefreshTTL         time.Duration // TOKEN_REFRESH_TTL
+	RememberMeTTL      time.Duration // TOKEN_REMEMBER_ME_TTL, umur refresh token jika login dengan remember_me
+	SessionMaxLifetime time.Duration // TOKEN_SESSION_MAX_LIFETIME, umur maksimal sesi sejak login walaupun terus di-refresh (0 = tanpa batas)
+	IdleTimeout        time.Duration // TOKEN_IDLE_TIMEOUT, sesi berakhir jika tidak di-refresh selama ini (0 = nonaktif, tidak berlaku untuk remember_me)
+}
+
+// IntrospectionConfig mengatur service internal yang boleh memanggil /introspect dan /revoke dengan API key
+type IntrospectionConfig struct {
+	APIKeys []string // INTROSPECTION_API_KEYS, pasangan "nama:key" dipisah koma, key minimal 32 karakter
+}
+
+// Load membaca konfigurasi dari environment variable, dengan default untuk development lokal
+func Load() *Config {
+	// Sisa konfigurasi database/sql sebelum pindah ke pgxpool
+	warnRemovedEnv("DB_MAX_IDLE_CONNS", "pgxpool has no idle connection limit, use DB_MIN_CONNS and DB_CONN_MAX_IDLE_TIME")
+
+	return &Config{
+		Server: ServerConfig{
+			ReadHeaderTimeout: getEnvDuration("SERVER_READ_HEADER_TIMEOUT", 5*time.Second),
+			ReadTimeout:       getEnvDuration("SERVER_READ_TIMEOUT", 15*time.Second),
+			WriteTimeout:      getEnvDuration("SERVER_WRITE_TIMEOUT", 15*time.Second),
+			IdleTimeout:       getEnvDuration("SERVER_IDLE_TIMEOUT", 60*time.Second),
+			HandlerTimeout:    getEnvDuration("SERVER_HANDLER_TIMEOUT", 10*time.Second),
+			ShutdownTimeout:   getEnvDuration("SERVER_SHUTDOWN_TIMEOUT", 5*time.Second),
+			MaxHeaderBytes:    getEnvInt("SERVER_MAX_HEADER_BYTES", 64<<10),
+			MaxBodyBytes:      int64(getEnvInt("SERVER_MAX_BODY_BYTES", 1<<20)),
+			ImportMaxBytes:    int64(getEnvInt("IMPORT_MAX_BYTES", 32<<20)),
+		},
+		Database: DatabaseConfig{
+			URL:             getEnv("DATABASE_URL", "user=admin dbname=main_db sslmode=disable password=delodelo123 host=localhost port=5432"),
+			ReplicaURLs:     getEnvList("DATABASE_REPLICA_URLS", nil),
+			MaxConns:        getEnvIntRenamed("DB_MAX_CONNS", "DB_MAX_OPEN_CONNS", 25),
+			MinConns:        getEnvInt("DB_MIN_CONNS", 2),
+			ConnMaxLifetime: getEnvDuration("DB_CONN_MAX_LIFETIME", 30*time.Minute),
+			ConnMaxIdleTime: getEnvDuration("DB_CONN_MAX_IDLE_TIME", 5*time.Minute),
+			ConnectTimeout:  getEnvDuration("DB_CONNECT_TIMEOUT", 60*time.Second),
+			QueryTimeout:    getEnvDuration("DB_QUERY_TIMEOUT", 5*time.Second),
+		},
+		Redis: RedisConfig{
+			Mode:             getEnv("REDIS_MODE", "standalone"),
+			Addrs:            getEnvList("REDIS_ADDRS", []string{getEnv("REDIS_ADDR", "localhost:6379")}),
+			DB:               getEnvInt("REDIS_DB", 0),
+			Username:         getEnv("REDIS_USERNAME", ""),
+			Password:         getEnv("REDIS_PASSWORD", ""),
+			MasterName:       getEnv("REDIS_SENTINEL_MASTER", ""),
+			SentinelUsername: getEnv("REDIS_SENTINEL_USERNAME", ""),
+			SentinelPassword: getEnv("REDIS_SENTINEL_PASSWORD", ""),
+			KeyPrefix:        getEnv("REDIS_KEY_PREFIX", ""),
+			TLS:              getEnvBool("REDIS_TLS", false),
+			TLSCAFile:        getEnv("REDIS_TLS_CA_FILE", ""),
+			TLSCertFile:      getEnv("REDIS_TLS_CERT_FILE", ""),
+			TLSKeyFile:       getEnv("REDIS_TLS_KEY_FILE", ""),
+			TLSServerName:    getEnv("REDIS_TLS_SERVER_NAME", ""),
+			TLSSkipVerify:    getEnvBool("REDIS_TLS_INSECURE_SKIP_VERIFY", false),
+			DialTimeout:      getEnvDuration("REDIS_DIAL_TIMEOUT", 5*time.Second),
+			ReadTimeout:      getEnvDuration("REDIS_READ_TIMEOUT", 3*time.Second),
+			WriteTimeout:     getEnvDuration("REDIS_WRITE_TIMEOUT", 3*time.Second),
+			PoolTimeout:      getEnvDuration("REDIS_POOL_TIMEOUT", 4*time.Second),
+		},
+		Cache: CacheConfig{
+			Backend:     getEnv("CACHE_BACKEND", "redis"),
+			UserTTL:     getEnvDuration("CACHE_USER_TTL", 5*time.Minute),
+			UserListTTL: getEnvDuration("CACHE_USER_LIST_TTL", 30*time.Second),
+			MemorySize:  getEnvInt("CACHE_MEMORY_SIZE", 10000),
+			Beta:        getEnvFloat("CACHE_XFETCH_BETA", 1),
+			ReplicaLag:  getEnvDuration("CACHE_REPLICA_LAG", 2*time.Second),
+		},
+		Log: LogConfig{
+			Level:  getEnv("LOG_LEVEL", "info"),
+			Format: getEnv("LOG_FORMAT", "json"),
+		},
+		Tracing: TracingConfig{
+			Exporter:     getEnv("TRACING_EXPORTER", "none"),
+			OTLPEndpoint: getEnv("TRACING_OTLP_ENDPOINT", ""),
+			ServiceName:  getEnv("OTEL_SERVICE_NAME", "betest"),
+			SampleRatio:  getEnvFloat("TRACING_SAMPLE_RATIO", 1),
+		},
+		Outbox: OutboxConfig{
+			Sinks:          getEnvList("OUTBOX_SINKS", []string{"log"}),
+			PollInterval:   getEnvDuration("OUTBOX_POLL_INTERVAL", time.Second),
+			BatchSize:      getEnvInt("OUTBOX_BATCH_SIZE", 100),
+			MaxAttempts:    getEnvInt("OUTBOX_MAX_ATTEMPTS", 20),
+			RedisStream:    getEnv("OUTBOX_REDIS_STREAM", "events:user"),
+			RedisMaxLen:    int64(getEnvInt("OUTBOX_REDIS_MAXLEN", 100000)),
+			WebhookURL:     getEnv("OUTBOX_WEBHOOK_URL", ""),
+			WebhookTimeout: getEnvDuration("OUTBOX_WEBHOOK_TIMEOUT", 5*time.Second),
+			LeaseDuration:  getEnvDuration("OUTBOX_LEASE_DURATION", 5*time.Minute),
+		},
+		Webhook: WebhookConfig{
+			Enabled:       getEnvBool("WEBHOOK_ENABLED", true),
+			PollInterval:  getEnvDuration("WEBHOOK_POLL_INTERVAL", time.Second),
+			BatchSize:     getEnvInt("WEBHOOK_BATCH_SIZE", 50),
+			MaxAttempts:   getEnvInt("WEBHOOK_MAX_ATTEMPTS", 10),
+			Timeout:       getEnvDuration("WEBHOOK_TIMEOUT", 10*time.Second),
+			DisableAfter:  getEnvInt("WEBHOOK_DISABLE_AFTER", 20),
+			LeaseDuration: getEnvDuration("WEBHOOK_LEASE_DURATION", 10*time.Minute),
+		},
+		Health: HealthConfig{
+			CheckTimeout: getEnvDuration("HEALTH_CHECK_TIMEOUT", 2*time.Second),
+			DrainDelay:   getEnvDuration("SHUTDOWN_DRAIN_DELAY", 5*time.Second),
+		},
+		Cookie: CookieConfig{
+			Secure:     getEnvBool("COOKIE_SECURE", false),
+			SameSite:   getEnv("COOKIE_SAMESITE", "lax"),
+			Domain:     getEnv("COOKIE_DOMAIN", ""),
+			Path:       getEnv("COOKIE_PATH", "/refresh"),
+			HostPrefix: getEnvBool("COOKIE_HOST_PREFIX", false),
+			CSRF:       getEnvBool("CSRF_ENABLED", true),
+		},
+		CORS: CORSConfig{
+			AllowedOrigins:   getEnvList("CORS_ALLOWED_ORIGINS", nil),
+			AllowedMethods:   getEnvList("CORS_ALLOWED_METHODS", []string{"GET", "POST", "PUT", "DELETE"}),
+			AllowedHeaders:   getEnvList("CORS_ALLOWED_HEADERS", []string{"Authorization", "Content-Type", "X-CSRF-Token", "X-Request-ID"}),
+			ExposedHeaders:   getEnvList("CORS_EXPOSED_HEADERS", []string{"X-Request-ID", "Content-Disposition"}),
+			AllowCredentials: getEnvBool("CORS_ALLOW_CREDENTIALS", true),
+			MaxAge:           getEnvDuration("CORS_MAX_AGE", 10*time.Minute),
+		},
+		Security: SecurityConfig{
+			HSTSMaxAge:            getEnvDuration("SECURITY_HSTS_MAX_AGE", 365*24*time.Hour),
+			HSTSIncludeSubdomains: getEnvBool("SECURITY_HSTS_INCLUDE_SUBDOMAINS", true),
+			FrameOptions:          getEnv("SECURITY_FRAME_OPTIONS", "DENY"),
+			ReferrerPolicy:        getEnv("SECURITY_REFERRER_POLICY", "no-referrer"),
+			ContentSecurityPolicy: getEnv("SECURITY_CSP", "default-src 'none'; frame-ancestors 'none'"),
+		},
+		TLS: TLSConfig{
+			CertFile:       getEnv("TLS_CERT_FILE", ""),
+			KeyFile:        getEnv("TLS_KEY_FILE", ""),
+			MinVersion:     getEnv("TLS_MIN_VERSION", "1.2"),
+			CipherSuites:   getEnvList("TLS_CIPHER_SUITES", nil),
+			ClientCAFile:   getEnv("TLS_CLIENT_CA_FILE", ""),
+			ClientAuth:     getEnv("TLS_CLIENT_AUTH", ""),
+			ReloadInterval: getEnvDuration("TLS_RELOAD_INTERVAL", time.Minute),
+		},
+		JWT: JWTConfig{
+			Issuer:         getEnv("JWT_ISSUER", "betest"),
+			Audience:       getEnv("JWT_AUDIENCE", "betest-api"),
+			Leeway:         getEnvDuration("JWT_LEEWAY", 30*time.Second),
+			Algorithm:      getEnv("JWT_ALGORITHM", "RS256"),
+			PrivateKeyFile: getEnv("JWT_PRIVATE_KEY_FILE", ""),
+			Secret:         getEnv("JWT_SECRET", ""),
+		},
+		Token: TokenConfig{
+			AccessFormat:       getEnv("TOKEN_ACCESS_FORMAT", "jwt"),
+			AccessTTL:          getEnvDuration("TOKEN_ACCESS_TTL", 15*time.Minute),
+			RefreshTTL:         getEnvDuration("TOKEN_REFRESH_TTL", 7*24*time.Hour),
+			RememberMeTTL:      getEnvDuration("TOKEN_REMEMBER_ME_TTL", 30*24*time.Hour),
+			SessionMaxLifetime: getEnvDuration("TOKEN_SESSION_MAX_LIFETIME", 90*24*time.Hour),
+			IdleTimeout:        getEnvDuration("TOKEN_IDLE_TIMEOUT", 0),
+		},
+		Introspection: IntrospectionConfig{
+			APIKeys: getEnvList("INTROSPECTION_API_KEYS", nil),
+		},
+	}
+}
@@ -0,0 +1,100 @@
+package config
+
+import (
+	"log/slog"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+func getEnv(key, fallback string) string {
+	if v, ok := os.LookupEnv(key); ok && v != "" {
+		return v
+	}
+	return fallback
+}
+
+func getEnvInt(key string, fallback int) int {
+	v := os.Getenv(key)
+	if v == "" {
+		return fallback
+	}
+	n, err := strconv.Atoi(v)
+	if err != nil {
+		slog.Warn("Invalid environment variable, using default", "key", key, "value", v, "default", fallback)
+		return fallback
+	}
+	return n
+}
+
+// getEnvIntRenamed membaca key, atau oldKey (nama lama yang sudah deprecated) jika key tidak diset,
+// supaya deployment yang masih memakai nama lama tidak diam-diam kembali ke default
+func getEnvIntRenamed(key, oldKey string, fallback int) int {
+	if os.Getenv(key) == "" && os.Getenv(oldKey) != "" {
+		slog.Warn("Deprecated environment variable, please rename it", "key", oldKey, "replacement", key)
+		return getEnvInt(oldKey, fallback)
+	}
+	return getEnvInt(key, fallback)
+}
+
+// warnRemovedEnv memperingatkan environment variable lama yang sudah tidak dipakai lagi
+func warnRemovedEnv(key, hint string) {
+	if os.Getenv(key) != "" {
+		slog.Warn("Environment variable is no longer supported and is ignored", "key", key, "hint", hint)
+	}
+}
+
+func getEnvFloat(key string, fallback float64) float64 {
+	v := os.Getenv(key)
+	if v == "" {
+		return fallback
+	}
+	f, err := strconv.ParseFloat(v, 64)
+	if err != nil {
+		slog.Warn("Invalid environment variable, using default", "key", key, "value", v, "default", fallback)
+		return fallback
+	}
+	return f
+}
+
+func getEnvBool(key string, fallback bool) bool {
+	v := os.Getenv(key)
+	if v == "" {
+		return fallback
+	}
+	b, err := strconv.ParseBool(v)
+	if err != nil {
+		slog.Warn("Invalid environment variable, using default", "key", key, "value", v, "default", fallback)
+		return fallback
+	}
+	return b
+}
+
+func getEnvDuration(key string, fallback time.Duration) time.Duration {
+	v := os.Getenv(key)
+	if v == "" {
+		return fallback
+	}
+	d, err := time.ParseDuration(v)
+	if err != nil {
+		slog.Warn("Invalid environment variable, using default", "key", key, "value", v, "default", fallback)
+		return fallback
+	}
+	return d
+}
+
+// getEnvList membaca daftar yang dipisah koma, misalnya "log,redis"
+func getEnvList(key string, fallback []string) []string {
+	v := os.Getenv(key)
+	if v == "" {
+		return fallback
+	}
+	var list []string
+	for _, item := range strings.Split(v, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			list = append(list, item)
+		}
+	}
+	return list
+}
@@ -0,0 +1,142 @@
+package cookie
+
+import (
+	"betest/internal/config"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// CSRFHeader adalah header yang harus berisi nilai cookie CSRF (double-submit)
+const CSRFHeader = "X-CSRF-Token"
+
+// Policy menentukan nama dan atribut cookie refresh token dan CSRF di satu tempat,
+// supaya semua handler yang set/hapus cookie memakai atribut yang sama
+type Policy struct {
+	refreshName string
+	csrfName    string
+	secure      bool
+	sameSite    http.SameSite
+	domain      string
+	path        string
+	csrf        bool
+}
+
+// NewPolicy membuat Policy dari config. Kombinasi atribut yang akan ditolak browser
+// (misalnya SameSite=None tanpa Secure) dikembalikan sebagai error.
+func NewPolicy(cfg config.CookieConfig) (*Policy, error) {
+	p := &Policy{
+		refreshName: "refresh_token",
+		csrfName:    "csrf_token",
+		secure:      cfg.Secure,
+		domain:      cfg.Domain,
+		path:        cfg.Path,
+		csrf:        cfg.CSRF,
+	}
+	if p.path == "" {
+		p.path = "/"
+	}
+
+	switch strings.ToLower(cfg.SameSite) {
+	case "lax", "":
+		p.sameSite = http.SameSiteLaxMode
+	case "strict":
+		p.sameSite = http.SameSiteStrictMode
+	case "none":
+		if !cfg.Secure {
+			return nil, fmt.Errorf("COOKIE_SAMESITE=none requires COOKIE_SECURE=true")
+		}
+		p.sameSite = http.SameSiteNoneMode
+	default:
+		return nil, fmt.Errorf("unknown COOKIE_SAMESITE %q", cfg.SameSite)
+	}
+
+	if cfg.HostPrefix {
+		// Browser hanya menerima cookie __Host- yang Secure, Path=/ dan tanpa Domain
+		if !cfg.Secure || p.path != "/" || p.domain != "" {
+			return nil, fmt.Errorf("COOKIE_HOST_PREFIX requires COOKIE_SECURE=true, COOKIE_PATH=/ and an empty COOKIE_DOMAIN")
+		}
+		p.refreshName = "__Host-" + p.refreshName
+		p.csrfName = "__Host-" + p.csrfName
+	}
+
+	return p, nil
+}
+
+// SetRefreshToken menyimpan refresh token di cookie HttpOnly
+func (p *Policy) SetRefreshToken(w http.ResponseWriter, value string, maxAge time.Duration) {
+	http.SetCookie(w, p.cookie(p.refreshName, value, p.path, true, maxAge))
+}
+
+// ClearRefreshToken menghapus cookie refresh token
+func (p *Policy) ClearRefreshToken(w http.ResponseWriter) {
+	http.SetCookie(w, p.cookie(p.refreshName, "", p.path, true, -1))
+}
+
+// RefreshToken membaca refresh token dari cookie request
+func (p *Policy) RefreshToken(r *http.Request) (string, error) {
+	c, err := r.Cookie(p.refreshName)
+	if err != nil {
+		return "", err
+	}
+	return c.Value, nil
+}
+
+// SetCSRFToken membuat CSRF token baru dan menyimpannya di cookie yang bisa dibaca JavaScript
+// (tidak HttpOnly) dengan Path "/" supaya terbaca dari halaman manapun. Token yang sama juga
+// dikembalikan supaya bisa dikirim di response body. Mengembalikan "" jika CSRF nonaktif.
+func (p *Policy) SetCSRFToken(w http.ResponseWriter, maxAge time.Duration) (string, error) {
+	if !p.csrf {
+		return "", nil
+	}
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	token := base64.RawURLEncoding.EncodeToString(b)
+	http.SetCookie(w, p.cookie(p.csrfName, token, "/", false, maxAge))
+	return token, nil
+}
+
+// ClearCSRFToken menghapus cookie CSRF
+func (p *Policy) ClearCSRFToken(w http.ResponseWriter) {
+	if p.csrf {
+		http.SetCookie(w, p.cookie(p.csrfName, "", "/", false, -1))
+	}
+}
+
+// ValidCSRF mengecek double-submit: header X-CSRF-Token harus sama dengan cookie CSRF.
+// Situs lain bisa membuat browser mengirim cookie, tapi tidak bisa membaca nilainya
+// untuk dikirim ulang di header. Selalu true jika CSRF nonaktif.
+func (p *Policy) ValidCSRF(r *http.Request) bool {
+	if !p.csrf {
+		return true
+	}
+	c, err := r.Cookie(p.csrfName)
+	if err != nil || c.Value == "" {
+		return false
+	}
+	header := r.Header.Get(CSRFHeader)
+	return subtle.ConstantTimeCompare([]byte(header), []byte(c.Value)) == 1
+}
+
+func (p *Policy) cookie(name, value, path string, httpOnly bool, maxAge time.Duration) *http.Cookie {
+	c := &http.Cookie{
+		Name:     name,
+		Value:    value,
+		Path:     path,
+		Domain:   p.domain,
+		Secure:   p.secure,
+		HttpOnly: httpOnly,
+		SameSite: p.sameSite,
+		MaxAge:   int(maxAge / time.Second),
+	}
+	if maxAge < 0 {
+		c.MaxAge = -1
+	}
+	return c
+}
@@ -0,0 +1,153 @@
+package cookie
+
+import (
+	"betest/internal/config"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestNewPolicy(t *testing.T) {
+	tests := []struct {
+		name         string
+		cfg          config.CookieConfig
+		wantErr      bool
+		wantSameSite http.SameSite
+		wantRefresh  string
+	}{
+		{"defaults", config.CookieConfig{}, false, http.SameSiteLaxMode, "refresh_token"},
+		{"strict", config.CookieConfig{SameSite: "Strict"}, false, http.SameSiteStrictMode, "refresh_token"},
+		{"none with secure", config.CookieConfig{SameSite: "none", Secure: true}, false, http.SameSiteNoneMode, "refresh_token"},
+		{"none without secure", config.CookieConfig{SameSite: "none"}, true, 0, ""},
+		{"unknown samesite", config.CookieConfig{SameSite: "sometimes"}, true, 0, ""},
+		{"host prefix", config.CookieConfig{HostPrefix: true, Secure: true}, false, http.SameSiteLaxMode, "__Host-refresh_token"},
+		{"host prefix with explicit root path", config.CookieConfig{HostPrefix: true, Secure: true, Path: "/"}, false, http.SameSiteLaxMode, "__Host-refresh_token"},
+		{"host prefix without secure", config.CookieConfig{HostPrefix: true}, true, 0, ""},
+		{"host prefix with path", config.CookieConfig{HostPrefix: true, Secure: true, Path: "/auth"}, true, 0, ""},
+		{"host prefix with domain", config.CookieConfig{HostPrefix: true, Secure: true, Domain: "example.com"}, true, 0, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p, err := NewPolicy(tt.cfg)
+			if tt.wantErr {
+				if err == nil {
+					t.Error("NewPolicy succeeded; want error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("NewPolicy: %v", err)
+			}
+			if p.sameSite != tt.wantSameSite || p.refreshName != tt.wantRefresh {
+				t.Errorf("sameSite = %v, refresh cookie %q; want %v, %q", p.sameSite, p.refreshName, tt.wantSameSite, tt.wantRefresh)
+			}
+		})
+	}
+}
+
+func TestSetRefreshTokenAttributes(t *testing.T) {
+	p, err := NewPolicy(config.CookieConfig{Secure: true, SameSite: "strict", Path: "/refresh", Domain: "api.example.com"})
+	if err != nil {
+		t.Fatalf("NewPolicy: %v", err)
+	}
+	rec := httptest.NewRecorder()
+	p.SetRefreshToken(rec, "value", time.Hour)
+
+	cookies := rec.Result().Cookies()
+	if len(cookies) != 1 {
+		t.Fatalf("cookies = %v; want 1", cookies)
+	}
+	c := cookies[0]
+	if c.Name != "refresh_token" || !c.HttpOnly || !c.Secure || c.SameSite != http.SameSiteStrictMode ||
+		c.Path != "/refresh" || c.Domain != "api.example.com" || c.MaxAge != 3600 {
+		t.Errorf("cookie = %+v", c)
+	}
+
+	rec = httptest.NewRecorder()
+	p.ClearRefreshToken(rec)
+	if c := rec.Result().Cookies()[0]; c.MaxAge != -1 || c.Value != "" || c.Path != "/refresh" {
+		t.Errorf("cleared cookie = %+v; want empty value with MaxAge -1 on the same path", c)
+	}
+}
+
+func TestValidCSRF(t *testing.T) {
+	p, err := NewPolicy(config.CookieConfig{CSRF: true})
+	if err != nil {
+		t.Fatalf("NewPolicy: %v", err)
+	}
+	rec := httptest.NewRecorder()
+	csrf, err := p.SetCSRFToken(rec, time.Hour)
+	if err != nil || csrf == "" {
+		t.Fatalf("SetCSRFToken = %q, %v", csrf, err)
+	}
+	c := rec.Result().Cookies()[0]
+	if c.Name != "csrf_token" || c.HttpOnly || c.Path != "/" || c.Value != csrf {
+		t.Errorf("CSRF cookie = %+v; want readable csrf_token on / with the returned value", c)
+	}
+
+	tests := []struct {
+		name   string
+		cookie string
+		header string
+		want   bool
+	}{
+		{"matching", csrf, csrf, true},
+		{"missing header", csrf, "", false},
+		{"missing cookie", "", csrf, false},
+		{"both empty", "", "", false},
+		{"different", csrf, csrf[:len(csrf)-1] + "x", false},
+		{"prefix only", csrf, csrf[:10], false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodPost, "/refresh", nil)
+			if tt.cookie != "" {
+				r.AddCookie(&http.Cookie{Name: "csrf_token", Value: tt.cookie})
+			}
+			if tt.header != "" {
+				r.Header.Set(CSRFHeader, tt.header)
+			}
+			if got := p.ValidCSRF(r); got != tt.want {
+				t.Errorf("ValidCSRF = %v; want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCSRFDisabled(t *testing.T) {
+	p, err := NewPolicy(config.CookieConfig{})
+	if err != nil {
+		t.Fatalf("NewPolicy: %v", err)
+	}
+	rec := httptest.NewRecorder()
+	if csrf, err := p.SetCSRFToken(rec, time.Hour); csrf != "" || err != nil || len(rec.Result().Cookies()) != 0 {
+		t.Errorf("SetCSRFToken = %q, %v with cookies %v; want no token", csrf, err, rec.Result().Cookies())
+	}
+	if !p.ValidCSRF(httptest.NewRequest(http.MethodPost, "/refresh", nil)) {
+		t.Error("ValidCSRF = false; want true when CSRF is disabled")
+	}
+}
+
+func TestHostPrefixCSRFCookie(t *testing.T) {
+	p, err := NewPolicy(config.CookieConfig{HostPrefix: true, Secure: true, CSRF: true})
+	if err != nil {
+		t.Fatalf("NewPolicy: %v", err)
+	}
+	rec := httptest.NewRecorder()
+	csrf, err := p.SetCSRFToken(rec, time.Hour)
+	if err != nil {
+		t.Fatalf("SetCSRFToken: %v", err)
+	}
+	if c := rec.Result().Cookies()[0]; c.Name != "__Host-csrf_token" || !c.Secure || c.Domain != "" || c.Path != "/" {
+		t.Errorf("CSRF cookie = %+v; want a valid __Host- cookie", c)
+	}
+
+	// Cookie tanpa prefix (misalnya di-set subdomain lain) tidak boleh diterima
+	r := httptest.NewRequest(http.MethodPost, "/refresh", nil)
+	r.AddCookie(&http.Cookie{Name: "csrf_token", Value: csrf})
+	r.Header.Set(CSRFHeader, csrf)
+	if p.ValidCSRF(r) {
+		t.Error("ValidCSRF accepted a cookie without the __Host- prefix")
+	}
+}
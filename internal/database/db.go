@@ -1,26 +1,155 @@
 package database
 
 import (
+	"betest/internal/config"
+	"betest/internal/tracing"
+	"context"
 	"database/sql"
+	"errors"
 	"fmt"
-	"log"
+	"log/slog"
+	"os"
+	"sync/atomic"
+	"time"
 
-	_ "github.com/lib/pq"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/jackc/pgx/v5/stdlib"
 )
 
+// Pool adalah connection pool pgx ke primary. Pakai langsung untuk fitur yang tidak ada
+// di database/sql seperti COPY, LISTEN/NOTIFY dan batch.
+var Pool *pgxpool.Pool
+
+// DB adalah jalur kompatibilitas database/sql di atas Pool, dipakai untuk semua write
+// dan read yang butuh data terbaru
 var DB *sql.DB
 
-func InitDB() {
+// ReplicaPools dan Replicas adalah koneksi ke read replica (boleh kosong)
+var (
+	ReplicaPools []*pgxpool.Pool
+	Replicas     []*sql.DB
+)
+
+var nextReplica atomic.Uint64
+
+// QueryTimeout adalah deadline default satu operasi database (query atau transaksi)
+var QueryTimeout = 5 * time.Second
+
+// WithTimeout membatasi operasi database dengan QueryTimeout.
+// Deadline yang lebih dekat dari ctx (misalnya budget request) tetap berlaku.
+func WithTimeout(ctx context.Context) (context.Context, context.CancelFunc) {
+	return context.WithTimeout(ctx, QueryTimeout)
+}
+
+// Reader mengembalikan koneksi untuk query read-only (list/get). Jika ada replica dipilih
+// bergantian (round-robin), jika tidak memakai primary. Data di replica bisa sedikit
+// tertinggal, jadi read yang harus melihat hasil write sebelumnya tetap memakai DB.
+func Reader() *sql.DB {
+	if len(Replicas) == 0 {
+		return DB
+	}
+	return Replicas[(nextReplica.Add(1)-1)%uint64(len(Replicas))]
+}
+
+// InitDB membuka koneksi ke primary dan replica. Saat startup Postgres mungkin belum siap
+// (misalnya di docker compose), jadi koneksi di-retry dengan backoff sampai ConnectTimeout.
+func InitDB(cfg config.DatabaseConfig) {
+	QueryTimeout = cfg.QueryTimeout
+
 	var err error
-	connStr := "user=admin dbname=main_db sslmode=disable password=delodelo123 host=localhost port=5432"
-	DB, err = sql.Open("postgres", connStr)
+	Pool, err = open(cfg, cfg.URL)
 	if err != nil {
-		log.Fatal(err)
+		slog.Error("Error opening database", "error", err)
+		os.Exit(1)
+	}
+	if err = connectWithRetry(Pool, "primary", cfg.ConnectTimeout); err != nil {
+		slog.Error("Error connecting to database", "error", err)
+		os.Exit(1)
 	}
+	DB = stdlib.OpenDBFromPool(Pool)
+	slog.Info("Connected to database")
 
-	if err = DB.Ping(); err != nil {
-		log.Fatal(err)
+	// Replica yang tidak bisa dihubungi dilewati, read tetap jalan ke primary atau replica lain
+	for i, url := range cfg.ReplicaURLs {
+		name := fmt.Sprintf("replica-%d", i+1)
+		replica, err := open(cfg, url)
+		if err == nil {
+			err = connectWithRetry(replica, name, cfg.ConnectTimeout)
+		}
+		if err != nil {
+			slog.Warn("Skipping read replica", "replica", name, "error", err)
+			if replica != nil {
+				replica.Close()
+			}
+			continue
+		}
+		ReplicaPools = append(ReplicaPools, replica)
+		Replicas = append(Replicas, stdlib.OpenDBFromPool(replica))
+		slog.Info("Connected to read replica", "replica", name)
 	}
+}
 
-	fmt.Println("Connected to database")
+// CloseDB menutup koneksi primary dan replica, dipanggil saat graceful shutdown.
+// Menutup *sql.DB tidak menutup pool di bawahnya, jadi keduanya ditutup.
+func CloseDB() {
+	for i, replica := range Replicas {
+		replica.Close()
+		ReplicaPools[i].Close()
+	}
+	if DB != nil {
+		if err := DB.Close(); err != nil {
+			slog.Error("Error closing database", "error", err)
+		}
+	}
+	if Pool != nil {
+		Pool.Close()
+	}
+}
+
+// IsUniqueViolation mengecek apakah err berasal dari pelanggaran constraint UNIQUE (SQLSTATE 23505),
+// baik dari pgx langsung maupun lewat database/sql
+func IsUniqueViolation(err error) bool {
+	var pgErr *pgconn.PgError
+	return errors.As(err, &pgErr) && pgErr.Code == "23505"
+}
+
+func open(cfg config.DatabaseConfig, dsn string) (*pgxpool.Pool, error) {
+	poolCfg, err := pgxpool.ParseConfig(dsn)
+	if err != nil {
+		return nil, err
+	}
+	if cfg.MaxConns > 0 {
+		poolCfg.MaxConns = int32(cfg.MaxConns)
+	}
+	poolCfg.MinConns = int32(cfg.MinConns)
+	poolCfg.MaxConnLifetime = cfg.ConnMaxLifetime
+	poolCfg.MaxConnIdleTime = cfg.ConnMaxIdleTime
+	// Setiap query (termasuk lewat database/sql) menjadi child span dari request
+	poolCfg.ConnConfig.Tracer = tracing.PgxTracer{}
+
+	return pgxpool.NewWithConfig(context.Background(), poolCfg)
+}
+
+// connectWithRetry mem-ping db dengan exponential backoff (500ms, 1s, 2s, ... maksimal 10s)
+// sampai berhasil atau timeout habis
+func connectWithRetry(db *pgxpool.Pool, name string, timeout time.Duration) error {
+	deadline := time.Now().Add(timeout)
+	backoff := 500 * time.Millisecond
+
+	for attempt := 1; ; attempt++ {
+		ctx, cancel := WithTimeout(context.Background())
+		err := db.Ping(ctx)
+		cancel()
+		if err == nil {
+			return nil
+		}
+
+		if time.Now().Add(backoff).After(deadline) {
+			return fmt.Errorf("giving up after %d attempts: %w", attempt, err)
+		}
+		slog.Warn("Database not ready, retrying", "database", name, "attempt", attempt, "retry_in", backoff.String(), "error", err)
+		time.Sleep(backoff)
+		backoff = min(backoff*2, 10*time.Second)
+	}
 }
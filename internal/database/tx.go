@@ -0,0 +1,75 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"log/slog"
+	"math/rand/v2"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+// DBTX dipenuhi oleh *sql.DB, *sql.Conn dan *sql.Tx, supaya query yang sama bisa dipakai
+// di dalam maupun di luar transaksi
+type DBTX interface {
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row
+}
+
+// maxTxAttempts adalah jumlah percobaan maksimum WithTx untuk error yang bisa di-retry
+const maxTxAttempts = 3
+
+// WithTx menjalankan fn di dalam transaksi primary: commit jika fn berhasil, rollback jika
+// fn mengembalikan error atau panic. Serialization failure dan deadlock di-retry dari awal,
+// jadi fn harus aman dijalankan ulang dan tidak boleh menulis response HTTP.
+func WithTx(ctx context.Context, fn func(tx *sql.Tx) error) error {
+	return WithTxOptions(ctx, nil, fn)
+}
+
+// WithTxOptions sama dengan WithTx dengan isolation level atau read-only tertentu
+func WithTxOptions(ctx context.Context, opts *sql.TxOptions, fn func(tx *sql.Tx) error) error {
+	backoff := 10 * time.Millisecond
+	for attempt := 1; ; attempt++ {
+		err := runTx(ctx, opts, fn)
+		if err == nil || !isRetryable(err) || attempt >= maxTxAttempts {
+			return err
+		}
+
+		slog.WarnContext(ctx, "Retrying transaction", "attempt", attempt, "error", err)
+		// Jitter supaya transaksi yang bentrok tidak bentrok lagi di percobaan berikutnya
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(backoff + rand.N(backoff)):
+		}
+		backoff *= 2
+	}
+}
+
+func runTx(ctx context.Context, opts *sql.TxOptions, fn func(tx *sql.Tx) error) (err error) {
+	tx, err := DB.BeginTx(ctx, opts)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if p := recover(); p != nil {
+			tx.Rollback()
+			panic(p)
+		}
+	}()
+
+	if err := fn(tx); err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
+
+// isRetryable mengecek serialization_failure (40001) dan deadlock_detected (40P01)
+func isRetryable(err error) bool {
+	var pgErr *pgconn.PgError
+	return errors.As(err, &pgErr) && (pgErr.Code == "40001" || pgErr.Code == "40P01")
+}
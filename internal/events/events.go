@@ -0,0 +1,74 @@
+package events
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Tipe domain event untuk lifecycle user
+const (
+	UserRegistered = "UserRegistered"
+	UserUpdated    = "UserUpdated"
+	UserDeleted    = "UserDeleted"
+	UserLoggedIn   = "UserLoggedIn"
+)
+
+const AggregateUser = "user"
+
+// Sumber pembuatan user pada UserRegistered
+const (
+	SourceRegister = "register"
+	SourceAdmin    = "admin"
+	SourceImport   = "import"
+)
+
+// Event adalah envelope yang disimpan di outbox dan dikirim ke sink.
+// ID unik per event sehingga consumer bisa melakukan dedup (pengiriman at-least-once).
+type Event struct {
+	ID            string          `json:"id"`
+	Type          string          `json:"type"`
+	AggregateType string          `json:"aggregate_type"`
+	AggregateID   string          `json:"aggregate_id"`
+	OccurredAt    time.Time       `json:"occurred_at"`
+	Payload       json.RawMessage `json:"payload"`
+}
+
+type UserRegisteredPayload struct {
+	UserID int    `json:"user_id"`
+	Name   string `json:"name"`
+	Email  string `json:"email"`
+	Source string `json:"source"`
+}
+
+type UserUpdatedPayload struct {
+	UserID int    `json:"user_id"`
+	Name   string `json:"name"`
+	Email  string `json:"email"`
+}
+
+type UserDeletedPayload struct {
+	UserID int    `json:"user_id"`
+	Email  string `json:"email"`
+}
+
+type UserLoggedInPayload struct {
+	UserID int `json:"user_id"`
+}
+
+// New membuat event baru dengan ID dan waktu terjadinya
+func New(eventType, aggregateType, aggregateID string, payload interface{}) (Event, error) {
+	b, err := json.Marshal(payload)
+	if err != nil {
+		return Event{}, err
+	}
+	return Event{
+		ID:            uuid.New().String(),
+		Type:          eventType,
+		AggregateType: aggregateType,
+		AggregateID:   aggregateID,
+		OccurredAt:    time.Now().UTC(),
+		Payload:       b,
+	}, nil
+}
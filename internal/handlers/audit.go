@@ -0,0 +1,116 @@
+package handlers
+
+import (
+	"betest/internal/database"
+	"betest/internal/models"
+	"betest/internal/response"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// parseAuditFilter membangun klausa WHERE dari query parameter
+// actor_id, action, resource_type, resource_id, request_id, from dan to
+func parseAuditFilter(r *http.Request) (string, []interface{}, error) {
+	var conds []string
+	var args []interface{}
+
+	q := r.URL.Query()
+	if actor := q.Get("actor_id"); actor != "" {
+		actorID, err := strconv.Atoi(actor)
+		if err != nil {
+			return "", nil, fmt.Errorf("Invalid actor_id parameter. Must be an integer")
+		}
+		args = append(args, actorID)
+		conds = append(conds, fmt.Sprintf("actor_id = $%d", len(args)))
+	}
+	for _, col := range []string{"action", "resource_type", "resource_id", "request_id"} {
+		if v := q.Get(col); v != "" {
+			args = append(args, v)
+			conds = append(conds, fmt.Sprintf("%s = $%d", col, len(args)))
+		}
+	}
+	if from := q.Get("from"); from != "" {
+		t, err := parseFilterTime(from)
+		if err != nil {
+			return "", nil, fmt.Errorf("Invalid from parameter. Use RFC3339 or YYYY-MM-DD")
+		}
+		args = append(args, t)
+		conds = append(conds, fmt.Sprintf("created_at >= $%d", len(args)))
+	}
+	if to := q.Get("to"); to != "" {
+		t, err := parseFilterTime(to)
+		if err != nil {
+			return "", nil, fmt.Errorf("Invalid to parameter. Use RFC3339 or YYYY-MM-DD")
+		}
+		args = append(args, t)
+		conds = append(conds, fmt.Sprintf("created_at <= $%d", len(args)))
+	}
+
+	if len(conds) == 0 {
+		return "", nil, nil
+	}
+	return " WHERE " + strings.Join(conds, " AND "), args, nil
+}
+
+// GetAuditLogs menampilkan audit log (khusus admin) dengan filter dan pagination
+func GetAuditLogs(w http.ResponseWriter, r *http.Request) {
+	page, limit, err := parsePagination(r)
+	if err != nil {
+		SendError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	where, args, err := parseAuditFilter(r)
+	if err != nil {
+		SendError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	ctx, cancel := database.WithTimeout(r.Context())
+	defer cancel()
+
+	// Count dan list membaca dari replica yang sama supaya konsisten
+	db := database.Reader()
+
+	var total int
+	err = db.QueryRowContext(ctx, "SELECT COUNT(*) FROM audit_log"+where, args...).Scan(&total)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Error counting audit logs", "error", err)
+		SendError(w, http.StatusInternalServerError, "Error fetching audit logs count")
+		return
+	}
+
+	offset := (page - 1) * limit
+	args = append(args, limit, offset)
+	rows, err := db.QueryContext(ctx, fmt.Sprintf(
+		`SELECT id, actor_id, action, resource_type, COALESCE(resource_id, ''), before, after, changes,
+			COALESCE(ip, ''), COALESCE(user_agent, ''), COALESCE(request_id, ''), created_at
+		FROM audit_log%s ORDER BY created_at DESC, id DESC LIMIT $%d OFFSET $%d`, where, len(args)-1, len(args)),
+		args...)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Error fetching audit logs", "error", err)
+		SendError(w, http.StatusInternalServerError, "Error fetching audit logs")
+		return
+	}
+	defer rows.Close()
+
+	logs := []models.AuditLog{}
+	for rows.Next() {
+		var a models.AuditLog
+		var before, after, changes []byte
+		err := rows.Scan(&a.ID, &a.ActorID, &a.Action, &a.ResourceType, &a.ResourceID, &before, &after, &changes,
+			&a.IP, &a.UserAgent, &a.RequestID, &a.CreatedAt)
+		if err != nil {
+			slog.ErrorContext(r.Context(), "Error scanning audit log", "error", err)
+			SendError(w, http.StatusInternalServerError, "Error scanning audit log data")
+			return
+		}
+		a.Before, a.After, a.Changes = before, after, changes
+		logs = append(logs, a)
+	}
+
+	response.SendPaginatedSuccess(w, http.StatusOK, "Audit logs retrieved successfully", logs, paginationMeta(page, limit, total))
+}
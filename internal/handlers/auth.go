@@ -1,42 +1,52 @@
 package handlers
 
 import (
+	"betest/internal/audit"
+	"betest/internal/config"
+	"betest/internal/cookie"
 	"betest/internal/database"
+	"betest/internal/events"
+	"betest/internal/metrics"
+	"betest/internal/middleware"
 	"betest/internal/models"
+	"betest/internal/outbox"
+	"betest/internal/redisclient"
+	"betest/internal/repository"
+	"betest/internal/token"
+	"betest/internal/tracing"
 	"context"
-	"crypto/rand"
-	"crypto/rsa"
-	"encoding/json"
+	"database/sql"
 	"fmt"
+	"log/slog"
 	"net/http"
-	"strings"
+	"strconv"
 	"time"
 
 	"github.com/go-redis/redis/v8"
 	"github.com/golang-jwt/jwt/v5"
-	"github.com/google/uuid"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
 	"golang.org/x/crypto/bcrypt"
 )
 
 var (
-	PrivateKey *rsa.PrivateKey
-	PublicKey  *rsa.PublicKey
-	Rdb        *redis.Client // Exported untuk digunakan di middleware
+	TokenSigner   token.Signer          // Penanda tangan access/refresh token, diisi dari main
+	TokenVerifier token.Verifier        // Verifikasi refresh token, diisi dari main
+	Rdb           redis.UniversalClient // Exported untuk digunakan di middleware
+	Cookies       *cookie.Policy        // Atribut cookie refresh token dan CSRF, diisi dari main
 )
 
-func init() {
-	// Generate RSA keys for demo (in production, load from files)
-	var err error
-	PrivateKey, err = rsa.GenerateKey(rand.Reader, 2048)
+// InitRedis membuat Redis client (standalone, sentinel atau cluster). Read/write timeout
+// menjadi deadline per command, selain deadline dari context request.
+func InitRedis(cfg config.RedisConfig) error {
+	rdb, err := redisclient.New(cfg)
 	if err != nil {
-		panic(err)
+		return err
 	}
-	PublicKey = &PrivateKey.PublicKey
-
-	// Redis client
-	Rdb = redis.NewClient(&redis.Options{
-		Addr: "localhost:6379", // Update if needed
-	})
+	rdb.AddHook(tracing.RedisHook{})
+	Rdb = rdb
+	return nil
 }
 
 type RegisterRequest struct {
@@ -46,21 +56,21 @@ type RegisterRequest struct {
 }
 
 type LoginRequest struct {
-	Email    string `json:"email"`
-	Password string `json:"password"`
+	Email      string `json:"email"`
+	Password   string `json:"password"`
+	RememberMe bool   `json:"remember_me"` // Refresh token berumur TOKEN_REMEMBER_ME_TTL
 }
 
 type AuthResponse struct {
 	User         models.User `json:"user"`
 	AccessToken  string      `json:"access_token"`
-	RefreshToken string      `json:"refresh_token"`
+	RefreshToken string      `json:"refresh_token,omitempty"`
+	CSRFToken    string      `json:"csrf_token,omitempty"`
 }
 
 func Register(w http.ResponseWriter, r *http.Request) {
 	var req RegisterRequest
-	err := json.NewDecoder(r.Body).Decode(&req)
-	if err != nil {
-		SendError(w, http.StatusBadRequest, "Invalid request body")
+	if !decodeJSON(w, r, &req) {
 		return
 	}
 
@@ -71,51 +81,91 @@ func Register(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Insert user
+	ctx, cancel := database.WithTimeout(r.Context())
+	defer cancel()
+
+	// Insert user, event UserRegistered dan penerbitan token dibuat konsisten: token diterbitkan
+	// di dalam transaksi, jadi jika Redis gagal user ikut di-rollback. Token yang sudah
+	// terlanjur ditulis ke Redis (transaksi di-retry atau commit gagal) dicabut kembali.
 	var user models.User
-	err = database.DB.QueryRow("INSERT INTO users (name, email, password) VALUES ($1, $2, $3) RETURNING id, name, email, created_at",
-		req.Name, req.Email, string(hashedPassword)).Scan(&user.ID, &user.Name, &user.Email, &user.CreatedAt)
+	var issued []tokenPair
+	err = database.WithTx(ctx, func(tx *sql.Tx) error {
+		var err error
+		user, err = repository.CreateUser(ctx, tx, models.User{Name: req.Name, Email: req.Email, Password: string(hashedPassword)})
+		if err != nil {
+			return err
+		}
+		err = outbox.WriteUserEvent(ctx, tx, events.UserRegistered, user.ID, events.UserRegisteredPayload{
+			UserID: user.ID,
+			Name:   user.Name,
+			Email:  user.Email,
+			Source: events.SourceRegister,
+		})
+		if err != nil {
+			return err
+		}
+		pair, err := generateTokens(ctx, tokenGrant{UserID: user.ID, AuthTime: time.Now()})
+		if err != nil {
+			return err
+		}
+		issued = append(issued, pair)
+		return nil
+	})
+	if err != nil {
+		revokeTokens(r.Context(), issued...)
+	} else {
+		revokeTokens(r.Context(), issued[:len(issued)-1]...)
+	}
+	if database.IsUniqueViolation(err) {
+		SendError(w, http.StatusConflict, "Email already registered")
+		return
+	}
 	if err != nil {
+		slog.ErrorContext(r.Context(), "Error creating user", "error", err)
 		SendError(w, http.StatusInternalServerError, "Error creating user")
 		return
 	}
+	invalidateUserCache(r)
+	tokens := issued[len(issued)-1]
 
-	// Generate tokens
-	accessToken, refreshToken, err := generateTokens(user.ID)
+	// Set refresh token cookie dan CSRF token (double-submit) untuk /refresh
+	Cookies.SetRefreshToken(w, tokens.Refresh, tokens.RefreshTTL)
+	csrfToken, err := Cookies.SetCSRFToken(w, tokens.RefreshTTL)
 	if err != nil {
 		SendError(w, http.StatusInternalServerError, "Error generating tokens")
 		return
 	}
 
-	// Set refresh token cookie
-	http.SetCookie(w, &http.Cookie{
-		Name:     "refresh_token",
-		Value:    refreshToken,
-		HttpOnly: true,
-		Secure:   false, // Set to true in production with HTTPS
-		Path:     "/",
-		MaxAge:   7 * 24 * 3600, // 7 days
+	audit.Record(r, audit.Entry{
+		ActorID:      &user.ID,
+		Action:       audit.ActionRegister,
+		ResourceType: audit.ResourceUser,
+		ResourceID:   strconv.Itoa(user.ID),
+		After:        user,
 	})
 
 	SendSuccess(w, http.StatusCreated, "User registered successfully", AuthResponse{
 		User:        user,
-		AccessToken: accessToken,
+		AccessToken: tokens.Access,
+		CSRFToken:   csrfToken,
 	})
 }
 
 func Login(w http.ResponseWriter, r *http.Request) {
 	var req LoginRequest
-	err := json.NewDecoder(r.Body).Decode(&req)
-	if err != nil {
-		SendError(w, http.StatusBadRequest, "Invalid request body")
+	if !decodeJSON(w, r, &req) {
 		return
 	}
 
+	ctx, cancel := database.WithTimeout(r.Context())
+	defer cancel()
+
 	// Get user
 	var user models.User
-	err = database.DB.QueryRow("SELECT id, name, email, password, created_at FROM users WHERE email=$1", req.Email).Scan(
+	err := database.DB.QueryRowContext(ctx, "SELECT id, name, email, password, created_at FROM users WHERE email=$1", req.Email).Scan(
 		&user.ID, &user.Name, &user.Email, &user.Password, &user.CreatedAt)
 	if err != nil {
+		recordFailedLogin(r, nil, req.Email)
 		SendError(w, http.StatusUnauthorized, "Invalid credentials")
 		return
 	}
@@ -123,221 +173,327 @@ func Login(w http.ResponseWriter, r *http.Request) {
 	// Check password
 	err = bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(req.Password))
 	if err != nil {
+		recordFailedLogin(r, &user.ID, req.Email)
 		SendError(w, http.StatusUnauthorized, "Invalid credentials")
 		return
 	}
 
 	// Generate tokens
-	accessToken, refreshToken, err := generateTokens(user.ID)
+	tokens, err := generateTokens(r.Context(), tokenGrant{UserID: user.ID, AuthTime: time.Now(), RememberMe: req.RememberMe})
+	if err != nil {
+		SendError(w, http.StatusInternalServerError, "Error generating tokens")
+		return
+	}
+
+	// Set refresh token cookie dan CSRF token (double-submit) untuk /refresh
+	Cookies.SetRefreshToken(w, tokens.Refresh, tokens.RefreshTTL)
+	csrfToken, err := Cookies.SetCSRFToken(w, tokens.RefreshTTL)
 	if err != nil {
 		SendError(w, http.StatusInternalServerError, "Error generating tokens")
 		return
 	}
 
-	// Set refresh token cookie
-	http.SetCookie(w, &http.Cookie{
-		Name:     "refresh_token",
-		Value:    refreshToken,
-		HttpOnly: true,
-		Secure:   false,
-		Path:     "/",
-		MaxAge:   7 * 24 * 3600,
+	// Login tidak mengubah data lain, jadi event ditulis langsung tanpa transaksi setelah token terbit.
+	// Event ini hanya informasi, gagal menulisnya tidak boleh menggagalkan login.
+	err = outbox.WriteUserEvent(ctx, database.DB, events.UserLoggedIn, user.ID, events.UserLoggedInPayload{UserID: user.ID})
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Error writing UserLoggedIn event", "error", err)
+	}
+
+	audit.Record(r, audit.Entry{
+		ActorID:      &user.ID,
+		Action:       audit.ActionLogin,
+		ResourceType: audit.ResourceUser,
+		ResourceID:   strconv.Itoa(user.ID),
 	})
 
+	metrics.LoginsTotal.WithLabelValues(metrics.ResultSuccess).Inc()
 	SendSuccess(w, http.StatusOK, "Login successful", AuthResponse{
 		User:        user,
-		AccessToken: accessToken,
+		AccessToken: tokens.Access,
+		CSRFToken:   csrfToken,
 	})
 }
 
 func RefreshToken(w http.ResponseWriter, r *http.Request) {
-	cookie, err := r.Cookie("refresh_token")
+	result := metrics.ResultFailure
+	defer func() { metrics.TokenRefreshesTotal.WithLabelValues(result).Inc() }()
+
+	refreshToken, err := Cookies.RefreshToken(r)
 	if err != nil {
 		SendError(w, http.StatusUnauthorized, "No refresh token")
 		return
 	}
 
-	// Parse refresh token
-	token, err := jwt.Parse(cookie.Value, func(token *jwt.Token) (interface{}, error) {
-		if _, ok := token.Method.(*jwt.SigningMethodRSA); !ok {
-			return nil, jwt.ErrSignatureInvalid
-		}
-		return PublicKey, nil
-	})
-	if err != nil || !token.Valid {
+	// Parse refresh token, termasuk memastikan token_use=refresh (bukan access token).
+	// Refresh token milik client OAuth2 hanya bisa dipakai lewat /oauth/token.
+	claims, err := token.Parse(refreshToken, TokenVerifier, token.UseRefresh)
+	if err != nil || claims.ClientID != "" {
 		SendError(w, http.StatusUnauthorized, "Invalid refresh token")
 		return
 	}
+	jti, userID := claims.ID, claims.UserID
 
-	claims, ok := token.Claims.(jwt.MapClaims)
-	if !ok {
-		SendError(w, http.StatusUnauthorized, "Invalid token claims")
+	// Rotasi tidak bisa memperpanjang sesi melewati TOKEN_SESSION_MAX_LIFETIME sejak login
+	g := refreshGrant(claims)
+	if g.sessionExpired() {
+		SendError(w, http.StatusUnauthorized, "Session expired")
 		return
 	}
 
-	jti, ok := claims["jti"].(string)
-	if !ok {
-		SendError(w, http.StatusUnauthorized, "Invalid token")
+	// Token baru ditandatangani lebih dulu, lalu validasi jti lama, blacklist access token lama,
+	// penghapusan key lama dan penyimpanan key baru dijalankan atomik dalam satu script Lua.
+	// Dengan begitu dari beberapa refresh paralel dengan cookie yang sama hanya satu yang berhasil.
+	tokens, err := signTokenPair(r.Context(), g)
+	if err != nil {
+		SendError(w, http.StatusInternalServerError, "Error generating tokens")
 		return
 	}
 
-	userIDFloat, ok := claims["user_id"].(float64)
-	if !ok {
-		SendError(w, http.StatusUnauthorized, "Invalid token")
+	status, err := rotateRefreshToken(r.Context(), jti, userID, tokens)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Error rotating refresh token", "error", err)
+		SendError(w, http.StatusInternalServerError, "Error generating tokens")
 		return
 	}
-	userID := int(userIDFloat)
-
-	// Check if jti exists in Redis
-	val, err := Rdb.Get(context.Background(), fmt.Sprintf("refresh_token:%s", jti)).Result()
-	if err != nil || val != fmt.Sprintf("%d", userID) {
+	if status == rotateReused {
+		// Token valid secara signature tapi sudah tidak ada di Redis:
+		// sudah pernah di-rotate atau di-logout, kemungkinan dipakai ulang
+		result = metrics.ResultReuse
+		audit.Record(r, audit.Entry{
+			ActorID:      &userID,
+			Action:       audit.ActionRefreshReused,
+			ResourceType: audit.ResourceUser,
+			ResourceID:   strconv.Itoa(userID),
+			After:        map[string]string{"refresh_jti": jti},
+		})
+	}
+	if status != rotateOK {
 		SendError(w, http.StatusUnauthorized, "Invalid refresh token")
 		return
 	}
 
-	// Blacklist access token lama yang terkait dengan refresh token ini
-	// (agar token lama tidak bisa dipakai setelah dapat token baru)
-	oldAccessJti, err := Rdb.Get(context.Background(), fmt.Sprintf("refresh_to_access:%s", jti)).Result()
-	if err == nil && oldAccessJti != "" {
-		// Blacklist dengan TTL 15 menit (maksimal sisa umur access token)
-		Rdb.Set(context.Background(), fmt.Sprintf("blacklist:access_token:%s", oldAccessJti), "1", 15*time.Minute)
-	}
-
-	// Delete old refresh token dan mapping (rotation)
-	Rdb.Del(context.Background(), fmt.Sprintf("refresh_token:%s", jti))
-	Rdb.Del(context.Background(), fmt.Sprintf("refresh_to_access:%s", jti))
-
-	// Generate new tokens
-	accessToken, refreshToken, err := generateTokens(userID)
+	// Set refresh token cookie dan CSRF token (double-submit) untuk /refresh
+	Cookies.SetRefreshToken(w, tokens.Refresh, tokens.RefreshTTL)
+	csrfToken, err := Cookies.SetCSRFToken(w, tokens.RefreshTTL)
 	if err != nil {
 		SendError(w, http.StatusInternalServerError, "Error generating tokens")
 		return
 	}
 
-	// Set new refresh token cookie
-	http.SetCookie(w, &http.Cookie{
-		Name:     "refresh_token",
-		Value:    refreshToken,
-		HttpOnly: true,
-		Secure:   false,
-		Path:     "/",
-		MaxAge:   7 * 24 * 3600,
+	audit.Record(r, audit.Entry{
+		ActorID:      &userID,
+		Action:       audit.ActionRefresh,
+		ResourceType: audit.ResourceUser,
+		ResourceID:   strconv.Itoa(userID),
 	})
 
-	SendSuccess(w, http.StatusOK, "Token refreshed successfully", map[string]string{"access_token": accessToken})
+	result = metrics.ResultSuccess
+	SendSuccess(w, http.StatusOK, "Token refreshed successfully", map[string]string{"access_token": tokens.Access, "csrf_token": csrfToken})
 }
 
-func generateTokens(userID int) (string, string, error) {
-	// Access token dengan JTI untuk tracking dan blacklist
-	accessJti := uuid.New().String()
-	accessClaims := jwt.MapClaims{
-		"user_id": userID,
-		"jti":     accessJti,
-		"exp":     time.Now().Add(15 * time.Minute).Unix(),
-		"iat":     time.Now().Unix(),
-	}
-	accessToken := jwt.NewWithClaims(jwt.SigningMethodRS256, accessClaims)
-	accessTokenString, err := accessToken.SignedString(PrivateKey)
+// tokenGrant adalah pihak yang menerima token: user (login first-party), atau client OAuth2
+// atas nama user (UserID diisi) maupun atas nama dirinya sendiri (client_credentials, UserID 0).
+// AuthTime dan RememberMe menentukan umur token (lihat session.go).
+type tokenGrant struct {
+	UserID      int
+	ClientID    string
+	Scope       string
+	AccessScope string // Scope access token jika dipersempit dari Scope; refresh token tetap memakai Scope
+	AuthTime    time.Time
+	RememberMe  bool
+}
+
+// tokenPair adalah access dan refresh token yang diterbitkan bersama beserta JTI-nya
+type tokenPair struct {
+	Access        string
+	Refresh       string
+	AccessJti     string
+	RefreshJti    string
+	AccessSession string // Nilai key access_token:<jti>, lihat signAccessToken
+
+	AccessTTL     time.Duration
+	RefreshTTL    time.Duration // Umur refresh token (exp dan MaxAge cookie)
+	RefreshKeyTTL time.Duration // TTL key refresh token di Redis, bisa lebih pendek karena idle timeout
+}
+
+func generateTokens(ctx context.Context, g tokenGrant) (_ tokenPair, err error) {
+	// Span sendiri (dengan child span untuk signing dan command Redis)
+	// supaya waktu signing RSA bisa dibedakan dari waktu Redis
+	ctx, span := tracing.Start(ctx, "generateTokens", trace.WithAttributes(attribute.Int("user_id", g.UserID)))
+	defer func() {
+		if err != nil {
+			span.RecordError(err)
+			span.SetStatus(codes.Error, err.Error())
+		}
+		span.End()
+	}()
+
+	pair, err := signTokenPair(ctx, g)
 	if err != nil {
-		return "", "", err
+		return tokenPair{}, err
 	}
 
-	// Store access token JTI di Redis dengan expiry 15 menit (sama dengan token expiry)
-	// Key format: "access_token:jti" untuk membedakan dari refresh token
-	err = Rdb.Set(context.Background(), fmt.Sprintf("access_token:%s", accessJti), fmt.Sprintf("%d", userID), 15*time.Minute).Err()
+	// Semua key ditulis dalam satu MULTI/EXEC supaya tidak ada token yang tersimpan setengah
+	_, err = Rdb.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		// Key format: "access_token:jti" untuk membedakan dari refresh token,
+		// expiry sama dengan token expiry (refresh token bisa lebih pendek jika ada idle timeout)
+		pipe.Set(ctx, redisclient.Key(fmt.Sprintf("access_token:%s", pair.AccessJti)), pair.AccessSession, pair.AccessTTL)
+		pipe.Set(ctx, redisclient.Key(fmt.Sprintf("refresh_token:%s", pair.RefreshJti)), fmt.Sprintf("%d", g.UserID), pair.RefreshKeyTTL)
+		// Mapping refresh_token JTI -> access_token JTI agar saat refresh bisa blacklist access token lama
+		pipe.Set(ctx, redisclient.Key(fmt.Sprintf("refresh_to_access:%s", pair.RefreshJti)), pair.AccessJti, pair.RefreshKeyTTL)
+		// Mapping sebaliknya agar logout (dengan access token) bisa mencabut refresh token
+		pipe.Set(ctx, redisclient.Key(fmt.Sprintf("access_to_refresh:%s", pair.AccessJti)), pair.RefreshJti, pair.AccessTTL)
+		return nil
+	})
 	if err != nil {
-		return "", "", err
+		return tokenPair{}, err
 	}
 
-	// Refresh token as JWT
-	refreshJti := uuid.New().String()
-	refreshClaims := jwt.MapClaims{
-		"user_id": userID,
-		"jti":     refreshJti,
-		"exp":     time.Now().Add(7 * 24 * time.Hour).Unix(),
-		"iat":     time.Now().Unix(),
-	}
-	refreshToken := jwt.NewWithClaims(jwt.SigningMethodRS256, refreshClaims)
-	refreshTokenString, err := refreshToken.SignedString(PrivateKey)
+	return pair, nil
+}
+
+// signTokenPair membuat dan menandatangani access dan refresh token baru tanpa menyimpannya di Redis
+func signTokenPair(ctx context.Context, g tokenGrant) (tokenPair, error) {
+	pair := tokenPair{AccessTTL: g.accessTTL(), RefreshTTL: g.refreshTTL(), RefreshKeyTTL: g.refreshKeyTTL()}
+	var err error
+
+	// Access token dengan JTI untuk tracking dan blacklist
+	pair.Access, pair.AccessJti, pair.AccessSession, err = signAccessToken(ctx, g)
 	if err != nil {
-		return "", "", err
+		return tokenPair{}, err
 	}
 
-	// Store refresh token jti in Redis with expiry
-	err = Rdb.Set(context.Background(), fmt.Sprintf("refresh_token:%s", refreshJti), fmt.Sprintf("%d", userID), 7*24*time.Hour).Err()
+	// Refresh token as JWT
+	pair.Refresh, pair.RefreshJti, err = signGrant(ctx, g, token.UseRefresh, pair.RefreshTTL)
 	if err != nil {
-		return "", "", err
+		return tokenPair{}, err
+	}
+
+	return pair, nil
+}
+
+// signAccessToken membuat access token sesuai TOKEN_ACCESS_FORMAT. Selain token dan JTI-nya dikembalikan
+// nilai untuk key access_token:<jti>: user id untuk JWT, atau seluruh claim (JSON) untuk opaque token
+// karena JWTMiddleware membaca claim opaque token dari key tersebut.
+func signAccessToken(ctx context.Context, g tokenGrant) (signed, jti, session string, err error) {
+	claims := grantClaims(g, token.UseAccess, g.accessTTL())
+	if g.AccessScope != "" {
+		claims.Scope = g.AccessScope
+	}
+	if tokenConfig.AccessFormat == token.FormatOpaque {
+		signed, session, err = token.NewOpaque(claims)
+		return signed, claims.ID, session, err
 	}
+	signed, err = signToken(ctx, claims)
+	return signed, claims.ID, strconv.Itoa(g.UserID), err
+}
+
+// signGrant menandatangani satu token JWT untuk grant dan mengembalikan token beserta JTI-nya
+func signGrant(ctx context.Context, g tokenGrant, use string, ttl time.Duration) (string, string, error) {
+	claims := grantClaims(g, use, ttl)
+	signed, err := signToken(ctx, claims)
+	return signed, claims.ID, err
+}
 
-	// Mapping refresh_token JTI -> access_token JTI agar saat refresh bisa blacklist access token lama
-	err = Rdb.Set(context.Background(), fmt.Sprintf("refresh_to_access:%s", refreshJti), accessJti, 7*24*time.Hour).Err()
+func grantClaims(g tokenGrant, use string, ttl time.Duration) *token.Claims {
+	claims := token.NewClaims(g.UserID, use, ttl)
+	claims.ClientID = g.ClientID
+	claims.Scope = g.Scope
+	claims.RememberMe = g.RememberMe
+	if !g.AuthTime.IsZero() {
+		claims.AuthTime = jwt.NewNumericDate(g.AuthTime)
+	}
+	if g.UserID == 0 {
+		claims.Subject = g.ClientID
+	}
+	return claims
+}
+
+// revokeTokens membatalkan token yang sudah diterbitkan tapi tidak jadi dikirim ke client
+// (kompensasi jika langkah setelah generateTokens gagal). Access token juga di-blacklist
+// karena bisa saja key-nya tetap tersimpan jika penghapusan gagal.
+func revokeTokens(ctx context.Context, pairs ...tokenPair) {
+	if len(pairs) == 0 {
+		return
+	}
+	ctx = context.WithoutCancel(ctx)
+	_, err := Rdb.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		for _, p := range pairs {
+			pipe.Del(ctx,
+				redisclient.Key(fmt.Sprintf("access_token:%s", p.AccessJti)),
+				redisclient.Key(fmt.Sprintf("access_to_refresh:%s", p.AccessJti)),
+				redisclient.Key(fmt.Sprintf("refresh_token:%s", p.RefreshJti)),
+				redisclient.Key(fmt.Sprintf("refresh_to_access:%s", p.RefreshJti)))
+			pipe.Set(ctx, redisclient.Key(fmt.Sprintf("blacklist:access_token:%s", p.AccessJti)), "1", p.AccessTTL+token.Leeway)
+		}
+		return nil
+	})
 	if err != nil {
-		return "", "", err
+		slog.ErrorContext(ctx, "Error revoking unused tokens", "count", len(pairs), "error", err)
 	}
+}
+
+func signToken(ctx context.Context, claims jwt.Claims) (string, error) {
+	_, span := tracing.Start(ctx, "jwt.sign", trace.WithAttributes(attribute.String("jwt.alg", TokenSigner.Alg())))
+	defer span.End()
 
-	return accessTokenString, refreshTokenString, nil
+	return TokenSigner.Sign(claims)
 }
 
 func Logout(w http.ResponseWriter, r *http.Request) {
-	// Parse access token dari Authorization header untuk mendapatkan JTI
-	authHeader := r.Header.Get("Authorization")
-	if authHeader != "" {
-		tokenString := strings.TrimPrefix(authHeader, "Bearer ")
-		if tokenString != "" {
-			token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
-				if _, ok := token.Method.(*jwt.SigningMethodRSA); !ok {
-					return nil, jwt.ErrSignatureInvalid
-				}
-				return PublicKey, nil
-			})
-			if err == nil && token.Valid {
-				if claims, ok := token.Claims.(jwt.MapClaims); ok {
-					if jti, ok := claims["jti"].(string); ok {
-						// Tambahkan access token ke blacklist
-						// Hitung sisa waktu expiry token
-						if exp, ok := claims["exp"].(float64); ok {
-							expTime := time.Unix(int64(exp), 0)
-							remainingTime := time.Until(expTime)
-							if remainingTime > 0 {
-								// Set ke blacklist dengan TTL sesuai sisa waktu token
-								Rdb.Set(context.Background(), fmt.Sprintf("blacklist:access_token:%s", jti), "1", remainingTime)
-							}
-						}
-					}
-				}
-			}
+	// Claim access token sudah divalidasi JWTMiddleware
+	if claims, ok := middleware.TokenClaims(r.Context()); ok {
+		// Tambahkan access token ke blacklist dengan TTL sesuai sisa waktu token
+		// (opaque token langsung tidak berlaku karena key-nya dihapus)
+		if err := revokeAccessToken(r.Context(), claims); err != nil {
+			slog.WarnContext(r.Context(), "Error revoking access token", "error", err)
 		}
+		// Refresh token pasangan access token ini ikut dicabut, karena cookie
+		// refresh token dengan Path /refresh tidak dikirim ke endpoint ini
+		revokeRefreshForAccess(r.Context(), claims.ID)
 	}
 
-	// Hapus refresh token dari cookie jika ada
-	cookie, err := r.Cookie("refresh_token")
-	if err == nil {
-		// Parse refresh token to get jti
-		token, err := jwt.Parse(cookie.Value, func(token *jwt.Token) (interface{}, error) {
-			if _, ok := token.Method.(*jwt.SigningMethodRSA); !ok {
-				return nil, jwt.ErrSignatureInvalid
-			}
-			return PublicKey, nil
-		})
-		if err == nil && token.Valid {
-			if claims, ok := token.Claims.(jwt.MapClaims); ok {
-				if jti, ok := claims["jti"].(string); ok {
-					// Hapus refresh token dari Redis
-					Rdb.Del(context.Background(), fmt.Sprintf("refresh_token:%s", jti))
-				}
-			}
+	// Hapus refresh token dari cookie jika ada (cookie hanya terkirim jika COOKIE_PATH=/)
+	if refreshToken, err := Cookies.RefreshToken(r); err == nil {
+		if claims, err := token.Parse(refreshToken, TokenVerifier, token.UseRefresh); err == nil {
+			Rdb.Del(r.Context(), redisclient.Key(fmt.Sprintf("refresh_token:%s", claims.ID)))
 		}
 	}
 
 	// Clear cookie
-	http.SetCookie(w, &http.Cookie{
-		Name:     "refresh_token",
-		Value:    "",
-		HttpOnly: true,
-		Secure:   false,
-		Path:     "/",
-		MaxAge:   -1,
+	Cookies.ClearRefreshToken(w)
+	Cookies.ClearCSRFToken(w)
+
+	audit.Record(r, audit.Entry{
+		Action:       audit.ActionLogout,
+		ResourceType: audit.ResourceUser,
+		ResourceID:   userIDString(r),
 	})
 
 	SendSuccessNoData(w, http.StatusOK, "Logout successful")
 }
+
+func recordFailedLogin(r *http.Request, userID *int, email string) {
+	metrics.LoginsTotal.WithLabelValues(metrics.ResultFailure).Inc()
+
+	resourceID := ""
+	if userID != nil {
+		resourceID = strconv.Itoa(*userID)
+	}
+	audit.Record(r, audit.Entry{
+		ActorID:      userID,
+		Action:       audit.ActionLoginFailed,
+		ResourceType: audit.ResourceUser,
+		ResourceID:   resourceID,
+		After:        map[string]string{"email": email},
+	})
+}
+
+// userIDString mengembalikan user_id dari context (hasil JWTMiddleware) sebagai string
+func userIDString(r *http.Request) string {
+	if userID, ok := middleware.UserID(r.Context()); ok {
+		return strconv.Itoa(userID)
+	}
+	return ""
+}
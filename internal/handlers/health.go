@@ -0,0 +1,111 @@
+package handlers
+
+import (
+	"betest/internal/database"
+	"context"
+	"log/slog"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	healthStatusUp   = "up"
+	healthStatusDown = "down"
+)
+
+// HealthCheckTimeout adalah batas waktu ping per dependency di /readyz, di-set dari main
+var HealthCheckTimeout = 2 * time.Second
+
+// shuttingDown di-set saat signal shutdown diterima supaya /readyz langsung gagal
+// dan load balancer berhenti mengirim traffic sebelum server.Shutdown
+var shuttingDown atomic.Bool
+
+// SetShuttingDown menandai server sedang shutdown
+func SetShuttingDown() {
+	shuttingDown.Store(true)
+}
+
+// DependencyStatus adalah hasil pengecekan satu dependency
+type DependencyStatus struct {
+	Status    string  `json:"status"`
+	LatencyMs float64 `json:"latency_ms"`
+	Error     string  `json:"error,omitempty"`
+}
+
+type ReadinessReport struct {
+	Status       string                      `json:"status"`
+	ShuttingDown bool                        `json:"shutting_down,omitempty"`
+	Dependencies map[string]DependencyStatus `json:"dependencies"`
+}
+
+// Healthz hanya menandakan proses masih hidup, tanpa mengecek dependency
+// supaya gangguan Postgres/Redis tidak membuat container di-restart
+func Healthz(w http.ResponseWriter, r *http.Request) {
+	SendSuccess(w, http.StatusOK, "OK", map[string]string{"status": healthStatusUp})
+}
+
+// Readyz mem-ping Postgres dan Redis secara paralel dan mengembalikan 503 jika salah satu gagal
+// atau server sedang shutdown
+func Readyz(w http.ResponseWriter, r *http.Request) {
+	checks := map[string]func(ctx context.Context) error{
+		"postgres": database.DB.PingContext,
+		"redis": func(ctx context.Context) error {
+			return Rdb.Ping(ctx).Err()
+		},
+	}
+
+	report := ReadinessReport{
+		Status:       healthStatusUp,
+		ShuttingDown: shuttingDown.Load(),
+		Dependencies: make(map[string]DependencyStatus, len(checks)),
+	}
+
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	for name, check := range checks {
+		wg.Go(func() {
+			dep := checkDependency(r.Context(), check)
+			if dep.Status != healthStatusUp {
+				slog.WarnContext(r.Context(), "Readiness check failed", "dependency", name, "error", dep.Error)
+			}
+			mu.Lock()
+			report.Dependencies[name] = dep
+			mu.Unlock()
+		})
+	}
+	wg.Wait()
+
+	for _, dep := range report.Dependencies {
+		if dep.Status != healthStatusUp {
+			report.Status = healthStatusDown
+		}
+	}
+	if report.ShuttingDown {
+		report.Status = healthStatusDown
+	}
+
+	if report.Status != healthStatusUp {
+		SendErrorWithData(w, http.StatusServiceUnavailable, "Service not ready", report)
+		return
+	}
+	SendSuccess(w, http.StatusOK, "Service ready", report)
+}
+
+func checkDependency(ctx context.Context, check func(ctx context.Context) error) DependencyStatus {
+	ctx, cancel := context.WithTimeout(ctx, HealthCheckTimeout)
+	defer cancel()
+
+	start := time.Now()
+	err := check(ctx)
+	dep := DependencyStatus{
+		Status:    healthStatusUp,
+		LatencyMs: float64(time.Since(start).Microseconds()) / 1000,
+	}
+	if err != nil {
+		dep.Status = healthStatusDown
+		dep.Error = err.Error()
+	}
+	return dep
+}
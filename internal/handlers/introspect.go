@@ -0,0 +1,234 @@
+package handlers
+
+import (
+	"betest/internal/audit"
+	"betest/internal/models"
+	"betest/internal/oauth"
+	"betest/internal/redisclient"
+	"betest/internal/token"
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+)
+
+// ServiceAPIKeys adalah API key service internal untuk /introspect dan /revoke, diisi dari main
+var ServiceAPIKeys oauth.APIKeys
+
+// IntrospectionResponse adalah response /introspect (RFC 7662 section 2.2).
+// Token yang tidak aktif hanya berisi {"active": false}.
+type IntrospectionResponse struct {
+	Active    bool     `json:"active"`
+	Scope     string   `json:"scope,omitempty"`
+	ClientID  string   `json:"client_id,omitempty"`
+	TokenType string   `json:"token_type,omitempty"`
+	Exp       int64    `json:"exp,omitempty"`
+	Iat       int64    `json:"iat,omitempty"`
+	Nbf       int64    `json:"nbf,omitempty"`
+	Sub       string   `json:"sub,omitempty"`
+	Aud       []string `json:"aud,omitempty"`
+	Iss       string   `json:"iss,omitempty"`
+	Jti       string   `json:"jti,omitempty"`
+	UserID    int      `json:"user_id,omitempty"`
+}
+
+// tokenCaller adalah pemanggil /introspect dan /revoke: service internal (API key) atau client OAuth2
+type tokenCaller struct {
+	Service string
+	Client  *models.OAuthClient
+}
+
+// name dipakai di audit log untuk mengenali pemanggil
+func (c tokenCaller) name() string {
+	if c.Client != nil {
+		return "client:" + c.Client.ClientID
+	}
+	return "service:" + c.Service
+}
+
+// authenticateTokenCaller mengautentikasi pemanggil dengan header X-API-Key jika ada,
+// selain itu dengan kredensial client OAuth2
+func authenticateTokenCaller(r *http.Request) (tokenCaller, *oauth.Error) {
+	if r.Header.Get(oauth.APIKeyHeader) != "" {
+		service, ok := ServiceAPIKeys.Service(r)
+		if !ok {
+			return tokenCaller{}, oauth.NewError(oauth.ErrInvalidClient, "Invalid API key")
+		}
+		return tokenCaller{Service: service}, nil
+	}
+	client, oerr := authenticateClient(r)
+	if oerr != nil {
+		return tokenCaller{}, oerr
+	}
+	return tokenCaller{Client: &client}, nil
+}
+
+// errInvalidToken dikembalikan parseAnyToken untuk token yang tidak valid, kedaluwarsa atau sudah dicabut
+var errInvalidToken = errors.New("invalid token")
+
+// parseAnyToken mem-parse token sebagai access atau refresh token; token_type_hint menentukan
+// mana yang dicoba lebih dulu (RFC 7009 section 2.1, RFC 7662 section 2.1).
+// Opaque access token di-resolve dari Redis. Token yang tidak valid mengembalikan errInvalidToken;
+// error lain (misalnya Redis tidak bisa dihubungi) berarti status token tidak diketahui.
+func parseAnyToken(ctx context.Context, tokenString, hint string) (*token.Claims, error) {
+	if token.IsOpaque(tokenString) {
+		claims, err := token.LookupOpaque(ctx, Rdb, tokenString)
+		if errors.Is(err, token.ErrOpaqueNotFound) {
+			return nil, errInvalidToken
+		}
+		return claims, err
+	}
+	uses := []string{token.UseAccess, token.UseRefresh}
+	if hint == "refresh_token" {
+		uses = []string{token.UseRefresh, token.UseAccess}
+	}
+	for _, use := range uses {
+		if claims, err := token.Parse(tokenString, TokenVerifier, use); err == nil {
+			return claims, nil
+		}
+	}
+	return nil, errInvalidToken
+}
+
+// tokenActive mengecek status token di Redis: access token tidak di-blacklist,
+// refresh token masih tersimpan (belum di-rotate, logout atau dicabut)
+func tokenActive(ctx context.Context, claims *token.Claims) (bool, error) {
+	if claims.TokenUse == token.UseAccess {
+		n, err := Rdb.Exists(ctx, redisclient.Key(fmt.Sprintf("blacklist:access_token:%s", claims.ID))).Result()
+		return n == 0, err
+	}
+	n, err := Rdb.Exists(ctx, redisclient.Key(fmt.Sprintf("refresh_token:%s", claims.ID))).Result()
+	return n == 1, err
+}
+
+// IntrospectToken adalah token introspection endpoint (RFC 7662) untuk service lain yang menerima
+// token kita: selain signature dan exp, status blacklist dan rotasi di Redis ikut dicek.
+// Pemanggil harus service dengan API key atau confidential client OAuth2.
+func IntrospectToken(w http.ResponseWriter, r *http.Request) {
+	if err := parseForm(w, r); err != nil {
+		sendOAuthError(w, r, oauth.NewError(oauth.ErrInvalidRequest, "Invalid request body"))
+		return
+	}
+
+	caller, oerr := authenticateTokenCaller(r)
+	if oerr != nil {
+		sendOAuthError(w, r, oerr)
+		return
+	}
+	if caller.Client != nil && !caller.Client.Confidential {
+		sendOAuthError(w, r, oauth.NewError(oauth.ErrUnauthorizedClient, "Introspection requires a confidential client"))
+		return
+	}
+
+	tokenString := r.PostForm.Get("token")
+	if tokenString == "" {
+		sendOAuthError(w, r, oauth.NewError(oauth.ErrInvalidRequest, "token is required"))
+		return
+	}
+
+	claims, err := parseAnyToken(r.Context(), tokenString, r.PostForm.Get("token_type_hint"))
+	if errors.Is(err, errInvalidToken) {
+		sendOAuthJSON(w, http.StatusOK, IntrospectionResponse{Active: false})
+		return
+	}
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Error resolving token", "error", err)
+		sendOAuthError(w, r, oauth.NewError(oauth.ErrServerError, ""))
+		return
+	}
+	active, err := tokenActive(r.Context(), claims)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Error checking token status", "error", err)
+		sendOAuthError(w, r, oauth.NewError(oauth.ErrServerError, ""))
+		return
+	}
+	if !active {
+		sendOAuthJSON(w, http.StatusOK, IntrospectionResponse{Active: false})
+		return
+	}
+
+	tokenType := "access_token"
+	if claims.TokenUse == token.UseRefresh {
+		tokenType = "refresh_token"
+	}
+	sendOAuthJSON(w, http.StatusOK, IntrospectionResponse{
+		Active:    true,
+		Scope:     claims.Scope,
+		ClientID:  claims.ClientID,
+		TokenType: tokenType,
+		Exp:       claims.ExpiresAt.Unix(),
+		Iat:       claims.IssuedAt.Unix(),
+		Nbf:       claims.NotBefore.Unix(),
+		Sub:       claims.Subject,
+		Aud:       claims.Audience,
+		Iss:       claims.Issuer,
+		Jti:       claims.ID,
+		UserID:    claims.UserID,
+	})
+}
+
+// RevokeToken adalah token revocation endpoint (RFC 7009) untuk access maupun refresh token.
+// Service dengan API key bisa mencabut token apa pun; client OAuth2 hanya token miliknya sendiri.
+// Token yang tidak valid, sudah kedaluwarsa atau milik client lain diabaikan dan tetap dibalas 200
+// supaya endpoint ini tidak bisa dipakai untuk menebak token.
+func RevokeToken(w http.ResponseWriter, r *http.Request) {
+	if err := parseForm(w, r); err != nil {
+		sendOAuthError(w, r, oauth.NewError(oauth.ErrInvalidRequest, "Invalid request body"))
+		return
+	}
+
+	caller, oerr := authenticateTokenCaller(r)
+	if oerr != nil {
+		sendOAuthError(w, r, oerr)
+		return
+	}
+
+	tokenString := r.PostForm.Get("token")
+	if tokenString == "" {
+		sendOAuthError(w, r, oauth.NewError(oauth.ErrInvalidRequest, "token is required"))
+		return
+	}
+
+	claims, err := parseAnyToken(r.Context(), tokenString, r.PostForm.Get("token_type_hint"))
+	// Status token tidak diketahui, jadi jangan dibalas 200 seolah sudah dicabut
+	if err != nil && !errors.Is(err, errInvalidToken) {
+		slog.ErrorContext(r.Context(), "Error resolving token", "error", err)
+		sendOAuthJSON(w, http.StatusServiceUnavailable, oauth.NewError(oauth.ErrTemporarilyUnavailable, ""))
+		return
+	}
+	if err != nil || (caller.Client != nil && claims.ClientID != caller.Client.ClientID) {
+		sendOAuthJSON(w, http.StatusOK, struct{}{})
+		return
+	}
+
+	if claims.TokenUse == token.UseAccess {
+		err = revokeAccessToken(r.Context(), claims)
+	} else {
+		err = revokeRefreshToken(r.Context(), claims.ID)
+	}
+	// RFC 7009 section 2.2.1: jika token gagal dicabut, pemanggil diberi tahu supaya bisa mencoba lagi
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Error revoking token", "error", err)
+		sendOAuthJSON(w, http.StatusServiceUnavailable, oauth.NewError(oauth.ErrTemporarilyUnavailable, ""))
+		return
+	}
+
+	audit.Record(r, audit.Entry{
+		ActorID:      actorID(claims),
+		Action:       audit.ActionTokenRevoke,
+		ResourceType: audit.ResourceToken,
+		ResourceID:   claims.ID,
+		After:        map[string]string{"caller": caller.name(), "token_use": claims.TokenUse, "client_id": claims.ClientID},
+	})
+
+	sendOAuthJSON(w, http.StatusOK, struct{}{})
+}
+
+// actorID mengembalikan user pemilik token, atau nil untuk token client_credentials
+func actorID(claims *token.Claims) *int {
+	if claims.UserID > 0 {
+		return &claims.UserID
+	}
+	return nil
+}
@@ -0,0 +1,385 @@
+package handlers
+
+import (
+	"betest/internal/audit"
+	"betest/internal/database"
+	"betest/internal/middleware"
+	"betest/internal/models"
+	"betest/internal/oauth"
+	"betest/internal/redisclient"
+	"betest/internal/repository"
+	"betest/internal/token"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+// OAuthTokenResponse adalah response sukses /oauth/token (RFC 6749 section 5.1)
+type OAuthTokenResponse struct {
+	AccessToken  string `json:"access_token"`
+	TokenType    string `json:"token_type"`
+	ExpiresIn    int    `json:"expires_in"`
+	RefreshToken string `json:"refresh_token,omitempty"`
+	Scope        string `json:"scope,omitempty"`
+}
+
+// sendOAuthJSON mengirim response endpoint OAuth2. Response berisi token tidak boleh di-cache.
+func sendOAuthJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.Header().Set("Pragma", "no-cache")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+// sendOAuthError mengirim error RFC 6749 section 5.2. invalid_client dibalas 401 (dengan
+// WWW-Authenticate jika client memakai Basic auth), server_error 500, selain itu 400.
+func sendOAuthError(w http.ResponseWriter, r *http.Request, e *oauth.Error) {
+	status := http.StatusBadRequest
+	switch e.Code {
+	case oauth.ErrInvalidClient:
+		status = http.StatusUnauthorized
+		if _, _, ok := r.BasicAuth(); ok {
+			w.Header().Set("WWW-Authenticate", `Basic realm="oauth"`)
+		}
+	case oauth.ErrServerError:
+		status = http.StatusInternalServerError
+	}
+	sendOAuthJSON(w, status, e)
+}
+
+// authenticateClient mengautentikasi client dari request /oauth/token, /oauth/introspect dan /oauth/revoke.
+// Confidential client wajib mengirim secret yang benar; public client hanya mengirim client_id.
+func authenticateClient(r *http.Request) (models.OAuthClient, *oauth.Error) {
+	clientID, secret, err := oauth.ClientCredentials(r)
+	if err != nil || clientID == "" {
+		return models.OAuthClient{}, oauth.NewError(oauth.ErrInvalidClient, "Client authentication failed")
+	}
+
+	client, err := getOAuthClient(r.Context(), clientID)
+	if err == sql.ErrNoRows {
+		return client, oauth.NewError(oauth.ErrInvalidClient, "Client authentication failed")
+	}
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Error getting oauth client", "client_id", clientID, "error", err)
+		return client, oauth.NewError(oauth.ErrServerError, "")
+	}
+
+	if client.Confidential {
+		if secret == "" || !oauth.VerifySecret(secret, client.SecretHash) {
+			return client, oauth.NewError(oauth.ErrInvalidClient, "Client authentication failed")
+		}
+	} else if secret != "" {
+		return client, oauth.NewError(oauth.ErrInvalidClient, "Public clients must not send a client secret")
+	}
+	return client, nil
+}
+
+// OAuthAuthorize menerbitkan authorization code untuk client atas nama user yang sedang login.
+// Endpoint ini dipanggil halaman consent di frontend dengan access token first-party user
+// (GET atau POST dengan parameter RFC 6749 section 4.1.1 + PKCE). Karena response API berupa JSON,
+// redirect tidak dilakukan server: frontend mengarahkan browser ke data.redirect_uri.
+// client_id atau redirect_uri yang tidak valid dibalas 400 tanpa redirect (RFC 6749 section 4.1.2.1).
+func OAuthAuthorize(w http.ResponseWriter, r *http.Request) {
+	if err := parseForm(w, r); err != nil {
+		SendError(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	// Token OAuth2 tidak boleh dipakai untuk memberi akses ke client lain
+	claims, ok := middleware.TokenClaims(r.Context())
+	if !ok || claims.ClientID != "" || claims.UserID <= 0 {
+		SendError(w, http.StatusForbidden, "Forbidden")
+		return
+	}
+
+	client, err := getOAuthClient(r.Context(), r.Form.Get("client_id"))
+	if err == sql.ErrNoRows {
+		SendError(w, http.StatusBadRequest, "Invalid client_id")
+		return
+	}
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Error getting oauth client", "error", err)
+		SendError(w, http.StatusInternalServerError, "Error authorizing client")
+		return
+	}
+
+	// redirect_uri harus sama persis dengan yang terdaftar; boleh dikosongkan jika hanya ada satu
+	redirectURI := r.Form.Get("redirect_uri")
+	if redirectURI == "" && len(client.RedirectURIs) == 1 {
+		redirectURI = client.RedirectURIs[0]
+	}
+	if !slices.Contains(client.RedirectURIs, redirectURI) {
+		SendError(w, http.StatusBadRequest, "Invalid redirect_uri")
+		return
+	}
+
+	// Mulai dari sini error dikirim ke client lewat redirect_uri
+	state := r.Form.Get("state")
+	redirect := func(params url.Values) {
+		SendSuccess(w, http.StatusOK, "Authorization processed", map[string]string{
+			"redirect_uri": authorizeRedirectURI(redirectURI, state, params),
+		})
+	}
+	redirectError := func(code, description string) {
+		params := url.Values{"error": {code}}
+		if description != "" {
+			params.Set("error_description", description)
+		}
+		redirect(params)
+	}
+
+	if r.Form.Get("response_type") != "code" {
+		redirectError(oauth.ErrUnsupportedResponseType, "response_type must be code")
+		return
+	}
+	if !slices.Contains(client.GrantTypes, oauth.GrantAuthorizationCode) {
+		redirectError(oauth.ErrUnauthorizedClient, "Client is not allowed to use authorization_code")
+		return
+	}
+	// PKCE wajib untuk semua client (OAuth 2.1), hanya S256
+	challenge := r.Form.Get("code_challenge")
+	if r.Form.Get("code_challenge_method") != oauth.MethodS256 || !oauth.ValidChallenge(challenge) {
+		redirectError(oauth.ErrInvalidRequest, "code_challenge with code_challenge_method=S256 is required")
+		return
+	}
+
+	// Scope yang diminta harus terdaftar untuk client, lalu dipersempit sesuai role user
+	requested := oauth.ParseScope(r.Form.Get("scope"))
+	if len(requested) == 0 {
+		requested = client.Scopes
+	}
+	if !oauth.Subset(requested, client.Scopes) {
+		redirectError(oauth.ErrInvalidScope, "Requested scope is not allowed for this client")
+		return
+	}
+	ctx, cancel := database.WithTimeout(r.Context())
+	defer cancel()
+	role, err := repository.GetUserRole(ctx, database.DB, claims.UserID)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Error getting user role", "error", err)
+		redirectError(oauth.ErrServerError, "")
+		return
+	}
+	granted := oauth.Intersect(requested, oauth.RoleScopes(role))
+	if len(granted) == 0 {
+		redirectError(oauth.ErrAccessDenied, "User cannot grant any of the requested scopes")
+		return
+	}
+	scope := oauth.FormatScope(granted)
+
+	code, err := oauth.SaveCode(r.Context(), Rdb, oauth.Code{
+		ClientID:            client.ClientID,
+		UserID:              claims.UserID,
+		RedirectURI:         redirectURI,
+		RedirectURIProvided: r.Form.Get("redirect_uri") != "",
+		Scope:               scope,
+		CodeChallenge:       challenge,
+	})
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Error saving authorization code", "error", err)
+		redirectError(oauth.ErrServerError, "")
+		return
+	}
+
+	audit.Record(r, audit.Entry{
+		Action:       audit.ActionOAuthAuthorize,
+		ResourceType: audit.ResourceOAuthClient,
+		ResourceID:   strconv.Itoa(client.ID),
+		After:        map[string]string{"client_id": client.ClientID, "scope": scope},
+	})
+
+	redirect(url.Values{"code": {code}})
+}
+
+// authorizeRedirectURI menambahkan params dan state ke query redirect_uri yang terdaftar
+func authorizeRedirectURI(redirectURI, state string, params url.Values) string {
+	u, _ := url.Parse(redirectURI)
+	q := u.Query()
+	for k, v := range params {
+		q[k] = v
+	}
+	if state != "" {
+		q.Set("state", state)
+	}
+	u.RawQuery = q.Encode()
+	return u.String()
+}
+
+// OAuthToken adalah token endpoint (RFC 6749 section 3.2) untuk grant authorization_code,
+// refresh_token dan client_credentials. Parameter dikirim sebagai application/x-www-form-urlencoded.
+func OAuthToken(w http.ResponseWriter, r *http.Request) {
+	if err := parseForm(w, r); err != nil {
+		sendOAuthError(w, r, oauth.NewError(oauth.ErrInvalidRequest, "Invalid request body"))
+		return
+	}
+
+	client, oerr := authenticateClient(r)
+	if oerr != nil {
+		sendOAuthError(w, r, oerr)
+		return
+	}
+
+	grantType := r.PostForm.Get("grant_type")
+	if !slices.Contains(oauth.GrantTypes, grantType) {
+		sendOAuthError(w, r, oauth.NewError(oauth.ErrUnsupportedGrantType, ""))
+		return
+	}
+	if !slices.Contains(client.GrantTypes, grantType) {
+		sendOAuthError(w, r, oauth.NewError(oauth.ErrUnauthorizedClient, "Client is not allowed to use "+grantType))
+		return
+	}
+
+	var resp OAuthTokenResponse
+	switch grantType {
+	case oauth.GrantAuthorizationCode:
+		resp, oerr = exchangeAuthorizationCode(r, client)
+	case oauth.GrantRefreshToken:
+		resp, oerr = exchangeRefreshToken(r, client)
+	case oauth.GrantClientCredentials:
+		resp, oerr = exchangeClientCredentials(r, client)
+	}
+	if oerr != nil {
+		sendOAuthError(w, r, oerr)
+		return
+	}
+
+	sendOAuthJSON(w, http.StatusOK, resp)
+}
+
+// exchangeAuthorizationCode menukar code (sekali pakai) dan code_verifier PKCE dengan token.
+// Refresh token hanya diterbitkan jika client juga terdaftar untuk grant refresh_token.
+func exchangeAuthorizationCode(r *http.Request, client models.OAuthClient) (OAuthTokenResponse, *oauth.Error) {
+	code := r.PostForm.Get("code")
+	if code == "" {
+		return OAuthTokenResponse{}, oauth.NewError(oauth.ErrInvalidRequest, "code is required")
+	}
+
+	c, err := oauth.ConsumeCode(r.Context(), Rdb, code)
+	if err == redis.Nil {
+		return OAuthTokenResponse{}, oauth.NewError(oauth.ErrInvalidGrant, "Invalid or expired code")
+	}
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Error consuming authorization code", "error", err)
+		return OAuthTokenResponse{}, oauth.NewError(oauth.ErrServerError, "")
+	}
+	if c.ClientID != client.ClientID {
+		return OAuthTokenResponse{}, oauth.NewError(oauth.ErrInvalidGrant, "Code was issued to another client")
+	}
+	// redirect_uri yang dikirim ke /oauth/authorize wajib diulang persis; jika saat itu dikosongkan,
+	// client tetap boleh mengirimnya asalkan sama
+	redirectURI := r.PostForm.Get("redirect_uri")
+	if (c.RedirectURIProvided || redirectURI != "") && redirectURI != c.RedirectURI {
+		return OAuthTokenResponse{}, oauth.NewError(oauth.ErrInvalidGrant, "redirect_uri does not match")
+	}
+	if !oauth.VerifyPKCE(r.PostForm.Get("code_verifier"), c.CodeChallenge) {
+		return OAuthTokenResponse{}, oauth.NewError(oauth.ErrInvalidGrant, "Invalid code_verifier")
+	}
+
+	g := tokenGrant{UserID: c.UserID, ClientID: client.ClientID, Scope: c.Scope, AuthTime: time.Now()}
+	if !slices.Contains(client.GrantTypes, oauth.GrantRefreshToken) {
+		return issueAccessToken(r.Context(), g)
+	}
+	pair, err := generateTokens(r.Context(), g)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Error generating oauth tokens", "error", err)
+		return OAuthTokenResponse{}, oauth.NewError(oauth.ErrServerError, "")
+	}
+	return tokenResponse(pair.Access, pair.Refresh, g.Scope, pair.AccessTTL), nil
+}
+
+// exchangeRefreshToken me-rotate refresh token milik client. Scope access token boleh dipersempit, tidak boleh
+// diperluas; refresh token baru selalu memakai scope refresh token lama (RFC 6749 section 6).
+func exchangeRefreshToken(r *http.Request, client models.OAuthClient) (OAuthTokenResponse, *oauth.Error) {
+	claims, err := token.Parse(r.PostForm.Get("refresh_token"), TokenVerifier, token.UseRefresh)
+	if err != nil || claims.ClientID != client.ClientID {
+		return OAuthTokenResponse{}, oauth.NewError(oauth.ErrInvalidGrant, "Invalid refresh token")
+	}
+
+	// Sama seperti /refresh, rotasi tidak bisa memperpanjang sesi melewati umur maksimalnya
+	g := refreshGrant(claims)
+	scope := g.Scope
+	if requested := oauth.ParseScope(r.PostForm.Get("scope")); len(requested) > 0 {
+		if !oauth.Subset(requested, oauth.ParseScope(claims.Scope)) {
+			return OAuthTokenResponse{}, oauth.NewError(oauth.ErrInvalidScope, "Requested scope exceeds the original grant")
+		}
+		scope = oauth.FormatScope(requested)
+		g.AccessScope = scope
+	}
+	if g.sessionExpired() {
+		return OAuthTokenResponse{}, oauth.NewError(oauth.ErrInvalidGrant, "Session expired")
+	}
+	pair, err := signTokenPair(r.Context(), g)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Error signing oauth tokens", "error", err)
+		return OAuthTokenResponse{}, oauth.NewError(oauth.ErrServerError, "")
+	}
+	status, err := rotateRefreshToken(r.Context(), claims.ID, claims.UserID, pair)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Error rotating oauth refresh token", "error", err)
+		return OAuthTokenResponse{}, oauth.NewError(oauth.ErrServerError, "")
+	}
+	if status == rotateReused {
+		audit.Record(r, audit.Entry{
+			ActorID:      &claims.UserID,
+			Action:       audit.ActionRefreshReused,
+			ResourceType: audit.ResourceUser,
+			ResourceID:   strconv.Itoa(claims.UserID),
+			After:        map[string]string{"refresh_jti": claims.ID, "client_id": client.ClientID},
+		})
+	}
+	if status != rotateOK {
+		return OAuthTokenResponse{}, oauth.NewError(oauth.ErrInvalidGrant, "Invalid refresh token")
+	}
+	return tokenResponse(pair.Access, pair.Refresh, scope, pair.AccessTTL), nil
+}
+
+// exchangeClientCredentials menerbitkan access token untuk client itu sendiri (tanpa user dan tanpa refresh token)
+func exchangeClientCredentials(r *http.Request, client models.OAuthClient) (OAuthTokenResponse, *oauth.Error) {
+	if !client.Confidential {
+		return OAuthTokenResponse{}, oauth.NewError(oauth.ErrUnauthorizedClient, "client_credentials requires a confidential client")
+	}
+
+	requested := oauth.ParseScope(r.PostForm.Get("scope"))
+	if len(requested) == 0 {
+		requested = client.Scopes
+	}
+	if !oauth.Subset(requested, client.Scopes) {
+		return OAuthTokenResponse{}, oauth.NewError(oauth.ErrInvalidScope, "Requested scope is not allowed for this client")
+	}
+
+	return issueAccessToken(r.Context(), tokenGrant{ClientID: client.ClientID, Scope: oauth.FormatScope(requested)})
+}
+
+// issueAccessToken menerbitkan access token saja (tanpa refresh token) dan menyimpannya di Redis
+func issueAccessToken(ctx context.Context, g tokenGrant) (OAuthTokenResponse, *oauth.Error) {
+	ttl := g.accessTTL()
+	access, jti, session, err := signAccessToken(ctx, g)
+	if err == nil {
+		err = Rdb.Set(ctx, redisclient.Key(fmt.Sprintf("access_token:%s", jti)), session, ttl).Err()
+	}
+	if err != nil {
+		slog.ErrorContext(ctx, "Error issuing oauth access token", "error", err)
+		return OAuthTokenResponse{}, oauth.NewError(oauth.ErrServerError, "")
+	}
+	return tokenResponse(access, "", g.Scope, ttl), nil
+}
+
+func tokenResponse(access, refresh, scope string, expiresIn time.Duration) OAuthTokenResponse {
+	return OAuthTokenResponse{
+		AccessToken:  access,
+		TokenType:    "Bearer",
+		ExpiresIn:    int(expiresIn.Seconds()),
+		RefreshToken: refresh,
+		Scope:        scope,
+	}
+}
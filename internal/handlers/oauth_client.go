@@ -0,0 +1,254 @@
+package handlers
+
+import (
+	"betest/internal/audit"
+	"betest/internal/database"
+	"betest/internal/models"
+	"betest/internal/oauth"
+	"betest/internal/response"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"slices"
+	"strconv"
+
+	"github.com/gorilla/mux"
+)
+
+type OAuthClientRequest struct {
+	Name         string   `json:"name"`
+	RedirectURIs []string `json:"redirect_uris"`
+	GrantTypes   []string `json:"grant_types"`
+	Scopes       []string `json:"scopes"`
+	Confidential *bool    `json:"confidential"` // Default true; false untuk public client (SPA, mobile)
+}
+
+func (req *OAuthClientRequest) validate(confidential bool) error {
+	if req.Name == "" {
+		return fmt.Errorf("Name is required")
+	}
+	if len(req.GrantTypes) == 0 {
+		return fmt.Errorf("At least one grant type is required")
+	}
+	for _, g := range req.GrantTypes {
+		if !slices.Contains(oauth.GrantTypes, g) {
+			return fmt.Errorf("Unsupported grant type %q", g)
+		}
+	}
+	if slices.Contains(req.GrantTypes, oauth.GrantAuthorizationCode) && len(req.RedirectURIs) == 0 {
+		return fmt.Errorf("authorization_code requires at least one redirect uri")
+	}
+	// Public client tidak bisa menyimpan secret, jadi tidak bisa mengautentikasi dirinya sendiri
+	if slices.Contains(req.GrantTypes, oauth.GrantClientCredentials) && !confidential {
+		return fmt.Errorf("client_credentials requires a confidential client")
+	}
+	for _, u := range req.RedirectURIs {
+		if err := oauth.ValidRedirectURI(u); err != nil {
+			return fmt.Errorf("Invalid redirect uri %q: %v", u, err)
+		}
+	}
+	for _, s := range req.Scopes {
+		if !slices.Contains(oauth.Scopes, s) {
+			return fmt.Errorf("Unsupported scope %q", s)
+		}
+	}
+	return nil
+}
+
+const oauthClientColumns = "id, client_id, client_secret_hash, name, redirect_uris, grant_types, scopes, confidential, created_at, updated_at"
+
+func scanOAuthClient(row interface{ Scan(...interface{}) error }) (models.OAuthClient, error) {
+	var c models.OAuthClient
+	var secretHash sql.NullString
+	var redirectURIs, grantTypes, scopes []byte
+	err := row.Scan(&c.ID, &c.ClientID, &secretHash, &c.Name, &redirectURIs, &grantTypes, &scopes, &c.Confidential, &c.CreatedAt, &c.UpdatedAt)
+	if err != nil {
+		return c, err
+	}
+	c.SecretHash = secretHash.String
+	for _, f := range []struct {
+		data []byte
+		dst  *[]string
+	}{{redirectURIs, &c.RedirectURIs}, {grantTypes, &c.GrantTypes}, {scopes, &c.Scopes}} {
+		if err := json.Unmarshal(f.data, f.dst); err != nil {
+			return c, err
+		}
+	}
+	return c, nil
+}
+
+// getOAuthClient mengambil client berdasarkan client_id (bukan id internal). Membaca primary
+// supaya client yang baru dibuat atau dihapus langsung berlaku.
+func getOAuthClient(ctx context.Context, clientID string) (models.OAuthClient, error) {
+	ctx, cancel := database.WithTimeout(ctx)
+	defer cancel()
+
+	return scanOAuthClient(database.DB.QueryRowContext(ctx, "SELECT "+oauthClientColumns+" FROM oauth_clients WHERE client_id=$1", clientID))
+}
+
+func GetOAuthClients(w http.ResponseWriter, r *http.Request) {
+	page, limit, err := parsePagination(r)
+	if err != nil {
+		SendError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	ctx, cancel := database.WithTimeout(r.Context())
+	defer cancel()
+
+	// Count dan list membaca dari replica yang sama supaya konsisten
+	db := database.Reader()
+
+	var total int
+	if err := db.QueryRowContext(ctx, "SELECT COUNT(*) FROM oauth_clients").Scan(&total); err != nil {
+		slog.ErrorContext(r.Context(), "Error counting oauth clients", "error", err)
+		SendError(w, http.StatusInternalServerError, "Error fetching oauth clients count")
+		return
+	}
+
+	rows, err := db.QueryContext(ctx, "SELECT "+oauthClientColumns+" FROM oauth_clients ORDER BY id LIMIT $1 OFFSET $2", limit, (page-1)*limit)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Error fetching oauth clients", "error", err)
+		SendError(w, http.StatusInternalServerError, "Error fetching oauth clients")
+		return
+	}
+	defer rows.Close()
+
+	clients := []models.OAuthClient{}
+	for rows.Next() {
+		c, err := scanOAuthClient(rows)
+		if err != nil {
+			slog.ErrorContext(r.Context(), "Error scanning oauth client", "error", err)
+			SendError(w, http.StatusInternalServerError, "Error scanning oauth client data")
+			return
+		}
+		clients = append(clients, c)
+	}
+
+	response.SendPaginatedSuccess(w, http.StatusOK, "OAuth clients retrieved successfully", clients, paginationMeta(page, limit, total))
+}
+
+func GetOAuthClient(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		SendError(w, http.StatusBadRequest, "Invalid ID")
+		return
+	}
+
+	ctx, cancel := database.WithTimeout(r.Context())
+	defer cancel()
+
+	c, err := scanOAuthClient(database.Reader().QueryRowContext(ctx, "SELECT "+oauthClientColumns+" FROM oauth_clients WHERE id=$1", id))
+	if err == sql.ErrNoRows {
+		SendError(w, http.StatusNotFound, "OAuth client not found")
+		return
+	}
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Error getting oauth client", "oauth_client_id", id, "error", err)
+		SendError(w, http.StatusInternalServerError, "Error fetching oauth client")
+		return
+	}
+
+	SendSuccess(w, http.StatusOK, "OAuth client retrieved successfully", c)
+}
+
+// CreateOAuthClient mendaftarkan client OAuth2. client_id dan client_secret dibuat otomatis;
+// secret (hanya untuk confidential client) hanya dikembalikan di response ini.
+func CreateOAuthClient(w http.ResponseWriter, r *http.Request) {
+	var req OAuthClientRequest
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+	confidential := true
+	if req.Confidential != nil {
+		confidential = *req.Confidential
+	}
+	if err := req.validate(confidential); err != nil {
+		SendError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if req.RedirectURIs == nil {
+		req.RedirectURIs = []string{}
+	}
+	if req.Scopes == nil {
+		req.Scopes = []string{}
+	}
+
+	clientID, err := oauth.GenerateClientID()
+	if err != nil {
+		SendError(w, http.StatusInternalServerError, "Error generating client id")
+		return
+	}
+	var secret, secretHash string
+	if confidential {
+		secret, err = oauth.GenerateSecret()
+		if err != nil {
+			SendError(w, http.StatusInternalServerError, "Error generating secret")
+			return
+		}
+		secretHash = oauth.HashSecret(secret)
+	}
+
+	ctx, cancel := database.WithTimeout(r.Context())
+	defer cancel()
+
+	redirectURIsJSON, _ := json.Marshal(req.RedirectURIs)
+	grantTypesJSON, _ := json.Marshal(req.GrantTypes)
+	scopesJSON, _ := json.Marshal(req.Scopes)
+	c, err := scanOAuthClient(database.DB.QueryRowContext(ctx,
+		`INSERT INTO oauth_clients (client_id, client_secret_hash, name, redirect_uris, grant_types, scopes, confidential)
+		VALUES ($1, NULLIF($2, ''), $3, $4, $5, $6, $7) RETURNING `+oauthClientColumns,
+		clientID, secretHash, req.Name, string(redirectURIsJSON), string(grantTypesJSON), string(scopesJSON), confidential))
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Error creating oauth client", "error", err)
+		SendError(w, http.StatusInternalServerError, "Error creating oauth client")
+		return
+	}
+
+	audit.Record(r, audit.Entry{
+		Action:       audit.ActionOAuthClientCreate,
+		ResourceType: audit.ResourceOAuthClient,
+		ResourceID:   strconv.Itoa(c.ID),
+		After:        c,
+	})
+
+	c.ClientSecret = secret
+
+	SendSuccess(w, http.StatusCreated, "OAuth client created successfully", c)
+}
+
+// DeleteOAuthClient menghapus client. Token yang sudah diterbitkan tetap berlaku sampai kedaluwarsa,
+// tapi refresh token-nya tidak bisa dipakai lagi karena client tidak lagi ditemukan di /oauth/token.
+func DeleteOAuthClient(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		SendError(w, http.StatusBadRequest, "Invalid ID")
+		return
+	}
+
+	ctx, cancel := database.WithTimeout(r.Context())
+	defer cancel()
+
+	before, err := scanOAuthClient(database.DB.QueryRowContext(ctx, "DELETE FROM oauth_clients WHERE id=$1 RETURNING "+oauthClientColumns, id))
+	if err == sql.ErrNoRows {
+		SendError(w, http.StatusNotFound, "OAuth client not found")
+		return
+	}
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Error deleting oauth client", "oauth_client_id", id, "error", err)
+		SendError(w, http.StatusInternalServerError, "Error deleting oauth client")
+		return
+	}
+
+	audit.Record(r, audit.Entry{
+		Action:       audit.ActionOAuthClientDelete,
+		ResourceType: audit.ResourceOAuthClient,
+		ResourceID:   strconv.Itoa(id),
+		Before:       before,
+	})
+
+	SendSuccessNoData(w, http.StatusOK, "OAuth client deleted successfully")
+}
@@ -0,0 +1,499 @@
+package handlers
+
+import (
+	"betest/internal/config"
+	"betest/internal/middleware"
+	"betest/internal/models"
+	"betest/internal/oauth"
+	"betest/internal/token"
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/alicebob/miniredis/v2"
+	"github.com/go-redis/redis/v8"
+)
+
+const (
+	testClientSecret = "cs_test_secret"
+	testRedirectURI  = "https://app.example.com/callback"
+	testVerifier     = "dBjftJeZ4CVP-mB92K27uhbUJU1p1r_wW1gFWFOEjXk-verifier"
+)
+
+// setupOAuth memasang miniredis, key HS256 dan sqlmock untuk handler dan JWTMiddleware
+func setupOAuth(t *testing.T) (*miniredis.Miniredis, sqlmock.Sqlmock) {
+	t.Helper()
+	mr := miniredis.RunT(t)
+	keys, err := token.NewKeys(config.JWTConfig{Algorithm: "HS256", Secret: "0123456789abcdef0123456789abcdef"})
+	if err != nil {
+		t.Fatalf("NewKeys: %v", err)
+	}
+
+	prevRdb, prevMwRdb := Rdb, middleware.Rdb
+	prevSigner, prevVerifier, prevMwVerifier := TokenSigner, TokenVerifier, middleware.TokenVerifier
+	Rdb = redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	middleware.Rdb = Rdb
+	TokenSigner, TokenVerifier, middleware.TokenVerifier = keys, keys, keys
+	t.Cleanup(func() {
+		Rdb.Close()
+		Rdb, middleware.Rdb = prevRdb, prevMwRdb
+		TokenSigner, TokenVerifier, middleware.TokenVerifier = prevSigner, prevVerifier, prevMwVerifier
+	})
+	return mr, mockDB(t)
+}
+
+func testClient(confidential bool, grantTypes ...string) models.OAuthClient {
+	c := models.OAuthClient{
+		ID:           3,
+		ClientID:     "client-test",
+		Name:         "Reports",
+		RedirectURIs: []string{testRedirectURI},
+		GrantTypes:   grantTypes,
+		Scopes:       []string{oauth.ScopeUsersRead, oauth.ScopeUsersWrite, oauth.ScopeAdmin},
+		Confidential: confidential,
+	}
+	if confidential {
+		c.SecretHash = oauth.HashSecret(testClientSecret)
+	}
+	return c
+}
+
+func expectClient(mock sqlmock.Sqlmock, c models.OAuthClient) {
+	redirectURIs, _ := json.Marshal(c.RedirectURIs)
+	grantTypes, _ := json.Marshal(c.GrantTypes)
+	scopes, _ := json.Marshal(c.Scopes)
+	var secretHash interface{}
+	if c.SecretHash != "" {
+		secretHash = c.SecretHash
+	}
+	now := time.Now()
+	mock.ExpectQuery(`FROM oauth_clients WHERE client_id=\$1`).
+		WithArgs(c.ClientID).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "client_id", "client_secret_hash", "name", "redirect_uris", "grant_types", "scopes", "confidential", "created_at", "updated_at"}).
+			AddRow(c.ID, c.ClientID, secretHash, c.Name, redirectURIs, grantTypes, scopes, c.Confidential, now, now))
+}
+
+func pkceChallenge(verifier string) string {
+	sum := sha256.Sum256([]byte(verifier))
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
+
+// userAccessToken menerbitkan access token first-party untuk halaman consent
+func userAccessToken(t *testing.T, userID int) string {
+	t.Helper()
+	signed, err := TokenSigner.Sign(token.NewClaims(userID, token.UseAccess, time.Minute))
+	if err != nil {
+		t.Fatalf("Sign: %v", err)
+	}
+	return signed
+}
+
+// authorize memanggil /oauth/authorize lewat JWTMiddleware dan mengembalikan query redirect_uri
+func authorize(t *testing.T, accessToken string, params url.Values) url.Values {
+	t.Helper()
+	req := httptest.NewRequest(http.MethodPost, "/oauth/authorize", strings.NewReader(params.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Authorization", "Bearer "+accessToken)
+	rec := httptest.NewRecorder()
+	middleware.JWTMiddleware(http.HandlerFunc(OAuthAuthorize)).ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("authorize status = %d; body %s", rec.Code, rec.Body)
+	}
+
+	var resp struct {
+		Data struct {
+			RedirectURI string `json:"redirect_uri"`
+		} `json:"data"`
+	}
+	if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+		t.Fatalf("decode authorize response: %v", err)
+	}
+	u, err := url.Parse(resp.Data.RedirectURI)
+	if err != nil || !strings.HasPrefix(resp.Data.RedirectURI, testRedirectURI) {
+		t.Fatalf("redirect_uri = %q; want prefix %s", resp.Data.RedirectURI, testRedirectURI)
+	}
+	return u.Query()
+}
+
+func authorizeParams(scope string) url.Values {
+	return url.Values{
+		"response_type":         {"code"},
+		"client_id":             {"client-test"},
+		"redirect_uri":          {testRedirectURI},
+		"scope":                 {scope},
+		"state":                 {"xyz"},
+		"code_challenge":        {pkceChallenge(testVerifier)},
+		"code_challenge_method": {oauth.MethodS256},
+	}
+}
+
+type oauthTokenResult struct {
+	OAuthTokenResponse
+	Error string `json:"error"`
+}
+
+// postToken memanggil /oauth/token; confidential client memakai client_secret_basic
+func postToken(t *testing.T, client models.OAuthClient, form url.Values) (int, oauthTokenResult) {
+	t.Helper()
+	if !client.Confidential {
+		form.Set("client_id", client.ClientID)
+	}
+	req := httptest.NewRequest(http.MethodPost, "/oauth/token", strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	if client.Confidential {
+		req.SetBasicAuth(client.ClientID, testClientSecret)
+	}
+	rec := httptest.NewRecorder()
+	OAuthToken(rec, req)
+
+	var resp oauthTokenResult
+	if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+		t.Fatalf("decode token response: %v", err)
+	}
+	return rec.Code, resp
+}
+
+func parseTestToken(t *testing.T, signed, use string) *token.Claims {
+	t.Helper()
+	claims, err := token.Parse(signed, TokenVerifier, use)
+	if err != nil {
+		t.Fatalf("Parse(%s): %v", use, err)
+	}
+	return claims
+}
+
+func TestOAuthAuthorizationCodeFlow(t *testing.T) {
+	_, mock := setupOAuth(t)
+	client := testClient(false, oauth.GrantAuthorizationCode, oauth.GrantRefreshToken)
+
+	// Role user tidak boleh memberi scope admin, jadi scope dipersempit ke users:read
+	expectClient(mock, client)
+	mock.ExpectQuery(`SELECT role FROM users WHERE id=\$1`).WithArgs(42).
+		WillReturnRows(sqlmock.NewRows([]string{"role"}).AddRow("user"))
+	mock.ExpectExec(`INSERT INTO audit_log`).WillReturnResult(sqlmock.NewResult(1, 1))
+	q := authorize(t, userAccessToken(t, 42), authorizeParams("users:read admin"))
+	code := q.Get("code")
+	if code == "" || q.Get("state") != "xyz" {
+		t.Fatalf("redirect query = %v; want code and state", q)
+	}
+
+	exchange := url.Values{
+		"grant_type":    {oauth.GrantAuthorizationCode},
+		"code":          {code},
+		"redirect_uri":  {testRedirectURI},
+		"code_verifier": {testVerifier},
+	}
+	expectClient(mock, client)
+	status, resp := postToken(t, client, exchange)
+	if status != http.StatusOK {
+		t.Fatalf("token status = %d (%s)", status, resp.Error)
+	}
+	if resp.Scope != oauth.ScopeUsersRead || resp.RefreshToken == "" {
+		t.Errorf("response = %+v; want scope users:read with a refresh token", resp.OAuthTokenResponse)
+	}
+	access := parseTestToken(t, resp.AccessToken, token.UseAccess)
+	if access.UserID != 42 || access.ClientID != client.ClientID || access.Scope != oauth.ScopeUsersRead {
+		t.Errorf("access claims = %+v", access)
+	}
+
+	// Code sekali pakai (GETDEL), penukaran kedua ditolak
+	expectClient(mock, client)
+	if status, resp := postToken(t, client, exchange); status != http.StatusBadRequest || resp.Error != oauth.ErrInvalidGrant {
+		t.Errorf("second exchange = %d %q; want 400 invalid_grant", status, resp.Error)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestOAuthAuthorizeRejects(t *testing.T) {
+	client := testClient(false, oauth.GrantAuthorizationCode)
+	tests := []struct {
+		name      string
+		params    func(url.Values)
+		role      string // Kosong jika tidak sampai membaca role user
+		wantError string
+	}{
+		{"plain PKCE method", func(p url.Values) { p.Set("code_challenge_method", "plain") }, "", oauth.ErrInvalidRequest},
+		{"missing code_challenge", func(p url.Values) { p.Del("code_challenge") }, "", oauth.ErrInvalidRequest},
+		{"invalid code_challenge", func(p url.Values) { p.Set("code_challenge", "too-short") }, "", oauth.ErrInvalidRequest},
+		{"wrong response_type", func(p url.Values) { p.Set("response_type", "token") }, "", oauth.ErrUnsupportedResponseType},
+		{"unknown scope", func(p url.Values) { p.Set("scope", "users:read payments") }, "", oauth.ErrInvalidScope},
+		{"no scope the user can grant", func(p url.Values) { p.Set("scope", "admin") }, "user", oauth.ErrAccessDenied},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mr, mock := setupOAuth(t)
+			expectClient(mock, client)
+			if tt.role != "" {
+				mock.ExpectQuery(`SELECT role FROM users`).WillReturnRows(sqlmock.NewRows([]string{"role"}).AddRow(tt.role))
+			}
+
+			params := authorizeParams("users:read")
+			tt.params(params)
+			q := authorize(t, userAccessToken(t, 42), params)
+			if q.Get("error") != tt.wantError || q.Get("code") != "" || q.Get("state") != "xyz" {
+				t.Errorf("redirect query = %v; want error %s", q, tt.wantError)
+			}
+			if keys := mr.Keys(); len(keys) != 0 {
+				t.Errorf("redis keys = %v; want no authorization code", keys)
+			}
+			if err := mock.ExpectationsWereMet(); err != nil {
+				t.Error(err)
+			}
+		})
+	}
+}
+
+func TestOAuthAuthorizeRejectsUnregisteredRedirectURI(t *testing.T) {
+	_, mock := setupOAuth(t)
+	expectClient(mock, testClient(false, oauth.GrantAuthorizationCode))
+
+	params := authorizeParams("users:read")
+	params.Set("redirect_uri", "https://evil.example.com/callback")
+	req := httptest.NewRequest(http.MethodPost, "/oauth/authorize", strings.NewReader(params.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Authorization", "Bearer "+userAccessToken(t, 42))
+	rec := httptest.NewRecorder()
+	middleware.JWTMiddleware(http.HandlerFunc(OAuthAuthorize)).ServeHTTP(rec, req)
+
+	// Tidak boleh redirect ke URI yang tidak terdaftar
+	if rec.Code != http.StatusBadRequest || strings.Contains(rec.Body.String(), "evil.example.com") {
+		t.Errorf("status = %d, body %s; want 400 without redirect", rec.Code, rec.Body)
+	}
+}
+
+func TestOAuthCodeExchange(t *testing.T) {
+	tests := []struct {
+		name        string
+		code        oauth.Code
+		form        url.Values
+		wantStatus  int
+		wantError   string
+		wantRefresh bool
+	}{
+		{
+			name:       "redirect_uri sent at authorize and repeated",
+			code:       oauth.Code{RedirectURI: testRedirectURI, RedirectURIProvided: true},
+			form:       url.Values{"redirect_uri": {testRedirectURI}, "code_verifier": {testVerifier}},
+			wantStatus: http.StatusOK,
+		},
+		{
+			name:       "redirect_uri sent at authorize but omitted",
+			code:       oauth.Code{RedirectURI: testRedirectURI, RedirectURIProvided: true},
+			form:       url.Values{"code_verifier": {testVerifier}},
+			wantStatus: http.StatusBadRequest,
+			wantError:  oauth.ErrInvalidGrant,
+		},
+		{
+			name:       "redirect_uri does not match",
+			code:       oauth.Code{RedirectURI: testRedirectURI, RedirectURIProvided: true},
+			form:       url.Values{"redirect_uri": {testRedirectURI + "/other"}, "code_verifier": {testVerifier}},
+			wantStatus: http.StatusBadRequest,
+			wantError:  oauth.ErrInvalidGrant,
+		},
+		{
+			name:       "redirect_uri omitted at authorize and at token",
+			code:       oauth.Code{RedirectURI: testRedirectURI},
+			form:       url.Values{"code_verifier": {testVerifier}},
+			wantStatus: http.StatusOK,
+		},
+		{
+			name:       "redirect_uri omitted at authorize but wrong at token",
+			code:       oauth.Code{RedirectURI: testRedirectURI},
+			form:       url.Values{"redirect_uri": {"https://evil.example.com/callback"}, "code_verifier": {testVerifier}},
+			wantStatus: http.StatusBadRequest,
+			wantError:  oauth.ErrInvalidGrant,
+		},
+		{
+			name:       "wrong code_verifier",
+			code:       oauth.Code{RedirectURI: testRedirectURI, RedirectURIProvided: true},
+			form:       url.Values{"redirect_uri": {testRedirectURI}, "code_verifier": {strings.Repeat("a", 43)}},
+			wantStatus: http.StatusBadRequest,
+			wantError:  oauth.ErrInvalidGrant,
+		},
+		{
+			name:       "missing code_verifier",
+			code:       oauth.Code{RedirectURI: testRedirectURI, RedirectURIProvided: true},
+			form:       url.Values{"redirect_uri": {testRedirectURI}},
+			wantStatus: http.StatusBadRequest,
+			wantError:  oauth.ErrInvalidGrant,
+		},
+		{
+			name:       "code issued to another client",
+			code:       oauth.Code{ClientID: "client-other", RedirectURI: testRedirectURI, RedirectURIProvided: true},
+			form:       url.Values{"redirect_uri": {testRedirectURI}, "code_verifier": {testVerifier}},
+			wantStatus: http.StatusBadRequest,
+			wantError:  oauth.ErrInvalidGrant,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mr, mock := setupOAuth(t)
+			client := testClient(true, oauth.GrantAuthorizationCode)
+
+			c := tt.code
+			if c.ClientID == "" {
+				c.ClientID = client.ClientID
+			}
+			c.UserID = 42
+			c.Scope = oauth.ScopeUsersRead
+			c.CodeChallenge = pkceChallenge(testVerifier)
+			code, err := oauth.SaveCode(context.Background(), Rdb, c)
+			if err != nil {
+				t.Fatalf("SaveCode: %v", err)
+			}
+
+			expectClient(mock, client)
+			tt.form.Set("grant_type", oauth.GrantAuthorizationCode)
+			tt.form.Set("code", code)
+			status, resp := postToken(t, client, tt.form)
+			if status != tt.wantStatus || resp.Error != tt.wantError {
+				t.Fatalf("token = %d %q; want %d %q", status, resp.Error, tt.wantStatus, tt.wantError)
+			}
+			if status == http.StatusOK && (resp.AccessToken == "" || resp.RefreshToken != "" || resp.Scope != oauth.ScopeUsersRead) {
+				t.Errorf("response = %+v; want access token only with scope users:read", resp.OAuthTokenResponse)
+			}
+			// Code tetap habis walaupun penukaran gagal, supaya tidak bisa ditebak ulang
+			for _, k := range mr.Keys() {
+				if strings.HasPrefix(k, "oauth_code:") {
+					t.Errorf("authorization code %s still stored", k)
+				}
+			}
+		})
+	}
+}
+
+func TestOAuthRefreshScope(t *testing.T) {
+	tests := []struct {
+		name            string
+		scope           string
+		wantStatus      int
+		wantError       string
+		wantAccessScope string
+	}{
+		{"same scope", "", http.StatusOK, "", "users:read users:write"},
+		{"narrowed", "users:read", http.StatusOK, "", "users:read"},
+		{"widened", "users:read admin", http.StatusBadRequest, oauth.ErrInvalidScope, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, mock := setupOAuth(t)
+			client := testClient(true, oauth.GrantAuthorizationCode, oauth.GrantRefreshToken)
+
+			original := tokenGrant{UserID: 42, ClientID: client.ClientID, Scope: "users:read users:write", AuthTime: time.Now()}
+			pair, err := generateTokens(context.Background(), original)
+			if err != nil {
+				t.Fatalf("generateTokens: %v", err)
+			}
+
+			expectClient(mock, client)
+			form := url.Values{"grant_type": {oauth.GrantRefreshToken}, "refresh_token": {pair.Refresh}}
+			if tt.scope != "" {
+				form.Set("scope", tt.scope)
+			}
+			status, resp := postToken(t, client, form)
+			if status != tt.wantStatus || resp.Error != tt.wantError {
+				t.Fatalf("refresh = %d %q; want %d %q", status, resp.Error, tt.wantStatus, tt.wantError)
+			}
+			if status != http.StatusOK {
+				return
+			}
+
+			if resp.Scope != tt.wantAccessScope || parseTestToken(t, resp.AccessToken, token.UseAccess).Scope != tt.wantAccessScope {
+				t.Errorf("access scope = %q; want %q", resp.Scope, tt.wantAccessScope)
+			}
+			// RFC 6749 section 6: refresh token baru tetap memakai scope grant awal
+			refresh := parseTestToken(t, resp.RefreshToken, token.UseRefresh)
+			if refresh.Scope != original.Scope {
+				t.Errorf("refresh token scope = %q; want %q", refresh.Scope, original.Scope)
+			}
+
+			// Setelah dipersempit, refresh berikutnya masih bisa meminta scope awal
+			expectClient(mock, client)
+			status, resp = postToken(t, client, url.Values{"grant_type": {oauth.GrantRefreshToken}, "refresh_token": {resp.RefreshToken}, "scope": {original.Scope}})
+			if status != http.StatusOK || resp.Scope != original.Scope {
+				t.Errorf("second refresh = %d %q scope %q; want 200 with scope %q", status, resp.Error, resp.Scope, original.Scope)
+			}
+		})
+	}
+}
+
+func TestOAuthRefreshTokenOfAnotherClient(t *testing.T) {
+	_, mock := setupOAuth(t)
+	client := testClient(true, oauth.GrantRefreshToken)
+
+	pair, err := generateTokens(context.Background(), tokenGrant{UserID: 42, ClientID: "client-other", Scope: "users:read", AuthTime: time.Now()})
+	if err != nil {
+		t.Fatalf("generateTokens: %v", err)
+	}
+	expectClient(mock, client)
+	status, resp := postToken(t, client, url.Values{"grant_type": {oauth.GrantRefreshToken}, "refresh_token": {pair.Refresh}})
+	if status != http.StatusBadRequest || resp.Error != oauth.ErrInvalidGrant {
+		t.Errorf("refresh = %d %q; want 400 invalid_grant", status, resp.Error)
+	}
+}
+
+func TestOAuthClientCredentials(t *testing.T) {
+	tests := []struct {
+		name       string
+		client     models.OAuthClient
+		scope      string
+		wantStatus int
+		wantError  string
+		wantScope  string
+	}{
+		{"confidential client", testClient(true, oauth.GrantClientCredentials), "", http.StatusOK, "", "users:read users:write admin"},
+		{"confidential client with narrower scope", testClient(true, oauth.GrantClientCredentials), "users:read", http.StatusOK, "", "users:read"},
+		// Client lama yang terdaftar sebelum validasi grant type tetap ditolak di token endpoint
+		{"public client", testClient(false, oauth.GrantClientCredentials), "", http.StatusBadRequest, oauth.ErrUnauthorizedClient, ""},
+		{"grant not registered", testClient(true, oauth.GrantAuthorizationCode), "", http.StatusBadRequest, oauth.ErrUnauthorizedClient, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, mock := setupOAuth(t)
+			expectClient(mock, tt.client)
+
+			form := url.Values{"grant_type": {oauth.GrantClientCredentials}}
+			if tt.scope != "" {
+				form.Set("scope", tt.scope)
+			}
+			status, resp := postToken(t, tt.client, form)
+			if status != tt.wantStatus || resp.Error != tt.wantError {
+				t.Fatalf("token = %d %q; want %d %q", status, resp.Error, tt.wantStatus, tt.wantError)
+			}
+			if status != http.StatusOK {
+				return
+			}
+			claims := parseTestToken(t, resp.AccessToken, token.UseAccess)
+			if resp.RefreshToken != "" || claims.UserID != 0 || claims.ClientID != tt.client.ClientID || claims.Scope != tt.wantScope {
+				t.Errorf("claims = %+v, refresh %q; want client token with scope %q and no refresh token", claims, resp.RefreshToken, tt.wantScope)
+			}
+		})
+	}
+}
+
+func TestOAuthTokenRejectsWrongClientSecret(t *testing.T) {
+	_, mock := setupOAuth(t)
+	client := testClient(true, oauth.GrantClientCredentials)
+	expectClient(mock, client)
+
+	form := url.Values{"grant_type": {oauth.GrantClientCredentials}}
+	req := httptest.NewRequest(http.MethodPost, "/oauth/token", strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.SetBasicAuth(client.ClientID, "wrong-secret")
+	rec := httptest.NewRecorder()
+	OAuthToken(rec, req)
+
+	if rec.Code != http.StatusUnauthorized || rec.Header().Get("WWW-Authenticate") == "" {
+		t.Errorf("status = %d, WWW-Authenticate %q; want 401 with challenge", rec.Code, rec.Header().Get("WWW-Authenticate"))
+	}
+}
@@ -0,0 +1,48 @@
+package handlers
+
+import (
+	"betest/internal/response"
+	"errors"
+	"math"
+	"net/http"
+	"strconv"
+)
+
+const (
+	defaultPageLimit = 10  // Default 10 data per halaman
+	maxPageLimit     = 100 // Maksimal 100 data per halaman untuk menghindari overload
+)
+
+// parsePagination membaca query parameter page dan limit
+func parsePagination(r *http.Request) (page, limit int, err error) {
+	page = 1
+	limit = defaultPageLimit
+
+	if pageStr := r.URL.Query().Get("page"); pageStr != "" {
+		parsedPage, err := strconv.Atoi(pageStr)
+		if err != nil || parsedPage <= 0 {
+			return 0, 0, errors.New("Invalid page parameter. Must be a positive integer")
+		}
+		page = parsedPage
+	}
+
+	if limitStr := r.URL.Query().Get("limit"); limitStr != "" {
+		parsedLimit, err := strconv.Atoi(limitStr)
+		if err != nil || parsedLimit <= 0 {
+			return 0, 0, errors.New("Invalid limit parameter. Must be a positive integer")
+		}
+		limit = min(parsedLimit, maxPageLimit)
+	}
+
+	return page, limit, nil
+}
+
+// paginationMeta menghitung metadata pagination dari total data
+func paginationMeta(page, limit, total int) response.PaginationMeta {
+	return response.PaginationMeta{
+		Page:       page,
+		PerPage:    limit,
+		Total:      total,
+		TotalPages: int(math.Ceil(float64(total) / float64(limit))),
+	}
+}
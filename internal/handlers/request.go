@@ -0,0 +1,33 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+)
+
+// MaxBodyBytes adalah batas ukuran body JSON per request, di-set dari main
+var MaxBodyBytes int64 = 1 << 20
+
+// decodeJSON membaca body JSON ke v dengan batas MaxBodyBytes. Jika gagal response error
+// (400, atau 413 jika body terlalu besar) langsung dikirim dan hasilnya false.
+func decodeJSON(w http.ResponseWriter, r *http.Request, v interface{}) bool {
+	r.Body = http.MaxBytesReader(w, r.Body, MaxBodyBytes)
+	err := json.NewDecoder(r.Body).Decode(v)
+	var tooLarge *http.MaxBytesError
+	if errors.As(err, &tooLarge) {
+		SendError(w, http.StatusRequestEntityTooLarge, "Request body too large")
+		return false
+	}
+	if err != nil {
+		SendError(w, http.StatusBadRequest, "Invalid request body")
+		return false
+	}
+	return true
+}
+
+// parseForm membaca query string dan body form-urlencoded (endpoint OAuth2) dengan batas MaxBodyBytes
+func parseForm(w http.ResponseWriter, r *http.Request) error {
+	r.Body = http.MaxBytesReader(w, r.Body, MaxBodyBytes)
+	return r.ParseForm()
+}
@@ -4,7 +4,8 @@ import "betest/internal/response"
 
 // Re-export response functions for convenience
 var (
-	SendSuccess     = response.SendSuccess
-	SendError       = response.SendError
+	SendSuccess       = response.SendSuccess
+	SendError         = response.SendError
 	SendSuccessNoData = response.SendSuccessNoData
+	SendErrorWithData = response.SendErrorWithData
 )
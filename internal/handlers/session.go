@@ -0,0 +1,101 @@
+package handlers
+
+import (
+	"betest/internal/config"
+	"betest/internal/token"
+	"fmt"
+	"time"
+)
+
+// tokenConfig adalah format dan umur token, diisi dari main lewat InitTokens
+var tokenConfig = config.TokenConfig{
+	AccessFormat:       token.FormatJWT,
+	AccessTTL:          15 * time.Minute,
+	RefreshTTL:         7 * 24 * time.Hour,
+	RememberMeTTL:      30 * 24 * time.Hour,
+	SessionMaxLifetime: 90 * 24 * time.Hour,
+}
+
+// InitTokens memvalidasi dan memasang konfigurasi format dan umur token
+func InitTokens(cfg config.TokenConfig) error {
+	if err := token.ValidFormat(cfg.AccessFormat); err != nil {
+		return err
+	}
+	if cfg.AccessTTL <= 0 || cfg.RefreshTTL <= 0 || cfg.RememberMeTTL <= 0 {
+		return fmt.Errorf("token ttl must be positive")
+	}
+	if cfg.SessionMaxLifetime < 0 || cfg.IdleTimeout < 0 {
+		return fmt.Errorf("session max lifetime and idle timeout must not be negative")
+	}
+	if cfg.IdleTimeout > 0 && cfg.IdleTimeout < cfg.AccessTTL {
+		// Client yang aktif baru refresh setelah access token habis, jadi sesinya akan dianggap idle
+		return fmt.Errorf("idle timeout must not be shorter than access token ttl")
+	}
+	tokenConfig = cfg
+	return nil
+}
+
+// sessionRemaining adalah sisa umur sesi sampai SessionMaxLifetime sejak login (AuthTime).
+// ok false jika sesi tidak dibatasi (batas nonaktif, atau grant tanpa login seperti client_credentials).
+func (g tokenGrant) sessionRemaining() (remaining time.Duration, ok bool) {
+	if tokenConfig.SessionMaxLifetime <= 0 || g.AuthTime.IsZero() {
+		return 0, false
+	}
+	return time.Until(g.AuthTime.Add(tokenConfig.SessionMaxLifetime)), true
+}
+
+// sessionExpired menandakan sesi sudah melewati umur maksimalnya, jadi refresh token tidak boleh di-rotate lagi
+func (g tokenGrant) sessionExpired() bool {
+	remaining, ok := g.sessionRemaining()
+	return ok && remaining < time.Second
+}
+
+// accessTTL adalah umur access token, tidak melewati akhir sesi
+func (g tokenGrant) accessTTL() time.Duration {
+	ttl := tokenConfig.AccessTTL
+	if remaining, ok := g.sessionRemaining(); ok {
+		ttl = min(ttl, remaining)
+	}
+	return ttl
+}
+
+// refreshTTL adalah umur refresh token (claim exp dan MaxAge cookie): RefreshTTL, atau RememberMeTTL
+// jika login dengan remember_me, dan tidak melewati akhir sesi
+func (g tokenGrant) refreshTTL() time.Duration {
+	ttl := tokenConfig.RefreshTTL
+	if g.RememberMe {
+		ttl = tokenConfig.RememberMeTTL
+	}
+	if remaining, ok := g.sessionRemaining(); ok {
+		ttl = min(ttl, remaining)
+	}
+	return ttl
+}
+
+// refreshKeyTTL adalah TTL key refresh token di Redis. Dengan idle timeout key dihapus lebih awal
+// jika tidak di-refresh; setiap rotasi menulis key baru sehingga batasnya bergeser (sliding).
+func (g tokenGrant) refreshKeyTTL() time.Duration {
+	ttl := g.refreshTTL()
+	if tokenConfig.IdleTimeout > 0 && !g.RememberMe {
+		ttl = min(ttl, tokenConfig.IdleTimeout)
+	}
+	return ttl
+}
+
+// refreshGrant membuat grant untuk rotasi dari claim refresh token lama, dengan scope yang sama.
+// Token yang diterbitkan sebelum ada claim auth_time memakai iat sebagai waktu login.
+func refreshGrant(claims *token.Claims) tokenGrant {
+	g := tokenGrant{
+		UserID:     claims.UserID,
+		ClientID:   claims.ClientID,
+		Scope:      claims.Scope,
+		RememberMe: claims.RememberMe,
+	}
+	switch {
+	case claims.AuthTime != nil:
+		g.AuthTime = claims.AuthTime.Time
+	case claims.IssuedAt != nil:
+		g.AuthTime = claims.IssuedAt.Time
+	}
+	return g
+}
@@ -0,0 +1,200 @@
+package handlers
+
+import (
+	"betest/internal/config"
+	"betest/internal/token"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// useTokenConfig memasang cfg lewat InitTokens selama test
+func useTokenConfig(t *testing.T, cfg config.TokenConfig) {
+	t.Helper()
+	prev := tokenConfig
+	if err := InitTokens(cfg); err != nil {
+		t.Fatalf("InitTokens: %v", err)
+	}
+	t.Cleanup(func() { tokenConfig = prev })
+}
+
+func sessionConfig(maxLifetime, idle time.Duration) config.TokenConfig {
+	return config.TokenConfig{
+		AccessFormat:       token.FormatJWT,
+		AccessTTL:          15 * time.Minute,
+		RefreshTTL:         7 * 24 * time.Hour,
+		RememberMeTTL:      30 * 24 * time.Hour,
+		SessionMaxLifetime: maxLifetime,
+		IdleTimeout:        idle,
+	}
+}
+
+func TestInitTokens(t *testing.T) {
+	tests := []struct {
+		name    string
+		mutate  func(*config.TokenConfig)
+		wantErr bool
+	}{
+		{"valid", func(*config.TokenConfig) {}, false},
+		{"opaque format", func(c *config.TokenConfig) { c.AccessFormat = token.FormatOpaque }, false},
+		{"unknown format", func(c *config.TokenConfig) { c.AccessFormat = "paseto" }, true},
+		{"zero access ttl", func(c *config.TokenConfig) { c.AccessTTL = 0 }, true},
+		{"zero refresh ttl", func(c *config.TokenConfig) { c.RefreshTTL = 0 }, true},
+		{"zero remember me ttl", func(c *config.TokenConfig) { c.RememberMeTTL = 0 }, true},
+		{"negative max lifetime", func(c *config.TokenConfig) { c.SessionMaxLifetime = -time.Hour }, true},
+		{"negative idle timeout", func(c *config.TokenConfig) { c.IdleTimeout = -time.Hour }, true},
+		{"idle timeout shorter than access ttl", func(c *config.TokenConfig) { c.IdleTimeout = 10 * time.Minute }, true},
+		{"idle timeout equal to access ttl", func(c *config.TokenConfig) { c.IdleTimeout = 15 * time.Minute }, false},
+		{"idle timeout disabled", func(c *config.TokenConfig) { c.IdleTimeout = 0 }, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			prev := tokenConfig
+			t.Cleanup(func() { tokenConfig = prev })
+
+			cfg := sessionConfig(90*24*time.Hour, time.Hour)
+			tt.mutate(&cfg)
+			err := InitTokens(cfg)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("InitTokens = %v; want error %v", err, tt.wantErr)
+			}
+			// Konfigurasi yang ditolak tidak boleh terpasang
+			if err != nil && tokenConfig != prev {
+				t.Error("rejected config was installed")
+			}
+		})
+	}
+}
+
+// approx membandingkan durasi yang dihitung dari time.Now dengan toleransi kecil
+func approx(got, want time.Duration) bool {
+	d := got - want
+	return d > -time.Second && d < time.Second
+}
+
+func TestSessionTTL(t *testing.T) {
+	const day = 24 * time.Hour
+	tests := []struct {
+		name           string
+		maxLifetime    time.Duration
+		idle           time.Duration
+		loginAgo       time.Duration // 0: grant tanpa login (client_credentials)
+		rememberMe     bool
+		wantExpired    bool
+		wantAccess     time.Duration
+		wantRefresh    time.Duration
+		wantRefreshKey time.Duration
+	}{
+		{
+			name:        "fresh login without limits",
+			loginAgo:    time.Nanosecond,
+			wantAccess:  15 * time.Minute,
+			wantRefresh: 7 * day, wantRefreshKey: 7 * day,
+		},
+		{
+			name:     "idle timeout shortens only the redis key",
+			idle:     time.Hour,
+			loginAgo: time.Nanosecond,
+			// Cookie dan exp tetap RefreshTTL; key Redis yang menentukan sesi idle
+			wantAccess: 15 * time.Minute, wantRefresh: 7 * day, wantRefreshKey: time.Hour,
+		},
+		{
+			name:       "remember me ignores idle timeout",
+			idle:       time.Hour,
+			loginAgo:   time.Nanosecond,
+			rememberMe: true,
+			wantAccess: 15 * time.Minute, wantRefresh: 30 * day, wantRefreshKey: 30 * day,
+		},
+		{
+			name:        "absolute limit caps remember me",
+			maxLifetime: 10 * day,
+			loginAgo:    5 * day,
+			rememberMe:  true,
+			wantAccess:  15 * time.Minute, wantRefresh: 5 * day, wantRefreshKey: 5 * day,
+		},
+		{
+			name:        "absolute cap shorter than idle timeout",
+			maxLifetime: 90 * day,
+			idle:        time.Hour,
+			loginAgo:    90*day - 30*time.Minute,
+			wantAccess:  15 * time.Minute, wantRefresh: 30 * time.Minute, wantRefreshKey: 30 * time.Minute,
+		},
+		{
+			name:        "absolute cap shorter than access ttl",
+			maxLifetime: 90 * day,
+			idle:        time.Hour,
+			loginAgo:    90*day - 5*time.Minute,
+			wantAccess:  5 * time.Minute, wantRefresh: 5 * time.Minute, wantRefreshKey: 5 * time.Minute,
+		},
+		{
+			name:        "absolute limit reached",
+			maxLifetime: 90 * day,
+			loginAgo:    90 * day,
+			wantExpired: true,
+		},
+		{
+			name:        "less than a second left counts as expired",
+			maxLifetime: 90 * day,
+			loginAgo:    90*day - 500*time.Millisecond,
+			wantExpired: true,
+		},
+		{
+			name:        "grant without login is not capped",
+			maxLifetime: time.Hour,
+			wantAccess:  15 * time.Minute, wantRefresh: 7 * day, wantRefreshKey: 7 * day,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			useTokenConfig(t, sessionConfig(tt.maxLifetime, tt.idle))
+
+			g := tokenGrant{UserID: 42, RememberMe: tt.rememberMe}
+			if tt.loginAgo > 0 {
+				g.AuthTime = time.Now().Add(-tt.loginAgo)
+			}
+			if got := g.sessionExpired(); got != tt.wantExpired {
+				t.Fatalf("sessionExpired = %v; want %v", got, tt.wantExpired)
+			}
+			if tt.wantExpired {
+				return
+			}
+			if got := g.accessTTL(); !approx(got, tt.wantAccess) {
+				t.Errorf("accessTTL = %v; want %v", got, tt.wantAccess)
+			}
+			if got := g.refreshTTL(); !approx(got, tt.wantRefresh) {
+				t.Errorf("refreshTTL = %v; want %v", got, tt.wantRefresh)
+			}
+			if got := g.refreshKeyTTL(); !approx(got, tt.wantRefreshKey) {
+				t.Errorf("refreshKeyTTL = %v; want %v", got, tt.wantRefreshKey)
+			}
+		})
+	}
+}
+
+func TestRefreshGrant(t *testing.T) {
+	login := time.Now().Add(-48 * time.Hour).Truncate(time.Second)
+	issued := time.Now().Add(-time.Hour).Truncate(time.Second)
+
+	claims := token.NewClaims(42, token.UseRefresh, time.Hour)
+	claims.ClientID = "reports"
+	claims.Scope = "users:read users:write"
+	claims.RememberMe = true
+	claims.IssuedAt = jwt.NewNumericDate(issued)
+	claims.AuthTime = jwt.NewNumericDate(login)
+
+	g := refreshGrant(claims)
+	if g.UserID != 42 || g.ClientID != "reports" || g.Scope != claims.Scope || !g.RememberMe || g.AccessScope != "" {
+		t.Errorf("grant = %+v", g)
+	}
+	// Umur sesi dihitung dari login pertama, bukan dari rotasi terakhir
+	if !g.AuthTime.Equal(login) {
+		t.Errorf("AuthTime = %v; want %v", g.AuthTime, login)
+	}
+
+	// Token lama tanpa auth_time memakai iat
+	claims.AuthTime = nil
+	if g := refreshGrant(claims); !g.AuthTime.Equal(issued) {
+		t.Errorf("AuthTime without auth_time = %v; want iat %v", g.AuthTime, issued)
+	}
+}
@@ -0,0 +1,160 @@
+package handlers
+
+import (
+	"betest/internal/redisclient"
+	"betest/internal/token"
+	"context"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+// Hasil rotateRefreshToken
+const (
+	rotateOK       = 1  // Token lama dicabut dan token baru tersimpan
+	rotateReused   = 0  // Refresh token tidak ada di Redis (sudah di-rotate atau di-logout)
+	rotateMismatch = -1 // Refresh token milik user lain
+)
+
+// rotateScript menjalankan seluruh rotasi refresh token secara atomik di Redis.
+//
+// KEYS[1] refresh_token:<jti lama>, KEYS[2] refresh_to_access:<jti lama>,
+// KEYS[3] access_token:<jti baru>, KEYS[4] refresh_token:<jti baru>,
+// KEYS[5] refresh_to_access:<jti baru>, KEYS[6] access_to_refresh:<access jti baru>,
+// KEYS[7] blacklist:access_token:<access jti lama>, KEYS[8] access_to_refresh:<access jti lama> dan
+// KEYS[9] access_token:<access jti lama> (opsional)
+// ARGV[1] user id, ARGV[2] access jti baru, ARGV[3] TTL access token (ms), ARGV[4] TTL refresh token (ms),
+// ARGV[5] refresh jti baru, ARGV[6] nilai access_token:<jti baru> (lihat signAccessToken), ARGV[7] leeway (ms)
+//
+// Access token lama masih lolos token.Parse sampai exp + leeway, dan key access_token:<access jti lama>
+// dibuat dengan TTL sampai exp, jadi TTL blacklist = sisa TTL key itu + leeway (tidak bergantung pada
+// TTL access token baru, yang bisa lebih pendek karena batas sesi atau perubahan konfigurasi).
+// Jika key itu sudah tidak ada, token lama sudah kedaluwarsa atau sudah dicabut (blacklist-nya sudah
+// ada), jadi blacklist hanya dibuat untuk sisa leeway tanpa memperpendek blacklist yang sudah ada.
+var rotateScript = redis.NewScript(`
+local owner = redis.call('GET', KEYS[1])
+if not owner then
+	return 0
+end
+if owner ~= ARGV[1] then
+	return -1
+end
+if KEYS[7] and redis.call('GET', KEYS[2]) then
+	local remaining = redis.call('PTTL', KEYS[9])
+	local leeway = tonumber(ARGV[7])
+	if remaining > 0 then
+		redis.call('SET', KEYS[7], '1', 'PX', remaining + leeway)
+	elseif leeway > 0 then
+		redis.call('SET', KEYS[7], '1', 'PX', leeway, 'NX')
+	end
+	redis.call('DEL', KEYS[8], KEYS[9])
+end
+redis.call('DEL', KEYS[1], KEYS[2])
+redis.call('SET', KEYS[3], ARGV[6], 'PX', ARGV[3])
+redis.call('SET', KEYS[4], ARGV[1], 'PX', ARGV[4])
+redis.call('SET', KEYS[5], ARGV[2], 'PX', ARGV[4])
+redis.call('SET', KEYS[6], ARGV[5], 'PX', ARGV[3])
+return 1
+`)
+
+// rotateRefreshToken mengganti refresh token oldJti dengan pasangan token baru.
+// Script dijalankan dengan EVALSHA (fallback ke EVAL jika script belum ter-load).
+func rotateRefreshToken(ctx context.Context, oldJti string, userID int, next tokenPair) (int64, error) {
+	keys := []string{
+		redisclient.Key(fmt.Sprintf("refresh_token:%s", oldJti)),
+		redisclient.Key(fmt.Sprintf("refresh_to_access:%s", oldJti)),
+		redisclient.Key(fmt.Sprintf("access_token:%s", next.AccessJti)),
+		redisclient.Key(fmt.Sprintf("refresh_token:%s", next.RefreshJti)),
+		redisclient.Key(fmt.Sprintf("refresh_to_access:%s", next.RefreshJti)),
+		redisclient.Key(fmt.Sprintf("access_to_refresh:%s", next.AccessJti)),
+	}
+
+	// Key blacklist harus dideklarasikan di KEYS, jadi access jti lama dibaca lebih dulu.
+	// Mapping ini tidak pernah berubah untuk satu refresh jti, sehingga aman dibaca di luar script;
+	// script tetap mengecek mapping masih ada sebelum menulis blacklist.
+	oldAccessJti, err := Rdb.Get(ctx, keys[1]).Result()
+	if err != nil && err != redis.Nil {
+		return 0, err
+	}
+	if oldAccessJti != "" {
+		keys = append(keys,
+			redisclient.Key(fmt.Sprintf("blacklist:access_token:%s", oldAccessJti)),
+			redisclient.Key(fmt.Sprintf("access_to_refresh:%s", oldAccessJti)),
+			redisclient.Key(fmt.Sprintf("access_token:%s", oldAccessJti)))
+	}
+
+	return rotateScript.Run(ctx, Rdb, keys,
+		userID, next.AccessJti, next.AccessTTL.Milliseconds(), next.RefreshKeyTTL.Milliseconds(), next.RefreshJti, next.AccessSession,
+		token.Leeway.Milliseconds()).Int64()
+}
+
+// revokeRefreshForAccess mencabut refresh token yang diterbitkan bersama access token accessJti
+func revokeRefreshForAccess(ctx context.Context, accessJti string) {
+	mappingKey := redisclient.Key(fmt.Sprintf("access_to_refresh:%s", accessJti))
+	refreshJti, err := Rdb.Get(ctx, mappingKey).Result()
+	if err != nil {
+		if err != redis.Nil {
+			slog.WarnContext(ctx, "Error looking up refresh token for logout", "error", err)
+		}
+		return
+	}
+	err = Rdb.Del(ctx,
+		redisclient.Key(fmt.Sprintf("refresh_token:%s", refreshJti)),
+		redisclient.Key(fmt.Sprintf("refresh_to_access:%s", refreshJti)),
+		mappingKey).Err()
+	if err != nil {
+		slog.WarnContext(ctx, "Error revoking refresh token", "error", err)
+	}
+}
+
+// revokeRefreshToken mencabut refresh token refreshJti beserta access token pasangannya
+// (RFC 7009: mencabut refresh token juga membatalkan access token dari grant yang sama)
+func revokeRefreshToken(ctx context.Context, refreshJti string) error {
+	mappingKey := redisclient.Key(fmt.Sprintf("refresh_to_access:%s", refreshJti))
+	accessJti, err := Rdb.Get(ctx, mappingKey).Result()
+	if err != nil && err != redis.Nil {
+		return err
+	}
+	// Sama seperti rotasi, blacklist berlaku sampai exp access token (sisa TTL key-nya) + leeway,
+	// bukan TOKEN_ACCESS_TTL saat ini
+	var remaining time.Duration
+	if accessJti != "" {
+		remaining, err = Rdb.PTTL(ctx, redisclient.Key(fmt.Sprintf("access_token:%s", accessJti))).Result()
+		if err != nil {
+			return err
+		}
+	}
+	_, err = Rdb.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.Del(ctx, redisclient.Key(fmt.Sprintf("refresh_token:%s", refreshJti)), mappingKey)
+		if accessJti != "" {
+			pipe.Del(ctx,
+				redisclient.Key(fmt.Sprintf("access_to_refresh:%s", accessJti)),
+				redisclient.Key(fmt.Sprintf("access_token:%s", accessJti)))
+			blacklistKey := redisclient.Key(fmt.Sprintf("blacklist:access_token:%s", accessJti))
+			if remaining > 0 {
+				pipe.Set(ctx, blacklistKey, "1", remaining+token.Leeway)
+			} else if token.Leeway > 0 {
+				pipe.SetNX(ctx, blacklistKey, "1", token.Leeway)
+			}
+		}
+		return nil
+	})
+	return err
+}
+
+// revokeAccessToken mencabut access token: JWT di-blacklist sampai exp + leeway (selama token.Parse
+// masih menerimanya), dan key access_token:<jti> dihapus sehingga opaque token langsung tidak bisa
+// di-resolve lagi
+func revokeAccessToken(ctx context.Context, claims *token.Claims) error {
+	remaining := time.Until(claims.ExpiresAt.Time) + token.Leeway
+	_, err := Rdb.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.Del(ctx, redisclient.Key(fmt.Sprintf("access_token:%s", claims.ID)))
+		if remaining > 0 {
+			pipe.Set(ctx, redisclient.Key(fmt.Sprintf("blacklist:access_token:%s", claims.ID)), "1", remaining)
+		}
+		return nil
+	})
+	return err
+}
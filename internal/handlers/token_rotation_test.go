@@ -0,0 +1,145 @@
+package handlers
+
+import (
+	"betest/internal/token"
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/go-redis/redis/v8"
+)
+
+func TestRotateRefreshTokenConcurrent(t *testing.T) {
+	mr := miniredis.RunT(t)
+	prev := Rdb
+	Rdb = redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	t.Cleanup(func() {
+		Rdb.Close()
+		Rdb = prev
+	})
+
+	// Keadaan setelah login: satu pasangan token user 42
+	const userID = 42
+	mr.Set("refresh_token:old-refresh", "42")
+	mr.Set("refresh_to_access:old-refresh", "old-access")
+	mr.Set("access_to_refresh:old-access", "old-refresh")
+	mr.Set("access_token:old-access", "42")
+
+	const n = 20
+	results := make([]int64, n)
+	errs := make([]error, n)
+	start := make(chan struct{})
+	var wg sync.WaitGroup
+	for i := range n {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			next := tokenPair{
+				AccessJti:     fmt.Sprintf("access-%d", i),
+				RefreshJti:    fmt.Sprintf("refresh-%d", i),
+				AccessSession: "42",
+				AccessTTL:     time.Minute,
+				RefreshKeyTTL: time.Hour,
+			}
+			<-start
+			results[i], errs[i] = rotateRefreshToken(context.Background(), "old-refresh", userID, next)
+		}()
+	}
+	close(start)
+	wg.Wait()
+
+	winner := -1
+	reused := 0
+	for i := range n {
+		if errs[i] != nil {
+			t.Fatalf("rotation %d: %v", i, errs[i])
+		}
+		switch results[i] {
+		case rotateOK:
+			if winner >= 0 {
+				t.Fatalf("rotations %d and %d both succeeded", winner, i)
+			}
+			winner = i
+		case rotateReused:
+			reused++
+		default:
+			t.Fatalf("rotation %d returned %d", i, results[i])
+		}
+	}
+	if winner < 0 {
+		t.Fatal("no rotation succeeded")
+	}
+	if reused != n-1 {
+		t.Errorf("reused = %d; want %d", reused, n-1)
+	}
+
+	var refreshKeys []string
+	for _, k := range mr.Keys() {
+		if strings.HasPrefix(k, "refresh_token:") {
+			refreshKeys = append(refreshKeys, k)
+		}
+	}
+	if want := fmt.Sprintf("refresh_token:refresh-%d", winner); len(refreshKeys) != 1 || refreshKeys[0] != want {
+		t.Errorf("refresh keys = %v; want [%s]", refreshKeys, want)
+	}
+
+	// Access token lama dicabut tepat sekali oleh rotasi yang menang
+	if !mr.Exists("blacklist:access_token:old-access") {
+		t.Error("old access token was not blacklisted")
+	}
+	if mr.Exists("access_token:old-access") || mr.Exists("access_to_refresh:old-access") {
+		t.Error("old access token keys were not removed")
+	}
+}
+
+func TestRotateRefreshTokenBlacklistTTL(t *testing.T) {
+	tests := []struct {
+		name         string
+		oldAccessTTL time.Duration // 0: key access_token lama sudah tidak ada
+		blacklistTTL time.Duration // Blacklist yang sudah ada sebelum rotasi (0: tidak ada)
+		wantMin      time.Duration
+		wantMax      time.Duration
+	}{
+		// Access token baru jauh lebih pendek (batas sesi), blacklist tetap mengikuti exp token lama
+		{"old token outlives the new one", 10 * time.Minute, 0, 10*time.Minute + token.Leeway - time.Second, 10*time.Minute + token.Leeway},
+		{"old token expired", 0, 0, token.Leeway - time.Second, token.Leeway},
+		{"old token already revoked", 0, time.Hour, time.Hour - time.Second, time.Hour},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mr := miniredis.RunT(t)
+			prev := Rdb
+			Rdb = redis.NewClient(&redis.Options{Addr: mr.Addr()})
+			t.Cleanup(func() {
+				Rdb.Close()
+				Rdb = prev
+			})
+
+			mr.Set("refresh_token:old-refresh", "42")
+			mr.Set("refresh_to_access:old-refresh", "old-access")
+			if tt.oldAccessTTL > 0 {
+				mr.Set("access_token:old-access", "42")
+				mr.SetTTL("access_token:old-access", tt.oldAccessTTL)
+			}
+			if tt.blacklistTTL > 0 {
+				mr.Set("blacklist:access_token:old-access", "1")
+				mr.SetTTL("blacklist:access_token:old-access", tt.blacklistTTL)
+			}
+
+			next := tokenPair{AccessJti: "new-access", RefreshJti: "new-refresh", AccessSession: "42", AccessTTL: time.Second, RefreshKeyTTL: time.Hour}
+			status, err := rotateRefreshToken(context.Background(), "old-refresh", 42, next)
+			if err != nil || status != rotateOK {
+				t.Fatalf("rotateRefreshToken = %d, %v; want rotateOK", status, err)
+			}
+
+			ttl := mr.TTL("blacklist:access_token:old-access")
+			if ttl < tt.wantMin || ttl > tt.wantMax {
+				t.Errorf("blacklist TTL = %v; want between %v and %v", ttl, tt.wantMin, tt.wantMax)
+			}
+		})
+	}
+}
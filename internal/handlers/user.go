@@ -1,12 +1,19 @@
 package handlers
 
 import (
+	"betest/internal/audit"
+	"betest/internal/cache"
 	"betest/internal/database"
+	"betest/internal/events"
 	"betest/internal/models"
+	"betest/internal/outbox"
+	"betest/internal/repository"
 	"betest/internal/response"
-	"encoding/json"
-	"log"
-	"math"
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log/slog"
 	"net/http"
 	"strconv"
 
@@ -15,79 +22,55 @@ import (
 
 func GetUsers(w http.ResponseWriter, r *http.Request) {
 	// Parse query parameters untuk pagination
-	page := 1
-	limit := 10 // Default 10 data per halaman
-
-	if pageStr := r.URL.Query().Get("page"); pageStr != "" {
-		if parsedPage, err := strconv.Atoi(pageStr); err == nil && parsedPage > 0 {
-			page = parsedPage
-		} else {
-			SendError(w, http.StatusBadRequest, "Invalid page parameter. Must be a positive integer")
-			return
-		}
-	}
-
-	if limitStr := r.URL.Query().Get("limit"); limitStr != "" {
-		if parsedLimit, err := strconv.Atoi(limitStr); err == nil && parsedLimit > 0 {
-			// Maksimal 100 data per halaman untuk menghindari overload
-			if parsedLimit > 100 {
-				limit = 100
-			} else {
-				limit = parsedLimit
-			}
-		} else {
-			SendError(w, http.StatusBadRequest, "Invalid limit parameter. Must be a positive integer")
-			return
-		}
+	page, limit, err := parsePagination(r)
+	if err != nil {
+		SendError(w, http.StatusBadRequest, err.Error())
+		return
 	}
 
 	// Hitung offset
 	offset := (page - 1) * limit
 
-	// Query untuk mendapatkan total jumlah data
-	var total int
-	err := database.DB.QueryRow("SELECT COUNT(*) FROM users").Scan(&total)
-	if err != nil {
-		log.Printf("Error counting users: %v", err)
-		SendError(w, http.StatusInternalServerError, "Error fetching users count")
-		return
-	}
+	key := UserListCache.Versioned(r.Context(), fmt.Sprintf("page:%d:limit:%d", page, limit))
+	result, err := cache.Fetch(r.Context(), UserListCache, key, func(ctx context.Context) (userPage, error) {
+		ctx, cancel := database.WithTimeout(ctx)
+		defer cancel()
 
-	// Query untuk mendapatkan data dengan pagination (diurutkan berdasarkan created_at DESC - terbaru dulu)
-	rows, err := database.DB.Query(
-		"SELECT id, name, email, created_at FROM users ORDER BY created_at DESC LIMIT $1 OFFSET $2",
-		limit, offset)
-	if err != nil {
-		log.Printf("Error fetching users: %v", err)
-		SendError(w, http.StatusInternalServerError, "Error fetching users")
-		return
-	}
-	defer rows.Close()
+		// Count dan list membaca dari koneksi yang sama supaya konsisten
+		db := database.Reader()
 
-	var users []models.User
-	for rows.Next() {
-		var u models.User
-		err := rows.Scan(&u.ID, &u.Name, &u.Email, &u.CreatedAt)
-		if err != nil {
-			log.Printf("Error scanning user data: %v", err)
-			SendError(w, http.StatusInternalServerError, "Error scanning user data")
-			return
+		// Query untuk mendapatkan total jumlah data
+		var p userPage
+		if err := db.QueryRowContext(ctx, "SELECT COUNT(*) FROM users").Scan(&p.Total); err != nil {
+			return p, fmt.Errorf("counting users: %w", err)
 		}
-		users = append(users, u)
-	}
 
-	// Hitung total pages
-	totalPages := int(math.Ceil(float64(total) / float64(limit)))
+		// Query untuk mendapatkan data dengan pagination (diurutkan berdasarkan created_at DESC - terbaru dulu)
+		rows, err := db.QueryContext(ctx,
+			"SELECT id, name, email, created_at FROM users ORDER BY created_at DESC LIMIT $1 OFFSET $2",
+			limit, offset)
+		if err != nil {
+			return p, fmt.Errorf("fetching users: %w", err)
+		}
+		defer rows.Close()
 
-	// Kirim response dengan pagination metadata
-	meta := response.PaginationMeta{
-		Page:       page,
-		PerPage:    limit,
-		Total:      total,
-		TotalPages: totalPages,
+		for rows.Next() {
+			var u models.User
+			if err := rows.Scan(&u.ID, &u.Name, &u.Email, &u.CreatedAt); err != nil {
+				return p, fmt.Errorf("scanning user data: %w", err)
+			}
+			p.Users = append(p.Users, u)
+		}
+		return p, rows.Err()
+	})
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Error fetching users", "error", err)
+		SendError(w, http.StatusInternalServerError, "Error fetching users")
+		return
 	}
 
-	response.SendPaginatedSuccess(w, http.StatusOK, "Users retrieved successfully", users, meta)
+	// Kirim response dengan pagination metadata
+	response.SendPaginatedSuccess(w, http.StatusOK, "Users retrieved successfully", result.Users, paginationMeta(page, limit, result.Total))
 }
 
 func GetUser(w http.ResponseWriter, r *http.Request) {
@@ -98,10 +81,17 @@ func GetUser(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	var u models.User
-	err = database.DB.QueryRow("SELECT id, name, email, created_at FROM users WHERE id=$1", id).Scan(&u.ID, &u.Name, &u.Email, &u.CreatedAt)
+	u, err := cache.Fetch(r.Context(), UserCache, strconv.Itoa(id), func(ctx context.Context) (models.User, error) {
+		ctx, cancel := database.WithTimeout(ctx)
+		defer cancel()
+
+		var u models.User
+		err := database.Reader().QueryRowContext(ctx, "SELECT id, name, email, created_at FROM users WHERE id=$1", id).Scan(
+			&u.ID, &u.Name, &u.Email, &u.CreatedAt)
+		return u, err
+	})
 	if err != nil {
-		log.Printf("Error getting user %d: %v", id, err)
+		slog.ErrorContext(r.Context(), "Error getting user", "id", id, "error", err)
 		SendError(w, http.StatusNotFound, "User not found")
 		return
 	}
@@ -110,18 +100,46 @@ func GetUser(w http.ResponseWriter, r *http.Request) {
 }
 
 func CreateUser(w http.ResponseWriter, r *http.Request) {
-	var u models.User
-	err := json.NewDecoder(r.Body).Decode(&u)
-	if err != nil {
-		SendError(w, http.StatusBadRequest, "Invalid request body")
+	var req models.User
+	if !decodeJSON(w, r, &req) {
 		return
 	}
 
-	err = database.DB.QueryRow("INSERT INTO users (name, email) VALUES ($1, $2) RETURNING id", u.Name, u.Email).Scan(&u.ID)
+	ctx, cancel := database.WithTimeout(r.Context())
+	defer cancel()
+
+	// Insert user dan event UserRegistered dalam satu transaksi
+	var u models.User
+	err := database.WithTx(ctx, func(tx *sql.Tx) error {
+		var err error
+		u, err = repository.CreateUser(ctx, tx, req)
+		if err != nil {
+			return err
+		}
+		return outbox.WriteUserEvent(ctx, tx, events.UserRegistered, u.ID, events.UserRegisteredPayload{
+			UserID: u.ID,
+			Name:   u.Name,
+			Email:  u.Email,
+			Source: events.SourceAdmin,
+		})
+	})
+	if database.IsUniqueViolation(err) {
+		SendError(w, http.StatusConflict, "Email already exists")
+		return
+	}
 	if err != nil {
+		slog.ErrorContext(r.Context(), "Error creating user", "error", err)
 		SendError(w, http.StatusInternalServerError, "Error creating user")
 		return
 	}
+	invalidateUserCache(r)
+
+	audit.Record(r, audit.Entry{
+		Action:       audit.ActionUserCreate,
+		ResourceType: audit.ResourceUser,
+		ResourceID:   strconv.Itoa(u.ID),
+		After:        u,
+	})
 
 	SendSuccess(w, http.StatusCreated, "User created successfully", u)
 }
@@ -134,26 +152,55 @@ func UpdateUser(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	var u models.User
-	err = json.NewDecoder(r.Body).Decode(&u)
-	if err != nil {
-		SendError(w, http.StatusBadRequest, "Invalid request body")
+	var req models.User
+	if !decodeJSON(w, r, &req) {
 		return
 	}
 
-	result, err := database.DB.Exec("UPDATE users SET name=$1, email=$2 WHERE id=$3", u.Name, u.Email, id)
+	ctx, cancel := database.WithTimeout(r.Context())
+	defer cancel()
+
+	var before, u models.User
+	err = database.WithTx(ctx, func(tx *sql.Tx) error {
+		var err error
+		// Ambil (dan kunci) data lama untuk audit log
+		before, err = repository.GetUserForUpdate(ctx, tx, id)
+		if err != nil {
+			return err
+		}
+		u, err = repository.UpdateUser(ctx, tx, id, req.Name, req.Email)
+		if err != nil {
+			return err
+		}
+		return outbox.WriteUserEvent(ctx, tx, events.UserUpdated, id, events.UserUpdatedPayload{
+			UserID: id,
+			Name:   u.Name,
+			Email:  u.Email,
+		})
+	})
+	if errors.Is(err, sql.ErrNoRows) {
+		SendError(w, http.StatusNotFound, "User not found")
+		return
+	}
+	if database.IsUniqueViolation(err) {
+		SendError(w, http.StatusConflict, "Email already exists")
+		return
+	}
 	if err != nil {
+		slog.ErrorContext(r.Context(), "Error updating user", "id", id, "error", err)
 		SendError(w, http.StatusInternalServerError, "Error updating user")
 		return
 	}
+	invalidateUserCache(r, id)
 
-	rowsAffected, _ := result.RowsAffected()
-	if rowsAffected == 0 {
-		SendError(w, http.StatusNotFound, "User not found")
-		return
-	}
+	audit.Record(r, audit.Entry{
+		Action:       audit.ActionUserUpdate,
+		ResourceType: audit.ResourceUser,
+		ResourceID:   strconv.Itoa(id),
+		Before:       before,
+		After:        u,
+	})
 
-	u.ID = id
 	SendSuccess(w, http.StatusOK, "User updated successfully", u)
 }
 
@@ -165,17 +212,39 @@ func DeleteUser(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	result, err := database.DB.Exec("DELETE FROM users WHERE id=$1", id)
+	ctx, cancel := database.WithTimeout(r.Context())
+	defer cancel()
+
+	// RETURNING dipakai untuk menyimpan data terakhir user di audit log
+	var before models.User
+	err = database.WithTx(ctx, func(tx *sql.Tx) error {
+		var err error
+		before, err = repository.DeleteUser(ctx, tx, id)
+		if err != nil {
+			return err
+		}
+		return outbox.WriteUserEvent(ctx, tx, events.UserDeleted, id, events.UserDeletedPayload{
+			UserID: id,
+			Email:  before.Email,
+		})
+	})
+	if errors.Is(err, sql.ErrNoRows) {
+		SendError(w, http.StatusNotFound, "User not found")
+		return
+	}
 	if err != nil {
+		slog.ErrorContext(r.Context(), "Error deleting user", "id", id, "error", err)
 		SendError(w, http.StatusInternalServerError, "Error deleting user")
 		return
 	}
+	invalidateUserCache(r, id)
 
-	rowsAffected, _ := result.RowsAffected()
-	if rowsAffected == 0 {
-		SendError(w, http.StatusNotFound, "User not found")
-		return
-	}
+	audit.Record(r, audit.Entry{
+		Action:       audit.ActionUserDelete,
+		ResourceType: audit.ResourceUser,
+		ResourceID:   strconv.Itoa(id),
+		Before:       before,
+	})
 
 	SendSuccessNoData(w, http.StatusOK, "User deleted successfully")
 }
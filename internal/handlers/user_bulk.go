@@ -0,0 +1,630 @@
+package handlers
+
+import (
+	"betest/internal/audit"
+	"betest/internal/database"
+	"betest/internal/events"
+	"betest/internal/models"
+	"betest/internal/outbox"
+	"bufio"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/mail"
+	"runtime"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"golang.org/x/crypto/bcrypt"
+	"golang.org/x/sync/errgroup"
+)
+
+const (
+	importModeAtomic  = "atomic"  // Semua baris harus valid, kalau ada yang gagal seluruh import di-rollback
+	importModePartial = "partial" // Baris yang valid tetap disimpan, baris yang gagal dilaporkan
+
+	formatCSV    = "csv"
+	formatNDJSON = "ndjson"
+
+	maxImportRows = 10000
+	exportFlushN  = 100 // Flush ke client setiap N baris agar data benar-benar di-stream
+
+	// Import dan export tidak memakai budget handler biasa, tapi import tetap dibatasi
+	// supaya upload yang sangat lambat tidak menahan transaksi terlalu lama
+	importTimeout = 5 * time.Minute
+)
+
+// ImportMaxBytes adalah batas ukuran body import, di-set dari main. Batas baris saja tidak cukup karena
+// satu field CSV ber-quote bisa sepanjang apa pun dan tetap di-buffer csv.Reader sampai selesai.
+var ImportMaxBytes int64 = 32 << 20
+
+// ImportRow adalah satu baris data user pada file import
+type ImportRow struct {
+	Name     string `json:"name"`
+	Email    string `json:"email"`
+	Password string `json:"password"`
+}
+
+// ImportRowError menjelaskan kenapa satu baris gagal di-import
+type ImportRowError struct {
+	Row   int    `json:"row"`
+	Email string `json:"email,omitempty"`
+	Error string `json:"error"`
+}
+
+// ImportReport adalah ringkasan hasil import
+type ImportReport struct {
+	Mode     string           `json:"mode"`
+	Format   string           `json:"format"`
+	Total    int              `json:"total"`
+	Imported int              `json:"imported"`
+	Failed   int              `json:"failed"`
+	Errors   []ImportRowError `json:"errors,omitempty"`
+}
+
+// rowReader membaca baris import satu per satu tanpa memuat seluruh body ke memori.
+// Mengembalikan io.EOF kalau data sudah habis.
+type rowReader interface {
+	Next() (ImportRow, int, error)
+}
+
+// rowParseError menandakan satu baris tidak bisa di-parse, tapi baris berikutnya masih bisa dibaca
+type rowParseError struct {
+	err error
+}
+
+func (e *rowParseError) Error() string { return e.err.Error() }
+
+type csvRowReader struct {
+	r       *csv.Reader
+	columns map[string]int
+}
+
+func newCSVRowReader(body io.Reader) (*csvRowReader, error) {
+	r := csv.NewReader(body)
+	r.TrimLeadingSpace = true
+	r.FieldsPerRecord = -1
+
+	header, err := r.Read()
+	if err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			return nil, err
+		}
+		return nil, fmt.Errorf("missing CSV header")
+	}
+
+	columns := make(map[string]int, len(header))
+	for i, h := range header {
+		columns[strings.ToLower(strings.TrimSpace(h))] = i
+	}
+	if _, ok := columns["name"]; !ok {
+		return nil, fmt.Errorf("CSV header must contain a name column")
+	}
+	if _, ok := columns["email"]; !ok {
+		return nil, fmt.Errorf("CSV header must contain an email column")
+	}
+	if _, ok := columns["password"]; !ok {
+		return nil, fmt.Errorf("CSV header must contain a password column")
+	}
+
+	return &csvRowReader{r: r, columns: columns}, nil
+}
+
+func (c *csvRowReader) field(record []string, name string) string {
+	i, ok := c.columns[name]
+	if !ok || i >= len(record) {
+		return ""
+	}
+	return strings.TrimSpace(record[i])
+}
+
+func (c *csvRowReader) Next() (ImportRow, int, error) {
+	record, err := c.r.Read()
+	if err != nil {
+		if err == io.EOF {
+			return ImportRow{}, 0, io.EOF
+		}
+		var perr *csv.ParseError
+		if errors.As(err, &perr) {
+			return ImportRow{}, perr.StartLine, &rowParseError{err: fmt.Errorf("malformed CSV row")}
+		}
+		return ImportRow{}, 0, err
+	}
+
+	line, _ := c.r.FieldPos(0)
+	return ImportRow{
+		Name:     c.field(record, "name"),
+		Email:    c.field(record, "email"),
+		Password: c.field(record, "password"),
+	}, line, nil
+}
+
+type ndjsonRowReader struct {
+	s    *bufio.Scanner
+	line int
+}
+
+func newNDJSONRowReader(body io.Reader) *ndjsonRowReader {
+	s := bufio.NewScanner(body)
+	s.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	return &ndjsonRowReader{s: s}
+}
+
+func (n *ndjsonRowReader) Next() (ImportRow, int, error) {
+	for n.s.Scan() {
+		n.line++
+		line := strings.TrimSpace(n.s.Text())
+		if line == "" {
+			continue
+		}
+
+		var row ImportRow
+		if err := json.Unmarshal([]byte(line), &row); err != nil {
+			return ImportRow{}, n.line, &rowParseError{err: fmt.Errorf("malformed JSON line")}
+		}
+		row.Name = strings.TrimSpace(row.Name)
+		row.Email = strings.TrimSpace(row.Email)
+		return row, n.line, nil
+	}
+	if err := n.s.Err(); err != nil {
+		return ImportRow{}, n.line, err
+	}
+	return ImportRow{}, n.line, io.EOF
+}
+
+// detectFormat menentukan format berdasarkan query ?format= atau header yang diberikan
+func detectFormat(r *http.Request, header string) string {
+	if f := strings.ToLower(r.URL.Query().Get("format")); f != "" {
+		return f
+	}
+	h := strings.ToLower(r.Header.Get(header))
+	if strings.Contains(h, "ndjson") || strings.Contains(h, "jsonl") {
+		return formatNDJSON
+	}
+	return formatCSV
+}
+
+func validateImportRow(row ImportRow) error {
+	if row.Name == "" {
+		return fmt.Errorf("name is required")
+	}
+	if row.Email == "" {
+		return fmt.Errorf("email is required")
+	}
+	// Tidak ada alur undangan atau reset password, jadi user tanpa password tidak akan pernah bisa login
+	if row.Password == "" {
+		return fmt.Errorf("password is required")
+	}
+	if addr, err := mail.ParseAddress(row.Email); err != nil || addr.Address != row.Email {
+		return fmt.Errorf("invalid email")
+	}
+	// Batas bcrypt, dicek di sini supaya hashing paralel tidak perlu melaporkan error per baris
+	if len(row.Password) > 72 {
+		return fmt.Errorf("password must be at most 72 bytes")
+	}
+	return nil
+}
+
+// ImportUsers membuat banyak user sekaligus dari body CSV atau NDJSON.
+//
+// Query parameter:
+//   - format: csv (default) atau ndjson, bisa juga dari Content-Type
+//   - mode: partial (default) menyimpan baris yang valid dan melaporkan yang gagal,
+//     atomic membatalkan seluruh import jika ada satu baris yang gagal
+func ImportUsers(w http.ResponseWriter, r *http.Request) {
+	// Perpanjang deadline server (SERVER_READ_TIMEOUT/SERVER_WRITE_TIMEOUT) untuk upload besar
+	deadline := time.Now().Add(importTimeout)
+	rc := http.NewResponseController(w)
+	rc.SetReadDeadline(deadline)
+	rc.SetWriteDeadline(deadline)
+
+	ctx, cancel := context.WithDeadline(r.Context(), deadline)
+	defer cancel()
+
+	mode := strings.ToLower(r.URL.Query().Get("mode"))
+	if mode == "" {
+		mode = importModePartial
+	}
+	if mode != importModePartial && mode != importModeAtomic {
+		SendError(w, http.StatusBadRequest, "Invalid mode parameter. Must be partial or atomic")
+		return
+	}
+
+	r.Body = http.MaxBytesReader(w, r.Body, ImportMaxBytes)
+
+	format := detectFormat(r, "Content-Type")
+	var reader rowReader
+	switch format {
+	case formatCSV:
+		csvReader, err := newCSVRowReader(r.Body)
+		if importTooLarge(w, err) {
+			return
+		}
+		if err != nil {
+			SendError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		reader = csvReader
+	case formatNDJSON:
+		reader = newNDJSONRowReader(r.Body)
+	default:
+		SendError(w, http.StatusBadRequest, "Invalid format parameter. Must be csv or ndjson")
+		return
+	}
+
+	report := ImportReport{Mode: mode, Format: format}
+	seen := make(map[string]int) // email (lowercase) -> baris pertama yang memakainya
+	var candidates []importCandidate
+
+	addError := func(line int, email string, err error) {
+		report.Failed++
+		report.Errors = append(report.Errors, ImportRowError{Row: line, Email: email, Error: err.Error()})
+	}
+
+	// Baca dan validasi semua baris dulu, baru kemudian disimpan sekaligus dengan COPY
+	for {
+		if ctx.Err() != nil {
+			SendError(w, http.StatusServiceUnavailable, "Import timed out")
+			return
+		}
+
+		row, line, err := reader.Next()
+		if err == io.EOF {
+			break
+		}
+		var perr *rowParseError
+		if errors.As(err, &perr) {
+			report.Total++
+			addError(line, "", perr)
+			continue
+		}
+		if importTooLarge(w, err) {
+			return
+		}
+		if err != nil {
+			slog.ErrorContext(r.Context(), "Error reading import body", "error", err)
+			SendError(w, http.StatusBadRequest, "Error reading request body")
+			return
+		}
+
+		report.Total++
+		if report.Total > maxImportRows {
+			SendError(w, http.StatusRequestEntityTooLarge, fmt.Sprintf("Import is limited to %d rows", maxImportRows))
+			return
+		}
+
+		if err := validateImportRow(row); err != nil {
+			addError(line, row.Email, err)
+			continue
+		}
+
+		key := strings.ToLower(row.Email)
+		if first, ok := seen[key]; ok {
+			addError(line, row.Email, fmt.Errorf("duplicate email in file (first seen on row %d)", first))
+			continue
+		}
+		seen[key] = line
+
+		candidates = append(candidates, importCandidate{line: line, row: row})
+	}
+
+	atomic := mode == importModeAtomic
+	if atomic && report.Failed > 0 {
+		SendErrorWithData(w, http.StatusUnprocessableEntity, "Import aborted, no users were created", report)
+		return
+	}
+
+	if err := hashImportPasswords(ctx, candidates); err != nil {
+		if ctx.Err() != nil {
+			SendError(w, http.StatusServiceUnavailable, "Import timed out")
+			return
+		}
+		slog.ErrorContext(r.Context(), "Error hashing imported passwords", "error", err)
+		SendError(w, http.StatusInternalServerError, "Error hashing password")
+		return
+	}
+
+	ids, err := insertImportedUsers(ctx, candidates, atomic)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Error importing users", "error", err)
+		SendError(w, http.StatusInternalServerError, "Error importing users")
+		return
+	}
+
+	for _, c := range candidates {
+		if _, ok := ids[c.row.Email]; ok {
+			report.Imported++
+		} else {
+			addError(c.line, c.row.Email, fmt.Errorf("email already exists"))
+		}
+	}
+	slices.SortStableFunc(report.Errors, func(a, b ImportRowError) int { return a.Row - b.Row })
+
+	if atomic && report.Failed > 0 {
+		report.Imported = 0
+		SendErrorWithData(w, http.StatusUnprocessableEntity, "Import aborted, no users were created", report)
+		return
+	}
+
+	if report.Imported > 0 {
+		invalidateUserCache(r)
+	}
+
+	audit.Record(r, audit.Entry{
+		Action:       audit.ActionUserImport,
+		ResourceType: audit.ResourceUser,
+		After:        map[string]int{"total": report.Total, "imported": report.Imported, "failed": report.Failed},
+	})
+
+	SendSuccess(w, http.StatusOK, "Users imported successfully", report)
+}
+
+// importTooLarge mengirim 413 jika err berasal dari batas ImportMaxBytes
+func importTooLarge(w http.ResponseWriter, err error) bool {
+	var tooLarge *http.MaxBytesError
+	if !errors.As(err, &tooLarge) {
+		return false
+	}
+	SendError(w, http.StatusRequestEntityTooLarge, fmt.Sprintf("Import body is limited to %d bytes", tooLarge.Limit))
+	return true
+}
+
+// importCandidate adalah baris import yang sudah valid dan siap disimpan
+type importCandidate struct {
+	line     int
+	row      ImportRow
+	password string // Hash bcrypt
+}
+
+// hashImportPasswords meng-hash password semua kandidat. bcrypt sengaja lambat (puluhan ms per hash),
+// jadi hashing dijalankan paralel sebanyak CPU supaya import besar selesai dalam importTimeout,
+// dan berhenti begitu ctx habis.
+func hashImportPasswords(ctx context.Context, candidates []importCandidate) error {
+	g, ctx := errgroup.WithContext(ctx)
+	g.SetLimit(runtime.GOMAXPROCS(0))
+	for i := range candidates {
+		c := &candidates[i]
+		g.Go(func() error {
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+			hashed, err := bcrypt.GenerateFromPassword([]byte(c.row.Password), bcrypt.DefaultCost)
+			if err != nil {
+				return err
+			}
+			c.password = string(hashed)
+			return nil
+		})
+	}
+	return g.Wait()
+}
+
+// insertImportedUsers menyimpan user dengan COPY ke tabel sementara lalu satu INSERT ... SELECT,
+// jauh lebih cepat daripada INSERT per baris. Email yang sudah terdaftar dilewati (ON CONFLICT).
+// Event UserRegistered untuk setiap user baru juga ditulis dengan COPY di transaksi yang sama.
+//
+// Mengembalikan ID user yang dibuat per email. Pada mode atomic transaksi hanya di-commit
+// jika semua baris berhasil dibuat.
+func insertImportedUsers(ctx context.Context, candidates []importCandidate, atomic bool) (map[string]int, error) {
+	ids := make(map[string]int, len(candidates))
+	if len(candidates) == 0 {
+		return ids, nil
+	}
+
+	tx, err := database.Pool.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback(ctx)
+
+	_, err = tx.Exec(ctx, `CREATE TEMP TABLE import_users (line INT, name TEXT, email TEXT, password TEXT) ON COMMIT DROP`)
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = tx.CopyFrom(ctx, pgx.Identifier{"import_users"}, []string{"line", "name", "email", "password"},
+		pgx.CopyFromSlice(len(candidates), func(i int) ([]interface{}, error) {
+			c := candidates[i]
+			return []interface{}{c.line, c.row.Name, c.row.Email, c.password}, nil
+		}))
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := tx.Query(ctx,
+		`INSERT INTO users (name, email, password)
+		SELECT name, email, password FROM import_users ORDER BY line
+		ON CONFLICT (email) DO NOTHING
+		RETURNING id, email`)
+	if err != nil {
+		return nil, err
+	}
+	for rows.Next() {
+		var id int
+		var email string
+		if err := rows.Scan(&id, &email); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		ids[email] = id
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	if atomic && len(ids) < len(candidates) {
+		return ids, nil
+	}
+
+	evts := make([]events.Event, 0, len(ids))
+	for _, c := range candidates {
+		id, ok := ids[c.row.Email]
+		if !ok {
+			continue
+		}
+		e, err := events.New(events.UserRegistered, events.AggregateUser, strconv.Itoa(id), events.UserRegisteredPayload{
+			UserID: id,
+			Name:   c.row.Name,
+			Email:  c.row.Email,
+			Source: events.SourceImport,
+		})
+		if err != nil {
+			return nil, err
+		}
+		evts = append(evts, e)
+	}
+	if err := outbox.CopyEvents(ctx, tx, evts); err != nil {
+		return nil, err
+	}
+
+	return ids, tx.Commit(ctx)
+}
+
+// parseExportFilter membangun klausa WHERE dari query parameter q, created_from dan created_to
+func parseExportFilter(r *http.Request) (string, []interface{}, error) {
+	var conds []string
+	var args []interface{}
+
+	q := r.URL.Query()
+	if search := strings.TrimSpace(q.Get("q")); search != "" {
+		args = append(args, "%"+search+"%")
+		conds = append(conds, fmt.Sprintf("(name ILIKE $%d OR email ILIKE $%d)", len(args), len(args)))
+	}
+	if from := q.Get("created_from"); from != "" {
+		t, err := parseFilterTime(from)
+		if err != nil {
+			return "", nil, fmt.Errorf("Invalid created_from parameter. Use RFC3339 or YYYY-MM-DD")
+		}
+		args = append(args, t)
+		conds = append(conds, fmt.Sprintf("created_at >= $%d", len(args)))
+	}
+	if to := q.Get("created_to"); to != "" {
+		t, err := parseFilterTime(to)
+		if err != nil {
+			return "", nil, fmt.Errorf("Invalid created_to parameter. Use RFC3339 or YYYY-MM-DD")
+		}
+		args = append(args, t)
+		conds = append(conds, fmt.Sprintf("created_at <= $%d", len(args)))
+	}
+
+	if len(conds) == 0 {
+		return "", nil, nil
+	}
+	return " WHERE " + strings.Join(conds, " AND "), args, nil
+}
+
+func parseFilterTime(s string) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, s); err == nil {
+		return t, nil
+	}
+	return time.Parse("2006-01-02", s)
+}
+
+// csvCell mencegah CSV injection: sel yang diawali =, +, -, @, tab atau CR dijalankan sebagai
+// formula saat file dibuka di spreadsheet, jadi diberi awalan ' supaya dibaca sebagai teks
+func csvCell(s string) string {
+	if s != "" && strings.ContainsRune("=+-@\t\r", rune(s[0])) {
+		return "'" + s
+	}
+	return s
+}
+
+// ExportUsers men-stream semua user (bisa difilter) sebagai CSV atau NDJSON.
+// Data dibaca per baris dari database dan langsung ditulis ke response,
+// jadi tidak pernah dimuat seluruhnya ke memori.
+func ExportUsers(w http.ResponseWriter, r *http.Request) {
+	format := detectFormat(r, "Accept")
+	if format != formatCSV && format != formatNDJSON {
+		SendError(w, http.StatusBadRequest, "Invalid format parameter. Must be csv or ndjson")
+		return
+	}
+
+	where, args, err := parseExportFilter(r)
+	if err != nil {
+		SendError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	// Export bisa berjalan lama, jadi deadline tulis server dihapus.
+	// Query tetap berhenti saat client memutus koneksi karena memakai context request.
+	http.NewResponseController(w).SetWriteDeadline(time.Time{})
+
+	rows, err := database.Reader().QueryContext(r.Context(),
+		"SELECT id, name, email, created_at FROM users"+where+" ORDER BY id", args...)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Error exporting users", "error", err)
+		SendError(w, http.StatusInternalServerError, "Error exporting users")
+		return
+	}
+	defer rows.Close()
+
+	filename := "users_" + time.Now().Format("20060102_150405")
+	if format == formatCSV {
+		w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+		w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filename+".csv"))
+	} else {
+		w.Header().Set("Content-Type", "application/x-ndjson")
+		w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filename+".ndjson"))
+	}
+	w.WriteHeader(http.StatusOK)
+
+	flusher, _ := w.(http.Flusher)
+	csvWriter := csv.NewWriter(w)
+	jsonEncoder := json.NewEncoder(w)
+
+	if format == formatCSV {
+		csvWriter.Write([]string{"id", "name", "email", "created_at"})
+	}
+
+	// Setelah header terkirim status code tidak bisa diubah lagi,
+	// jadi error di tengah stream hanya bisa di-log.
+	n := 0
+	for rows.Next() {
+		var (
+			id        int
+			name      string
+			email     string
+			createdAt time.Time
+		)
+		if err := rows.Scan(&id, &name, &email, &createdAt); err != nil {
+			slog.ErrorContext(r.Context(), "Error scanning exported user", "error", err)
+			return
+		}
+
+		if format == formatCSV {
+			err = csvWriter.Write([]string{fmt.Sprint(id), csvCell(name), csvCell(email), createdAt.Format(time.RFC3339)})
+		} else {
+			err = jsonEncoder.Encode(models.User{ID: id, Name: name, Email: email, CreatedAt: createdAt})
+		}
+		if err != nil {
+			slog.ErrorContext(r.Context(), "Error writing exported user", "error", err)
+			return
+		}
+
+		n++
+		if n%exportFlushN == 0 {
+			csvWriter.Flush()
+			if flusher != nil {
+				flusher.Flush()
+			}
+		}
+	}
+	if err := rows.Err(); err != nil {
+		slog.ErrorContext(r.Context(), "Error iterating exported users", "error", err)
+	}
+
+	csvWriter.Flush()
+	if flusher != nil {
+		flusher.Flush()
+	}
+}
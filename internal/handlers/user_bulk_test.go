@@ -0,0 +1,368 @@
+package handlers
+
+import (
+	"betest/internal/database"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+// mockDB mengganti database.DB dengan sqlmock selama test
+func mockDB(t *testing.T) sqlmock.Sqlmock {
+	t.Helper()
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("sqlmock: %v", err)
+	}
+	prev := database.DB
+	database.DB = db
+	t.Cleanup(func() {
+		db.Close()
+		database.DB = prev
+	})
+	return mock
+}
+
+func TestCSVCell(t *testing.T) {
+	tests := map[string]string{
+		"John Doe":                "John Doe",
+		"john@example.com":        "john@example.com",
+		"":                        "",
+		"=HYPERLINK(\"x\",\"y\")": "'=HYPERLINK(\"x\",\"y\")",
+		"+cmd|' /C calc'!A0":      "'+cmd|' /C calc'!A0",
+		"-2+3":                    "'-2+3",
+		"@SUM(A1:A2)":             "'@SUM(A1:A2)",
+		"\t=1":                    "'\t=1",
+		"\r=1":                    "'\r=1",
+		"a=b":                     "a=b",
+	}
+	for in, want := range tests {
+		if got := csvCell(in); got != want {
+			t.Errorf("csvCell(%q) = %q; want %q", in, got, want)
+		}
+	}
+}
+
+func TestExportUsersEscapesFormulas(t *testing.T) {
+	mock := mockDB(t)
+	created := time.Date(2026, 1, 20, 9, 0, 0, 0, time.UTC)
+	mock.ExpectQuery(`SELECT id, name, email, created_at FROM users ORDER BY id`).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "name", "email", "created_at"}).
+			AddRow(1, "=HYPERLINK(\"https://evil.example\",\"klik\")", "a@example.com", created).
+			AddRow(2, "Jane Doe", "+cmd@example.com", created))
+
+	rec := httptest.NewRecorder()
+	ExportUsers(rec, httptest.NewRequest(http.MethodGet, "/api/users/export?format=csv", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d; want %d", rec.Code, http.StatusOK)
+	}
+
+	records, err := csv.NewReader(strings.NewReader(rec.Body.String())).ReadAll()
+	if err != nil {
+		t.Fatalf("parse export: %v", err)
+	}
+	if len(records) != 3 {
+		t.Fatalf("records = %v; want header and 2 rows", records)
+	}
+	if got := records[1][1]; got != "'=HYPERLINK(\"https://evil.example\",\"klik\")" {
+		t.Errorf("name = %q; want escaped formula", got)
+	}
+	if got := records[2][2]; got != "'+cmd@example.com" {
+		t.Errorf("email = %q; want escaped formula", got)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestImportUsersBodyLimit(t *testing.T) {
+	prev := ImportMaxBytes
+	ImportMaxBytes = 1024
+	t.Cleanup(func() { ImportMaxBytes = prev })
+
+	tests := []struct {
+		name        string
+		contentType string
+		body        string
+	}{
+		{"csv header", "text/csv", strings.Repeat("x", 2048)},
+		// Satu field ber-quote yang tidak pernah ditutup, tanpa batas akan di-buffer seluruhnya
+		{"csv quoted field", "text/csv", "name,email,password\n\"" + strings.Repeat("x", 2048)},
+		{"ndjson", "application/x-ndjson", strings.Repeat(`{"name":"a","email":"a@example.com","password":"secret123"}`+"\n", 40)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "/api/users/import", strings.NewReader(tt.body))
+			req.Header.Set("Content-Type", tt.contentType)
+			rec := httptest.NewRecorder()
+			ImportUsers(rec, req)
+			if rec.Code != http.StatusRequestEntityTooLarge {
+				t.Errorf("status = %d; want %d (body %s)", rec.Code, http.StatusRequestEntityTooLarge, rec.Body)
+			}
+		})
+	}
+}
+
+// readAllRows membaca semua baris dari reader; baris yang gagal di-parse dicatat sebagai errLines
+func readAllRows(t *testing.T, reader rowReader) (rows []ImportRow, lines []int, errLines []int) {
+	t.Helper()
+	for {
+		row, line, err := reader.Next()
+		if err == io.EOF {
+			return rows, lines, errLines
+		}
+		var perr *rowParseError
+		if errors.As(err, &perr) {
+			errLines = append(errLines, line)
+			continue
+		}
+		if err != nil {
+			t.Fatalf("Next: %v", err)
+		}
+		rows = append(rows, row)
+		lines = append(lines, line)
+	}
+}
+
+func TestNewCSVRowReaderHeader(t *testing.T) {
+	tests := []struct {
+		name    string
+		body    string
+		wantErr string
+	}{
+		{"empty body", "", "missing CSV header"},
+		{"missing name", "email,password\n", "CSV header must contain a name column"},
+		{"missing email", "name,password\n", "CSV header must contain an email column"},
+		{"missing password", "name,email\nJohn,john@example.com\n", "CSV header must contain a password column"},
+		{"case and spaces", " Name , EMAIL ,Password\n", ""},
+		{"unknown columns", "id,name,email,password,role\n", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := newCSVRowReader(strings.NewReader(tt.body))
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("err = %v; want nil", err)
+				}
+				return
+			}
+			if err == nil || err.Error() != tt.wantErr {
+				t.Errorf("err = %v; want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestCSVRowReaderRows(t *testing.T) {
+	body := "role,email,password,name\n" +
+		"admin, john@example.com ,secret123, John Doe \n" +
+		"user,jane@example.com\n" + // Baris pendek: kolom yang tidak ada dianggap kosong
+		"user,\"broken,secret,x\n" // Quote tidak ditutup
+	reader, err := newCSVRowReader(strings.NewReader(body))
+	if err != nil {
+		t.Fatalf("newCSVRowReader: %v", err)
+	}
+
+	rows, lines, errLines := readAllRows(t, reader)
+	want := []ImportRow{
+		{Name: "John Doe", Email: "john@example.com", Password: "secret123"},
+		{Email: "jane@example.com"},
+	}
+	if !slices.Equal(rows, want) {
+		t.Errorf("rows = %+v; want %+v", rows, want)
+	}
+	if !slices.Equal(lines, []int{2, 3}) {
+		t.Errorf("lines = %v; want [2 3]", lines)
+	}
+	if !slices.Equal(errLines, []int{4}) {
+		t.Errorf("malformed lines = %v; want [4]", errLines)
+	}
+}
+
+func TestNDJSONRowReader(t *testing.T) {
+	body := `{"name": " John Doe ", "email": " john@example.com ", "password": "secret123"}` + "\n" +
+		"\n" +
+		`{"name": "Jane", "email": "jane@example.com", "role": "admin"}` + "\n" +
+		`{"name": "broken"` + "\n"
+
+	rows, lines, errLines := readAllRows(t, newNDJSONRowReader(strings.NewReader(body)))
+	want := []ImportRow{
+		{Name: "John Doe", Email: "john@example.com", Password: "secret123"},
+		{Name: "Jane", Email: "jane@example.com"},
+	}
+	if !slices.Equal(rows, want) {
+		t.Errorf("rows = %+v; want %+v", rows, want)
+	}
+	if !slices.Equal(lines, []int{1, 3}) {
+		t.Errorf("lines = %v; want [1 3]", lines)
+	}
+	if !slices.Equal(errLines, []int{4}) {
+		t.Errorf("malformed lines = %v; want [4]", errLines)
+	}
+}
+
+func TestValidateImportRow(t *testing.T) {
+	tests := []struct {
+		name    string
+		row     ImportRow
+		wantErr string
+	}{
+		{"valid", ImportRow{Name: "John", Email: "john@example.com", Password: "secret123"}, ""},
+		{"missing name", ImportRow{Email: "john@example.com", Password: "secret123"}, "name is required"},
+		{"missing email", ImportRow{Name: "John", Password: "secret123"}, "email is required"},
+		{"missing password", ImportRow{Name: "John", Email: "john@example.com"}, "password is required"},
+		{"invalid email", ImportRow{Name: "John", Email: "John <john@example.com>", Password: "secret123"}, "invalid email"},
+		{"password too long", ImportRow{Name: "John", Email: "john@example.com", Password: strings.Repeat("x", 73)}, "password must be at most 72 bytes"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validateImportRow(tt.row)
+			if got := fmt.Sprint(err); (tt.wantErr == "" && err != nil) || (tt.wantErr != "" && got != tt.wantErr) {
+				t.Errorf("err = %v; want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+// importResponse adalah response ImportUsers yang sudah di-decode
+type importResponse struct {
+	Error string       `json:"error"`
+	Data  ImportReport `json:"data"`
+}
+
+func postImport(t *testing.T, query, contentType, body string) (int, importResponse) {
+	t.Helper()
+	req := httptest.NewRequest(http.MethodPost, "/api/users/import"+query, strings.NewReader(body))
+	req.Header.Set("Content-Type", contentType)
+	rec := httptest.NewRecorder()
+	ImportUsers(rec, req)
+
+	var resp importResponse
+	if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+		t.Fatalf("decode response: %v", err)
+	}
+	return rec.Code, resp
+}
+
+// Test import berikut tidak pernah sampai ke database.Pool (nil di test): baris yang valid hanya
+// disimpan pada mode partial atau mode atomic tanpa baris gagal
+func TestImportUsersReport(t *testing.T) {
+	tests := []struct {
+		name        string
+		query       string
+		contentType string
+		body        string
+		wantStatus  int
+		wantTotal   int
+		wantErrors  []ImportRowError
+	}{
+		{
+			name:        "atomic aborts on a row without password",
+			query:       "?mode=atomic",
+			contentType: "text/csv",
+			body:        "name,email,password\nJohn,john@example.com,secret123\nJane,jane@example.com,\n",
+			wantStatus:  http.StatusUnprocessableEntity,
+			wantTotal:   2,
+			wantErrors:  []ImportRowError{{Row: 3, Email: "jane@example.com", Error: "password is required"}},
+		},
+		{
+			name:        "atomic aborts on a duplicate email in the file",
+			query:       "?mode=atomic",
+			contentType: "application/x-ndjson",
+			body: `{"name":"John","email":"john@example.com","password":"secret123"}` + "\n" +
+				`{"name":"John 2","email":"JOHN@example.com","password":"secret456"}` + "\n",
+			wantStatus: http.StatusUnprocessableEntity,
+			wantTotal:  2,
+			wantErrors: []ImportRowError{{Row: 2, Email: "JOHN@example.com", Error: "duplicate email in file (first seen on row 1)"}},
+		},
+		{
+			name:        "atomic aborts on a malformed row",
+			query:       "?mode=atomic&format=ndjson",
+			contentType: "text/plain",
+			body:        `{"name":"John","email":"john@example.com","password":"secret123"}` + "\n" + "not json\n",
+			wantStatus:  http.StatusUnprocessableEntity,
+			wantTotal:   2,
+			wantErrors:  []ImportRowError{{Row: 2, Error: "malformed JSON line"}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			status, resp := postImport(t, tt.query, tt.contentType, tt.body)
+			if status != tt.wantStatus {
+				t.Fatalf("status = %d; want %d (%s)", status, tt.wantStatus, resp.Error)
+			}
+			report := resp.Data
+			if report.Total != tt.wantTotal || report.Imported != 0 || report.Failed != len(tt.wantErrors) {
+				t.Errorf("report = %+v; want total %d, imported 0, failed %d", report, tt.wantTotal, len(tt.wantErrors))
+			}
+			if !slices.Equal(report.Errors, tt.wantErrors) {
+				t.Errorf("errors = %+v; want %+v", report.Errors, tt.wantErrors)
+			}
+		})
+	}
+}
+
+func TestImportUsersPartialReportsInvalidRows(t *testing.T) {
+	mock := mockDB(t)
+	mock.ExpectExec(`INSERT INTO audit_log`).WillReturnResult(sqlmock.NewResult(1, 1))
+
+	body := "name,email,password\n,nobody@example.com,secret123\nJane,jane@example.com,\nJoe,not-an-email,secret123\n"
+	status, resp := postImport(t, "", "text/csv", body)
+	if status != http.StatusOK {
+		t.Fatalf("status = %d; want %d (%s)", status, http.StatusOK, resp.Error)
+	}
+	want := []ImportRowError{
+		{Row: 2, Email: "nobody@example.com", Error: "name is required"},
+		{Row: 3, Email: "jane@example.com", Error: "password is required"},
+		{Row: 4, Email: "not-an-email", Error: "invalid email"},
+	}
+	if resp.Data.Mode != importModePartial || resp.Data.Total != 3 || resp.Data.Failed != 3 || !slices.Equal(resp.Data.Errors, want) {
+		t.Errorf("report = %+v; want 3 failed rows %+v", resp.Data, want)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestImportUsersRowLimit(t *testing.T) {
+	var b strings.Builder
+	b.WriteString("name,email,password\n")
+	for i := range maxImportRows + 1 {
+		// Tanpa password supaya tidak ada bcrypt; batas baris dicek sebelum validasi
+		fmt.Fprintf(&b, "User %d,user%d@example.com,\n", i, i)
+	}
+
+	status, resp := postImport(t, "", "text/csv", b.String())
+	if status != http.StatusRequestEntityTooLarge {
+		t.Errorf("status = %d; want %d (%s)", status, http.StatusRequestEntityTooLarge, resp.Error)
+	}
+}
+
+func TestImportUsersInvalidParams(t *testing.T) {
+	tests := []struct {
+		name  string
+		query string
+		body  string
+	}{
+		{"unknown mode", "?mode=all", "name,email,password\n"},
+		{"unknown format", "?format=xml", "<users/>"},
+		{"missing header", "", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if status, resp := postImport(t, tt.query, "text/csv", tt.body); status != http.StatusBadRequest {
+				t.Errorf("status = %d; want %d (%s)", status, http.StatusBadRequest, resp.Error)
+			}
+		})
+	}
+}
@@ -0,0 +1,50 @@
+package handlers
+
+import (
+	"betest/internal/cache"
+	"betest/internal/database"
+	"betest/internal/models"
+	"context"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// Cache read-through untuk data user, di-set dari main. Nil berarti cache nonaktif.
+var (
+	UserCache     *cache.Cache // User per ID
+	UserListCache *cache.Cache // Halaman GET /api/users, di-invalidate lewat versi
+
+	// CacheReplicaLag adalah jeda invalidation kedua setelah write jika ada read replica
+	CacheReplicaLag time.Duration
+)
+
+// userPage adalah isi cache satu halaman list user
+type userPage struct {
+	Users []models.User `json:"users"`
+	Total int           `json:"total"`
+}
+
+// invalidateUserCache dipanggil setelah transaksi yang mengubah user commit.
+// Halaman list selalu di-invalidate karena urutan dan total ikut berubah.
+func invalidateUserCache(r *http.Request, ids ...int) {
+	// Tetap dijalankan walaupun client sudah memutus koneksi, supaya cache tidak usang
+	ctx := context.WithoutCancel(r.Context())
+
+	keys := make([]string, len(ids))
+	for i, id := range ids {
+		keys[i] = strconv.Itoa(id)
+	}
+	invalidate := func() {
+		UserCache.Delete(ctx, keys...)
+		UserListCache.Bump(ctx)
+	}
+	invalidate()
+
+	// Cache diisi dari read replica. Replica yang tertinggal bisa masih mengembalikan data lama
+	// sesaat setelah commit dan hasilnya tersimpan lagi di cache, jadi cache di-invalidate sekali
+	// lagi setelah perkiraan lag replica.
+	if len(database.Replicas) > 0 && CacheReplicaLag > 0 {
+		time.AfterFunc(CacheReplicaLag, invalidate)
+	}
+}
@@ -0,0 +1,348 @@
+package handlers
+
+import (
+	"betest/internal/audit"
+	"betest/internal/database"
+	"betest/internal/events"
+	"betest/internal/models"
+	"betest/internal/response"
+	"crypto/rand"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strconv"
+
+	"github.com/gorilla/mux"
+)
+
+// supportedWebhookEvents adalah event yang boleh dipakai sebagai filter subscription
+var supportedWebhookEvents = map[string]bool{
+	events.UserRegistered: true,
+	events.UserUpdated:    true,
+	events.UserDeleted:    true,
+	events.UserLoggedIn:   true,
+}
+
+type WebhookRequest struct {
+	URL    string   `json:"url"`
+	Secret string   `json:"secret"`
+	Events []string `json:"events"`
+	Active *bool    `json:"active"`
+}
+
+func (req *WebhookRequest) validate() error {
+	u, err := url.Parse(req.URL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("Invalid url. Must be an absolute http or https URL")
+	}
+	if req.Secret != "" && len(req.Secret) < 16 {
+		return fmt.Errorf("Secret must be at least 16 characters")
+	}
+	for _, e := range req.Events {
+		if !supportedWebhookEvents[e] {
+			return fmt.Errorf("Unsupported event %q", e)
+		}
+	}
+	return nil
+}
+
+func generateWebhookSecret() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return "whsec_" + hex.EncodeToString(b), nil
+}
+
+const webhookColumns = "id, url, events, active, consecutive_failures, disabled_at, created_at, updated_at"
+
+func scanWebhook(row interface{ Scan(...interface{}) error }) (models.Webhook, error) {
+	var wh models.Webhook
+	var eventsJSON []byte
+	err := row.Scan(&wh.ID, &wh.URL, &eventsJSON, &wh.Active, &wh.ConsecutiveFailures, &wh.DisabledAt, &wh.CreatedAt, &wh.UpdatedAt)
+	if err != nil {
+		return wh, err
+	}
+	err = json.Unmarshal(eventsJSON, &wh.Events)
+	return wh, err
+}
+
+func GetWebhooks(w http.ResponseWriter, r *http.Request) {
+	page, limit, err := parsePagination(r)
+	if err != nil {
+		SendError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	ctx, cancel := database.WithTimeout(r.Context())
+	defer cancel()
+
+	// Count dan list membaca dari replica yang sama supaya konsisten
+	db := database.Reader()
+
+	var total int
+	if err := db.QueryRowContext(ctx, "SELECT COUNT(*) FROM webhooks").Scan(&total); err != nil {
+		slog.ErrorContext(r.Context(), "Error counting webhooks", "error", err)
+		SendError(w, http.StatusInternalServerError, "Error fetching webhooks count")
+		return
+	}
+
+	rows, err := db.QueryContext(ctx, "SELECT "+webhookColumns+" FROM webhooks ORDER BY id LIMIT $1 OFFSET $2", limit, (page-1)*limit)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Error fetching webhooks", "error", err)
+		SendError(w, http.StatusInternalServerError, "Error fetching webhooks")
+		return
+	}
+	defer rows.Close()
+
+	webhooks := []models.Webhook{}
+	for rows.Next() {
+		wh, err := scanWebhook(rows)
+		if err != nil {
+			slog.ErrorContext(r.Context(), "Error scanning webhook", "error", err)
+			SendError(w, http.StatusInternalServerError, "Error scanning webhook data")
+			return
+		}
+		webhooks = append(webhooks, wh)
+	}
+
+	response.SendPaginatedSuccess(w, http.StatusOK, "Webhooks retrieved successfully", webhooks, paginationMeta(page, limit, total))
+}
+
+func GetWebhook(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		SendError(w, http.StatusBadRequest, "Invalid ID")
+		return
+	}
+
+	ctx, cancel := database.WithTimeout(r.Context())
+	defer cancel()
+
+	wh, err := scanWebhook(database.Reader().QueryRowContext(ctx, "SELECT "+webhookColumns+" FROM webhooks WHERE id=$1", id))
+	if err == sql.ErrNoRows {
+		SendError(w, http.StatusNotFound, "Webhook not found")
+		return
+	}
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Error getting webhook", "webhook_id", id, "error", err)
+		SendError(w, http.StatusInternalServerError, "Error fetching webhook")
+		return
+	}
+
+	SendSuccess(w, http.StatusOK, "Webhook retrieved successfully", wh)
+}
+
+// CreateWebhook mendaftarkan endpoint baru. Jika secret tidak dikirim, secret dibuat otomatis.
+// Secret hanya dikembalikan di response ini.
+func CreateWebhook(w http.ResponseWriter, r *http.Request) {
+	var req WebhookRequest
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+	if err := req.validate(); err != nil {
+		SendError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	if req.Secret == "" {
+		secret, err := generateWebhookSecret()
+		if err != nil {
+			SendError(w, http.StatusInternalServerError, "Error generating secret")
+			return
+		}
+		req.Secret = secret
+	}
+	if req.Events == nil {
+		req.Events = []string{}
+	}
+	active := true
+	if req.Active != nil {
+		active = *req.Active
+	}
+
+	ctx, cancel := database.WithTimeout(r.Context())
+	defer cancel()
+
+	eventsJSON, _ := json.Marshal(req.Events)
+	wh, err := scanWebhook(database.DB.QueryRowContext(ctx,
+		"INSERT INTO webhooks (url, secret, events, active) VALUES ($1, $2, $3, $4) RETURNING "+webhookColumns,
+		req.URL, req.Secret, string(eventsJSON), active))
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Error creating webhook", "error", err)
+		SendError(w, http.StatusInternalServerError, "Error creating webhook")
+		return
+	}
+
+	audit.Record(r, audit.Entry{
+		Action:       audit.ActionWebhookCreate,
+		ResourceType: audit.ResourceWebhook,
+		ResourceID:   strconv.Itoa(wh.ID),
+		After:        wh,
+	})
+
+	wh.Secret = req.Secret
+
+	SendSuccess(w, http.StatusCreated, "Webhook created successfully", wh)
+}
+
+// UpdateWebhook mengganti url, events, secret (opsional) dan status aktif.
+// Mengaktifkan kembali webhook yang dinonaktifkan otomatis akan me-reset hitungan kegagalan.
+func UpdateWebhook(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		SendError(w, http.StatusBadRequest, "Invalid ID")
+		return
+	}
+
+	var req WebhookRequest
+	if !decodeJSON(w, r, &req) {
+		return
+	}
+	if err := req.validate(); err != nil {
+		SendError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if req.Events == nil {
+		req.Events = []string{}
+	}
+
+	ctx, cancel := database.WithTimeout(r.Context())
+	defer cancel()
+
+	before, err := scanWebhook(database.DB.QueryRowContext(ctx, "SELECT "+webhookColumns+" FROM webhooks WHERE id=$1", id))
+	if err == sql.ErrNoRows {
+		SendError(w, http.StatusNotFound, "Webhook not found")
+		return
+	}
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Error getting webhook", "webhook_id", id, "error", err)
+		SendError(w, http.StatusInternalServerError, "Error updating webhook")
+		return
+	}
+
+	eventsJSON, _ := json.Marshal(req.Events)
+	wh, err := scanWebhook(database.DB.QueryRowContext(ctx,
+		`UPDATE webhooks SET
+			url = $2,
+			events = $3,
+			secret = COALESCE(NULLIF($4, ''), secret),
+			active = COALESCE($5, active),
+			consecutive_failures = CASE WHEN COALESCE($5, active) AND NOT active THEN 0 ELSE consecutive_failures END,
+			disabled_at = CASE WHEN COALESCE($5, active) THEN NULL ELSE disabled_at END,
+			updated_at = NOW()
+		WHERE id = $1
+		RETURNING `+webhookColumns,
+		id, req.URL, string(eventsJSON), req.Secret, req.Active))
+	if err == sql.ErrNoRows {
+		SendError(w, http.StatusNotFound, "Webhook not found")
+		return
+	}
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Error updating webhook", "webhook_id", id, "error", err)
+		SendError(w, http.StatusInternalServerError, "Error updating webhook")
+		return
+	}
+
+	audit.Record(r, audit.Entry{
+		Action:       audit.ActionWebhookUpdate,
+		ResourceType: audit.ResourceWebhook,
+		ResourceID:   strconv.Itoa(id),
+		Before:       before,
+		After:        wh,
+	})
+
+	SendSuccess(w, http.StatusOK, "Webhook updated successfully", wh)
+}
+
+func DeleteWebhook(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		SendError(w, http.StatusBadRequest, "Invalid ID")
+		return
+	}
+
+	ctx, cancel := database.WithTimeout(r.Context())
+	defer cancel()
+
+	before, err := scanWebhook(database.DB.QueryRowContext(ctx, "DELETE FROM webhooks WHERE id=$1 RETURNING "+webhookColumns, id))
+	if err == sql.ErrNoRows {
+		SendError(w, http.StatusNotFound, "Webhook not found")
+		return
+	}
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Error deleting webhook", "webhook_id", id, "error", err)
+		SendError(w, http.StatusInternalServerError, "Error deleting webhook")
+		return
+	}
+
+	audit.Record(r, audit.Entry{
+		Action:       audit.ActionWebhookDelete,
+		ResourceType: audit.ResourceWebhook,
+		ResourceID:   strconv.Itoa(id),
+		Before:       before,
+	})
+
+	SendSuccessNoData(w, http.StatusOK, "Webhook deleted successfully")
+}
+
+// GetWebhookDeliveries menampilkan riwayat delivery sebuah webhook (terbaru dulu)
+func GetWebhookDeliveries(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		SendError(w, http.StatusBadRequest, "Invalid ID")
+		return
+	}
+
+	page, limit, err := parsePagination(r)
+	if err != nil {
+		SendError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	ctx, cancel := database.WithTimeout(r.Context())
+	defer cancel()
+
+	// Count dan list membaca dari replica yang sama supaya konsisten
+	db := database.Reader()
+
+	var total int
+	if err := db.QueryRowContext(ctx, "SELECT COUNT(*) FROM webhook_deliveries WHERE webhook_id=$1", id).Scan(&total); err != nil {
+		slog.ErrorContext(r.Context(), "Error counting webhook deliveries", "error", err)
+		SendError(w, http.StatusInternalServerError, "Error fetching webhook deliveries count")
+		return
+	}
+
+	rows, err := db.QueryContext(ctx,
+		`SELECT id, webhook_id, event_id, event_type, payload, status, attempts, next_attempt_at,
+			last_status_code, last_error, created_at, delivered_at
+		FROM webhook_deliveries WHERE webhook_id=$1 ORDER BY id DESC LIMIT $2 OFFSET $3`,
+		id, limit, (page-1)*limit)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Error fetching webhook deliveries", "error", err)
+		SendError(w, http.StatusInternalServerError, "Error fetching webhook deliveries")
+		return
+	}
+	defer rows.Close()
+
+	deliveries := []models.WebhookDelivery{}
+	for rows.Next() {
+		var d models.WebhookDelivery
+		var payload []byte
+		err := rows.Scan(&d.ID, &d.WebhookID, &d.EventID, &d.EventType, &payload, &d.Status, &d.Attempts, &d.NextAttemptAt,
+			&d.LastStatusCode, &d.LastError, &d.CreatedAt, &d.DeliveredAt)
+		if err != nil {
+			slog.ErrorContext(r.Context(), "Error scanning webhook delivery", "error", err)
+			SendError(w, http.StatusInternalServerError, "Error scanning webhook delivery data")
+			return
+		}
+		d.Payload = payload
+		deliveries = append(deliveries, d)
+	}
+
+	response.SendPaginatedSuccess(w, http.StatusOK, "Webhook deliveries retrieved successfully", deliveries, paginationMeta(page, limit, total))
+}
@@ -0,0 +1,113 @@
+package metrics
+
+import (
+	"net/http"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const namespace = "betest"
+
+// Registry khusus aplikasi (bukan default global) supaya isi /metrics terkontrol
+var Registry = prometheus.NewRegistry()
+
+// Metric HTTP (RED: rate, errors, duration) per route template gorilla/mux
+var (
+	HTTPRequestsTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "http_requests_total",
+		Help:      "Total HTTP requests by method, route template and status code.",
+	}, []string{"method", "route", "status"})
+
+	HTTPRequestDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "http_request_duration_seconds",
+		Help:      "HTTP request latency by method, route template and status code.",
+		Buckets:   []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10},
+	}, []string{"method", "route", "status"})
+
+	HTTPRequestsInFlight = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "http_requests_in_flight",
+		Help:      "HTTP requests currently being served by method and route template.",
+	}, []string{"method", "route"})
+)
+
+// Metric khusus auth
+var (
+	LoginsTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "auth",
+		Name:      "logins_total",
+		Help:      "Login attempts by result (success, failure).",
+	}, []string{"result"})
+
+	TokenRefreshesTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "auth",
+		Name:      "token_refreshes_total",
+		Help:      "Refresh token requests by result (success, failure, reuse).",
+	}, []string{"result"})
+
+	RevokedTokenHitsTotal = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "auth",
+		Name:      "revoked_token_hits_total",
+		Help:      "Requests rejected by JWTMiddleware because the access token is blacklisted.",
+	})
+)
+
+// Metric cache read-through
+var (
+	CacheRequestsTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "cache",
+		Name:      "requests_total",
+		Help:      "Cache lookups by cache name and result (hit, miss, early_refresh, error).",
+	}, []string{"cache", "result"})
+)
+
+// Nilai label result
+const (
+	ResultSuccess = "success"
+	ResultFailure = "failure"
+	ResultReuse   = "reuse"
+
+	ResultHit          = "hit"
+	ResultMiss         = "miss"
+	ResultEarlyRefresh = "early_refresh"
+	ResultError        = "error"
+)
+
+func init() {
+	Registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		HTTPRequestsTotal,
+		HTTPRequestDuration,
+		HTTPRequestsInFlight,
+		LoginsTotal,
+		TokenRefreshesTotal,
+		RevokedTokenHitsTotal,
+		CacheRequestsTotal,
+	)
+}
+
+// RegisterDBPoolStats mengekspos statistik pool pgx (acquire, in use, idle, dst)
+func RegisterDBPoolStats(pool *pgxpool.Pool, name string) {
+	Registry.MustRegister(newPgxPoolCollector(pool, name))
+}
+
+// RegisterRedisStats mengekspos statistik pool koneksi Redis
+func RegisterRedisStats(rdb redis.UniversalClient, name string) {
+	Registry.MustRegister(newRedisPoolCollector(rdb, name))
+}
+
+// Handler adalah handler untuk endpoint /metrics
+func Handler() http.Handler {
+	return promhttp.HandlerFor(Registry, promhttp.HandlerOpts{Registry: Registry})
+}
@@ -0,0 +1,92 @@
+package middleware
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// responseRecorder mencatat status code dan jumlah byte yang ditulis handler
+type responseRecorder struct {
+	http.ResponseWriter
+	status int
+	bytes  int
+}
+
+func (rw *responseRecorder) WriteHeader(status int) {
+	if rw.status == 0 {
+		rw.status = status
+	}
+	rw.ResponseWriter.WriteHeader(status)
+}
+
+func (rw *responseRecorder) Write(b []byte) (int, error) {
+	if rw.status == 0 {
+		rw.status = http.StatusOK
+	}
+	n, err := rw.ResponseWriter.Write(b)
+	rw.bytes += n
+	return n, err
+}
+
+// Flush diteruskan supaya response streaming (misalnya export) tetap bisa di-flush
+func (rw *responseRecorder) Flush() {
+	if f, ok := rw.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+func (rw *responseRecorder) Unwrap() http.ResponseWriter {
+	return rw.ResponseWriter
+}
+
+// routeTemplate mengembalikan template route gorilla/mux (misalnya /api/users/{id})
+// supaya log dan metric tidak meledak karena ID di path
+func routeTemplate(router *mux.Router, r *http.Request) string {
+	var match mux.RouteMatch
+	if router.Match(r, &match) && match.Route != nil {
+		if tmpl, err := match.Route.GetPathTemplate(); err == nil {
+			return tmpl
+		}
+	}
+	return "unmatched"
+}
+
+// AccessLog mencatat satu baris log per request berisi method, route, status, latency, bytes dan user_id.
+// Dipasang di luar router supaya request 404/405 juga tercatat.
+func AccessLog(router *mux.Router) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			start := time.Now()
+			state := &requestState{}
+			rec := &responseRecorder{ResponseWriter: w}
+
+			next.ServeHTTP(rec, r.WithContext(context.WithValue(r.Context(), requestStateKey, state)))
+
+			if rec.status == 0 {
+				rec.status = http.StatusOK
+			}
+
+			attrs := []slog.Attr{
+				slog.String("method", r.Method),
+				slog.String("route", routeTemplate(router, r)),
+				slog.String("path", r.URL.Path),
+				slog.Int("status", rec.status),
+				slog.Float64("latency_ms", float64(time.Since(start).Microseconds())/1000),
+				slog.Int("bytes", rec.bytes),
+			}
+			if userID := state.userID.Load(); userID != 0 {
+				attrs = append(attrs, slog.Int64("user_id", userID))
+			}
+
+			level := slog.LevelInfo
+			if rec.status >= 500 {
+				level = slog.LevelError
+			}
+			slog.LogAttrs(r.Context(), level, "http request", attrs...)
+		})
+	}
+}
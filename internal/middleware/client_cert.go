@@ -0,0 +1,31 @@
+package middleware
+
+import (
+	"betest/internal/logger"
+	"context"
+	"crypto/x509/pkix"
+	"log/slog"
+	"net/http"
+)
+
+const clientCertKey contextKey = "client_cert"
+
+// ClientCert menyimpan subject client certificate yang sudah terverifikasi (mTLS) di context
+// dan di attribute log. Request tanpa client certificate (atau lewat HTTP biasa) diteruskan apa adanya.
+func ClientCert(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.TLS != nil && len(r.TLS.VerifiedChains) > 0 && len(r.TLS.VerifiedChains[0]) > 0 {
+			subject := r.TLS.VerifiedChains[0][0].Subject
+			ctx := context.WithValue(r.Context(), clientCertKey, subject)
+			ctx = logger.WithAttrs(ctx, slog.String("client_cert_subject", subject.String()))
+			r = r.WithContext(ctx)
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// ClientCertSubject mengambil subject client certificate yang disimpan ClientCert di context
+func ClientCertSubject(ctx context.Context) (pkix.Name, bool) {
+	subject, ok := ctx.Value(clientCertKey).(pkix.Name)
+	return subject, ok
+}
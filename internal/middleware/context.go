@@ -0,0 +1,63 @@
+package middleware
+
+import (
+	"betest/internal/logger"
+	"betest/internal/token"
+	"context"
+	"log/slog"
+	"net/http"
+	"sync/atomic"
+)
+
+type contextKey string
+
+const (
+	requestIDKey    contextKey = "request_id"
+	requestStateKey contextKey = "request_state"
+	userIDKey       contextKey = "user_id"
+	claimsKey       contextKey = "claims"
+)
+
+// requestState dipasang oleh AccessLog agar middleware di dalamnya (misalnya JWTMiddleware)
+// bisa mengisi informasi yang perlu ikut dicatat di access log.
+// Field-nya atomic karena handler yang melewati Timeout tetap berjalan di goroutine sendiri
+// setelah AccessLog selesai menunggu dan membaca state ini.
+type requestState struct {
+	userID atomic.Int64
+}
+
+// UserID mengambil user_id yang disimpan JWTMiddleware di context
+func UserID(ctx context.Context) (int, bool) {
+	userID, ok := ctx.Value(userIDKey).(int)
+	return userID, ok
+}
+
+// TokenClaims mengambil claim access token yang sudah divalidasi JWTMiddleware
+func TokenClaims(ctx context.Context) (*token.Claims, bool) {
+	claims, ok := ctx.Value(claimsKey).(*token.Claims)
+	return claims, ok
+}
+
+// RequestID mengambil request ID yang disimpan RequestIDMiddleware di context
+func RequestID(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey).(string)
+	return id
+}
+
+// withUserID menyimpan user_id di context request, di access log dan di attribute log
+func withUserID(r *http.Request, userID int) *http.Request {
+	if state, ok := r.Context().Value(requestStateKey).(*requestState); ok {
+		state.userID.Store(int64(userID))
+	}
+	ctx := context.WithValue(r.Context(), userIDKey, userID)
+	ctx = logger.WithAttrs(ctx, slog.Int("user_id", userID))
+	return r.WithContext(ctx)
+}
+
+// withClaims menyimpan claim access token dan user_id-nya (jika token mewakili user) di context request
+func withClaims(r *http.Request, claims *token.Claims) *http.Request {
+	if claims.UserID > 0 {
+		r = withUserID(r, claims.UserID)
+	}
+	return r.WithContext(context.WithValue(r.Context(), claimsKey, claims))
+}
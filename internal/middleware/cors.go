@@ -0,0 +1,158 @@
+package middleware
+
+import (
+	"betest/internal/config"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// CORS menambahkan header CORS untuk origin yang diizinkan dan menjawab preflight (OPTIONS
+// dengan Access-Control-Request-Method) langsung dengan 204 tanpa masuk ke router.
+// Origin "*" menjawab dengan "Access-Control-Allow-Origin: *" tanpa credentials, karena browser
+// menolak kombinasi wildcard dan cookie; origin lain dikembalikan apa adanya jika cocok.
+// Jika CORS_ALLOWED_ORIGINS kosong middleware tidak melakukan apa-apa.
+func CORS(cfg config.CORSConfig) func(http.Handler) http.Handler {
+	c := newCORS(cfg)
+	return func(next http.Handler) http.Handler {
+		if len(cfg.AllowedOrigins) == 0 {
+			return next
+		}
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.Method == http.MethodOptions && r.Header.Get("Access-Control-Request-Method") != "" {
+				c.preflight(w, r)
+				return
+			}
+			c.actual(w, r)
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+type cors struct {
+	anyOrigin     bool
+	origins       []string // Origin persis, lowercase
+	wildcards     [][2]string
+	methods       []string
+	anyHeader     bool
+	headers       []string // Lowercase
+	allowMethods  string
+	allowHeaders  string
+	exposeHeaders string
+	credentials   bool
+	maxAge        string
+}
+
+func newCORS(cfg config.CORSConfig) *cors {
+	c := &cors{
+		methods:       cfg.AllowedMethods,
+		allowMethods:  strings.Join(cfg.AllowedMethods, ", "),
+		allowHeaders:  strings.Join(cfg.AllowedHeaders, ", "),
+		exposeHeaders: strings.Join(cfg.ExposedHeaders, ", "),
+		credentials:   cfg.AllowCredentials,
+	}
+	if cfg.MaxAge > 0 {
+		c.maxAge = strconv.Itoa(int(cfg.MaxAge.Seconds()))
+	}
+	for _, o := range cfg.AllowedOrigins {
+		o = strings.ToLower(o)
+		switch {
+		case o == "*":
+			c.anyOrigin = true
+		case strings.Contains(o, "*"):
+			// "https://*.example.com" dipecah menjadi prefix "https://" dan suffix ".example.com"
+			prefix, suffix, _ := strings.Cut(o, "*")
+			c.wildcards = append(c.wildcards, [2]string{prefix, suffix})
+		default:
+			c.origins = append(c.origins, o)
+		}
+	}
+	for _, h := range cfg.AllowedHeaders {
+		if h == "*" {
+			c.anyHeader = true
+		}
+		c.headers = append(c.headers, strings.ToLower(h))
+	}
+	return c
+}
+
+// allowOrigin mengembalikan nilai Access-Control-Allow-Origin untuk origin request, atau "" jika ditolak
+func (c *cors) allowOrigin(origin string) string {
+	if origin == "" {
+		return ""
+	}
+	o := strings.ToLower(origin)
+	if slices.Contains(c.origins, o) {
+		return origin
+	}
+	for _, w := range c.wildcards {
+		// Bagian wildcard minimal satu karakter, jadi "https://example.com" tidak cocok dengan "https://*.example.com"
+		if len(o) > len(w[0])+len(w[1]) && strings.HasPrefix(o, w[0]) && strings.HasSuffix(o, w[1]) {
+			return origin
+		}
+	}
+	if c.anyOrigin {
+		return "*"
+	}
+	return ""
+}
+
+func (c *cors) setOrigin(h http.Header, allowed string) {
+	h.Set("Access-Control-Allow-Origin", allowed)
+	if allowed != "*" && c.credentials {
+		h.Set("Access-Control-Allow-Credentials", "true")
+	}
+}
+
+func (c *cors) actual(w http.ResponseWriter, r *http.Request) {
+	h := w.Header()
+	h.Add("Vary", "Origin")
+	allowed := c.allowOrigin(r.Header.Get("Origin"))
+	if allowed == "" {
+		return
+	}
+	c.setOrigin(h, allowed)
+	if c.exposeHeaders != "" {
+		h.Set("Access-Control-Expose-Headers", c.exposeHeaders)
+	}
+}
+
+func (c *cors) preflight(w http.ResponseWriter, r *http.Request) {
+	h := w.Header()
+	h.Add("Vary", "Origin")
+	h.Add("Vary", "Access-Control-Request-Method")
+	h.Add("Vary", "Access-Control-Request-Headers")
+
+	// Preflight yang ditolak tetap dijawab 204 tanpa header CORS, browser yang akan memblokir request-nya
+	defer w.WriteHeader(http.StatusNoContent)
+
+	allowed := c.allowOrigin(r.Header.Get("Origin"))
+	if allowed == "" {
+		return
+	}
+	if !slices.Contains(c.methods, r.Header.Get("Access-Control-Request-Method")) {
+		return
+	}
+	requested := r.Header.Get("Access-Control-Request-Headers")
+	if !c.anyHeader {
+		for _, name := range strings.Split(requested, ",") {
+			name = strings.ToLower(strings.TrimSpace(name))
+			if name != "" && !slices.Contains(c.headers, name) {
+				return
+			}
+		}
+	}
+
+	c.setOrigin(h, allowed)
+	h.Set("Access-Control-Allow-Methods", c.allowMethods)
+	if c.anyHeader {
+		// "*" tidak dianggap wildcard oleh browser jika credentials diizinkan, jadi header yang diminta dikembalikan
+		h.Set("Access-Control-Allow-Headers", requested)
+	} else if c.allowHeaders != "" {
+		h.Set("Access-Control-Allow-Headers", c.allowHeaders)
+	}
+	if c.maxAge != "" {
+		h.Set("Access-Control-Max-Age", c.maxAge)
+	}
+}
@@ -0,0 +1,159 @@
+package middleware
+
+import (
+	"betest/internal/config"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestCORSAllowOrigin(t *testing.T) {
+	tests := []struct {
+		name    string
+		origins []string
+		origin  string
+		want    string
+	}{
+		{"exact", []string{"https://app.example.com"}, "https://app.example.com", "https://app.example.com"},
+		{"exact is case insensitive", []string{"https://App.Example.com"}, "https://app.EXAMPLE.com", "https://app.EXAMPLE.com"},
+		{"other origin", []string{"https://app.example.com"}, "https://evil.example.com", ""},
+		{"other scheme", []string{"https://app.example.com"}, "http://app.example.com", ""},
+		{"other port", []string{"https://app.example.com"}, "https://app.example.com:8443", ""},
+		{"no origin", []string{"https://app.example.com"}, "", ""},
+		{"wildcard subdomain", []string{"https://*.example.com"}, "https://app.example.com", "https://app.example.com"},
+		{"wildcard nested subdomain", []string{"https://*.example.com"}, "https://a.b.example.com", "https://a.b.example.com"},
+		{"wildcard does not match apex", []string{"https://*.example.com"}, "https://example.com", ""},
+		{"wildcard does not match bare dot", []string{"https://*.example.com"}, "https://.example.com", ""},
+		{"wildcard suffix attack", []string{"https://*.example.com"}, "https://app.example.com.evil.net", ""},
+		{"wildcard lookalike domain", []string{"https://*.example.com"}, "https://evilexample.com", ""},
+		{"wildcard other scheme", []string{"https://*.example.com"}, "http://app.example.com", ""},
+		{"any origin", []string{"*"}, "https://anything.test", "*"},
+		{"listed origin wins over any", []string{"*", "https://app.example.com"}, "https://app.example.com", "https://app.example.com"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := newCORS(config.CORSConfig{AllowedOrigins: tt.origins})
+			if got := c.allowOrigin(tt.origin); got != tt.want {
+				t.Errorf("allowOrigin(%q) = %q; want %q", tt.origin, got, tt.want)
+			}
+		})
+	}
+}
+
+func testCORSConfig(origins ...string) config.CORSConfig {
+	return config.CORSConfig{
+		AllowedOrigins:   origins,
+		AllowedMethods:   []string{"GET", "POST"},
+		AllowedHeaders:   []string{"Authorization", "Content-Type"},
+		ExposedHeaders:   []string{"X-Request-ID"},
+		AllowCredentials: true,
+		MaxAge:           10 * time.Minute,
+	}
+}
+
+func serveCORS(cfg config.CORSConfig, r *http.Request) (*httptest.ResponseRecorder, bool) {
+	called := false
+	h := CORS(cfg)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		called = true
+		w.WriteHeader(http.StatusOK)
+	}))
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, r)
+	return rec, called
+}
+
+func TestCORSActualRequest(t *testing.T) {
+	r := httptest.NewRequest(http.MethodGet, "/api/users", nil)
+	r.Header.Set("Origin", "https://app.example.com")
+	rec, called := serveCORS(testCORSConfig("https://app.example.com"), r)
+
+	h := rec.Header()
+	if !called || h.Get("Access-Control-Allow-Origin") != "https://app.example.com" ||
+		h.Get("Access-Control-Allow-Credentials") != "true" || h.Get("Access-Control-Expose-Headers") != "X-Request-ID" || h.Get("Vary") != "Origin" {
+		t.Errorf("called = %v, headers = %v", called, h)
+	}
+
+	// Origin yang tidak diizinkan tetap diteruskan ke handler, hanya tanpa header CORS
+	r.Header.Set("Origin", "https://evil.example.com")
+	rec, called = serveCORS(testCORSConfig("https://app.example.com"), r)
+	if !called || rec.Header().Get("Access-Control-Allow-Origin") != "" {
+		t.Errorf("called = %v, headers = %v; want no CORS headers", called, rec.Header())
+	}
+}
+
+func TestCORSAnyOriginWithoutCredentials(t *testing.T) {
+	r := httptest.NewRequest(http.MethodGet, "/api/users", nil)
+	r.Header.Set("Origin", "https://app.example.com")
+	rec, _ := serveCORS(testCORSConfig("*"), r)
+
+	// Browser menolak "*" bersama credentials, jadi credentials tidak pernah dikirim untuk "*"
+	if rec.Header().Get("Access-Control-Allow-Origin") != "*" || rec.Header().Get("Access-Control-Allow-Credentials") != "" {
+		t.Errorf("headers = %v; want * without credentials", rec.Header())
+	}
+}
+
+func TestCORSPreflight(t *testing.T) {
+	tests := []struct {
+		name        string
+		cfg         config.CORSConfig
+		origin      string
+		method      string
+		headers     string
+		wantAllowed bool
+		wantHeaders string
+	}{
+		{"allowed", testCORSConfig("https://app.example.com"), "https://app.example.com", "POST", "authorization, content-type", true, "Authorization, Content-Type"},
+		{"allowed without request headers", testCORSConfig("https://app.example.com"), "https://app.example.com", "GET", "", true, "Authorization, Content-Type"},
+		{"origin not allowed", testCORSConfig("https://app.example.com"), "https://evil.example.com", "POST", "", false, ""},
+		{"method not allowed", testCORSConfig("https://app.example.com"), "https://app.example.com", "DELETE", "", false, ""},
+		{"header not allowed", testCORSConfig("https://app.example.com"), "https://app.example.com", "POST", "Authorization, X-Custom", false, ""},
+		{
+			name: "any header echoes the request",
+			cfg: func() config.CORSConfig {
+				cfg := testCORSConfig("https://*.example.com")
+				cfg.AllowedHeaders = []string{"*"}
+				return cfg
+			}(),
+			origin: "https://app.example.com", method: "POST", headers: "X-Custom", wantAllowed: true, wantHeaders: "X-Custom",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodOptions, "/api/users", nil)
+			r.Header.Set("Origin", tt.origin)
+			r.Header.Set("Access-Control-Request-Method", tt.method)
+			if tt.headers != "" {
+				r.Header.Set("Access-Control-Request-Headers", tt.headers)
+			}
+			rec, called := serveCORS(tt.cfg, r)
+
+			// Preflight selalu dijawab middleware dengan 204, tanpa masuk ke handler
+			if called || rec.Code != http.StatusNoContent {
+				t.Fatalf("called = %v, status = %d; want 204 from the middleware", called, rec.Code)
+			}
+			h := rec.Header()
+			if !tt.wantAllowed {
+				if h.Get("Access-Control-Allow-Origin") != "" || h.Get("Access-Control-Allow-Methods") != "" {
+					t.Errorf("headers = %v; want no CORS headers", h)
+				}
+				return
+			}
+			if h.Get("Access-Control-Allow-Origin") != tt.origin || h.Get("Access-Control-Allow-Methods") != "GET, POST" ||
+				h.Get("Access-Control-Allow-Headers") != tt.wantHeaders || h.Get("Access-Control-Max-Age") != "600" ||
+				h.Get("Access-Control-Allow-Credentials") != "true" {
+				t.Errorf("headers = %v", h)
+			}
+		})
+	}
+}
+
+func TestCORSDisabled(t *testing.T) {
+	r := httptest.NewRequest(http.MethodOptions, "/api/users", nil)
+	r.Header.Set("Origin", "https://app.example.com")
+	r.Header.Set("Access-Control-Request-Method", "POST")
+	rec, called := serveCORS(config.CORSConfig{}, r)
+	if !called || rec.Header().Get("Access-Control-Allow-Origin") != "" || rec.Header().Get("Vary") != "" {
+		t.Errorf("called = %v, headers = %v; want the request passed through untouched", called, rec.Header())
+	}
+}
@@ -0,0 +1,26 @@
+package middleware
+
+import (
+	"betest/internal/cookie"
+	"betest/internal/response"
+	"net/http"
+)
+
+// CSRF menolak request yang mengubah state tanpa CSRF token yang cocok (double-submit).
+// Dipasang di endpoint yang diautentikasi dengan cookie (misalnya /refresh); endpoint dengan
+// Authorization header tidak butuh karena browser tidak mengirim header itu otomatis.
+func CSRF(p *cookie.Policy) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			switch r.Method {
+			case http.MethodGet, http.MethodHead, http.MethodOptions:
+			default:
+				if !p.ValidCSRF(r) {
+					response.SendError(w, http.StatusForbidden, "Invalid CSRF token")
+					return
+				}
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
@@ -0,0 +1,53 @@
+package middleware
+
+import (
+	"betest/internal/config"
+	"betest/internal/cookie"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestCSRF(t *testing.T) {
+	p, err := cookie.NewPolicy(config.CookieConfig{CSRF: true})
+	if err != nil {
+		t.Fatalf("NewPolicy: %v", err)
+	}
+	h := CSRF(p)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}))
+
+	tests := []struct {
+		name   string
+		method string
+		cookie string
+		header string
+		want   int
+	}{
+		{"GET without token", http.MethodGet, "", "", http.StatusNoContent},
+		{"HEAD without token", http.MethodHead, "", "", http.StatusNoContent},
+		{"OPTIONS without token", http.MethodOptions, "", "", http.StatusNoContent},
+		{"POST without token", http.MethodPost, "", "", http.StatusForbidden},
+		{"POST with cookie only", http.MethodPost, "abc", "", http.StatusForbidden},
+		{"POST with header only", http.MethodPost, "", "abc", http.StatusForbidden},
+		{"POST with mismatch", http.MethodPost, "abc", "abd", http.StatusForbidden},
+		{"POST with matching token", http.MethodPost, "abc", "abc", http.StatusNoContent},
+		{"DELETE without token", http.MethodDelete, "", "", http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(tt.method, "/refresh", nil)
+			if tt.cookie != "" {
+				r.AddCookie(&http.Cookie{Name: "csrf_token", Value: tt.cookie})
+			}
+			if tt.header != "" {
+				r.Header.Set(cookie.CSRFHeader, tt.header)
+			}
+			rec := httptest.NewRecorder()
+			h.ServeHTTP(rec, r)
+			if rec.Code != tt.want {
+				t.Errorf("status = %d; want %d", rec.Code, tt.want)
+			}
+		})
+	}
+}
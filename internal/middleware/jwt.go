@@ -1,20 +1,22 @@
 package middleware
 
 import (
+	"betest/internal/metrics"
+	"betest/internal/redisclient"
 	"betest/internal/response"
-	"context"
-	"crypto/rsa"
+	"betest/internal/token"
+	"errors"
 	"fmt"
+	"log/slog"
 	"net/http"
 	"strings"
 
 	"github.com/go-redis/redis/v8"
-	"github.com/golang-jwt/jwt/v5"
 )
 
 var (
-	PublicKey *rsa.PublicKey // Will be set from main or auth
-	Rdb       *redis.Client  // Will be set from main or auth
+	TokenVerifier token.Verifier        // Will be set from main
+	Rdb           redis.UniversalClient // Will be set from main or auth
 )
 
 func JWTMiddleware(next http.Handler) http.Handler {
@@ -26,38 +28,51 @@ func JWTMiddleware(next http.Handler) http.Handler {
 		}
 
 		tokenString := strings.TrimPrefix(authHeader, "Bearer ")
-		token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
-			if _, ok := token.Method.(*jwt.SigningMethodRSA); !ok {
-				return nil, jwt.ErrSignatureInvalid
+
+		// Opaque access token di-resolve dari Redis; token yang dicabut sudah tidak ada di sana,
+		// jadi tidak perlu cek blacklist
+		if token.IsOpaque(tokenString) {
+			claims, err := token.LookupOpaque(r.Context(), Rdb, tokenString)
+			if errors.Is(err, token.ErrOpaqueNotFound) {
+				response.SendError(w, http.StatusUnauthorized, "Invalid token")
+				return
+			}
+			if err != nil {
+				slog.ErrorContext(r.Context(), "Error resolving opaque token", "error", err)
+				response.SendError(w, http.StatusInternalServerError, "Error validating token")
+				return
 			}
-			return PublicKey, nil
-		})
+			next.ServeHTTP(w, withClaims(r, claims))
+			return
+		}
 
-		if err != nil || !token.Valid {
+		// Validasi signature, exp/nbf/iat, iss, aud dan token_use=access
+		// (refresh token ditolak walaupun signature-nya valid)
+		claims, err := token.Parse(tokenString, TokenVerifier, token.UseAccess)
+		if err != nil {
 			response.SendError(w, http.StatusUnauthorized, "Invalid token")
 			return
 		}
 
-		if claims, ok := token.Claims.(jwt.MapClaims); ok {
-			// Cek apakah token ada di blacklist
-			if jti, ok := claims["jti"].(string); ok {
-				blacklistKey := fmt.Sprintf("blacklist:access_token:%s", jti)
-				val, err := Rdb.Get(context.Background(), blacklistKey).Result()
-				if err == nil && val == "1" {
-					// Token ada di blacklist (sudah logout)
-					response.SendError(w, http.StatusUnauthorized, "Token has been revoked")
-					return
-				}
-			}
-
-			userID := int(claims["user_id"].(float64))
-			ctx := context.WithValue(r.Context(), "user_id", userID)
-			r = r.WithContext(ctx)
-		} else {
-			response.SendError(w, http.StatusUnauthorized, "Invalid token claims")
+		// Cek apakah token ada di blacklist
+		blacklistKey := redisclient.Key(fmt.Sprintf("blacklist:access_token:%s", claims.ID))
+		val, err := Rdb.Get(r.Context(), blacklistKey).Result()
+		// Jika Redis gagal, status blacklist tidak diketahui: token ditolak supaya token yang sudah
+		// logout atau di-rotate tidak berlaku lagi (sama seperti /introspect)
+		if err != nil && !errors.Is(err, redis.Nil) {
+			slog.ErrorContext(r.Context(), "Error checking token blacklist", "error", err)
+			response.SendError(w, http.StatusInternalServerError, "Error validating token")
+			return
+		}
+		if val == "1" {
+			// Token ada di blacklist (sudah logout)
+			metrics.RevokedTokenHitsTotal.Inc()
+			response.SendError(w, http.StatusUnauthorized, "Token has been revoked")
 			return
 		}
 
+		r = withClaims(r, claims)
+
 		next.ServeHTTP(w, r)
 	})
 }
@@ -0,0 +1,33 @@
+package middleware
+
+import (
+	"betest/internal/metrics"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// Metrics mencatat jumlah request, latency dan request in-flight per route template
+func Metrics(router *mux.Router) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			route := routeTemplate(router, r)
+			inFlight := metrics.HTTPRequestsInFlight.WithLabelValues(r.Method, route)
+			inFlight.Inc()
+			defer inFlight.Dec()
+
+			start := time.Now()
+			rec := &responseRecorder{ResponseWriter: w}
+			next.ServeHTTP(rec, r)
+
+			if rec.status == 0 {
+				rec.status = http.StatusOK
+			}
+			status := strconv.Itoa(rec.status)
+			metrics.HTTPRequestsTotal.WithLabelValues(r.Method, route, status).Inc()
+			metrics.HTTPRequestDuration.WithLabelValues(r.Method, route, status).Observe(time.Since(start).Seconds())
+		})
+	}
+}
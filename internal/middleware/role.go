@@ -0,0 +1,47 @@
+package middleware
+
+import (
+	"betest/internal/database"
+	"betest/internal/response"
+	"database/sql"
+	"log/slog"
+	"net/http"
+)
+
+const RoleAdmin = "admin"
+
+// RequireRole hanya meneruskan request jika user yang login punya role tertentu.
+// Harus dipasang setelah JWTMiddleware.
+func RequireRole(role string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			userID, ok := UserID(r.Context())
+			if !ok {
+				response.SendError(w, http.StatusUnauthorized, "Unauthorized")
+				return
+			}
+
+			ctx, cancel := database.WithTimeout(r.Context())
+			defer cancel()
+
+			var userRole string
+			err := database.DB.QueryRowContext(ctx, "SELECT role FROM users WHERE id=$1", userID).Scan(&userRole)
+			if err == sql.ErrNoRows {
+				response.SendError(w, http.StatusUnauthorized, "Unauthorized")
+				return
+			}
+			if err != nil {
+				slog.ErrorContext(r.Context(), "Error checking role", "error", err)
+				response.SendError(w, http.StatusInternalServerError, "Error checking permissions")
+				return
+			}
+
+			if userRole != role {
+				response.SendError(w, http.StatusForbidden, "Forbidden")
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
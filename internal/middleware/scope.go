@@ -0,0 +1,29 @@
+package middleware
+
+import (
+	"betest/internal/response"
+	"fmt"
+	"net/http"
+)
+
+// RequireScope hanya meneruskan request jika access token punya scope tertentu.
+// Token first-party (tanpa client_id) selalu lolos; batasannya tetap role user (RequireRole).
+// Harus dipasang setelah JWTMiddleware.
+func RequireScope(scope string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			claims, ok := TokenClaims(r.Context())
+			if !ok {
+				response.SendError(w, http.StatusUnauthorized, "Unauthorized")
+				return
+			}
+			if !claims.HasScope(scope) {
+				// RFC 6750 section 3.1
+				w.Header().Set("WWW-Authenticate", fmt.Sprintf(`Bearer error="insufficient_scope", scope=%q`, scope))
+				response.SendError(w, http.StatusForbidden, "Insufficient scope")
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
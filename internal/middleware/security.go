@@ -0,0 +1,42 @@
+package middleware
+
+import (
+	"betest/internal/config"
+	"net/http"
+	"strconv"
+)
+
+// SecurityHeaders menambahkan security header ke setiap response. API ini hanya mengembalikan
+// JSON/CSV, jadi CSP default melarang semua resource dan framing; jika suatu saat ada response
+// HTML, browser tidak akan menjalankan script atau menampilkannya di iframe.
+// HSTS hanya dikirim untuk request HTTPS (langsung atau lewat proxy dengan X-Forwarded-Proto),
+// karena browser mengabaikan HSTS dari HTTP biasa.
+func SecurityHeaders(cfg config.SecurityConfig) func(http.Handler) http.Handler {
+	hsts := ""
+	if cfg.HSTSMaxAge > 0 {
+		hsts = "max-age=" + strconv.Itoa(int(cfg.HSTSMaxAge.Seconds()))
+		if cfg.HSTSIncludeSubdomains {
+			hsts += "; includeSubDomains"
+		}
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			h := w.Header()
+			h.Set("X-Content-Type-Options", "nosniff")
+			if cfg.FrameOptions != "" {
+				h.Set("X-Frame-Options", cfg.FrameOptions)
+			}
+			if cfg.ReferrerPolicy != "" {
+				h.Set("Referrer-Policy", cfg.ReferrerPolicy)
+			}
+			if cfg.ContentSecurityPolicy != "" {
+				h.Set("Content-Security-Policy", cfg.ContentSecurityPolicy)
+			}
+			if hsts != "" && (r.TLS != nil || r.Header.Get("X-Forwarded-Proto") == "https") {
+				h.Set("Strict-Transport-Security", hsts)
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
@@ -0,0 +1,63 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+func TestTimeout(t *testing.T) {
+	router := mux.NewRouter()
+	h := Timeout(router, 50*time.Millisecond)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Handler", "1")
+		w.WriteHeader(http.StatusCreated)
+		w.Write([]byte("fast"))
+	}))
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+	if rec.Code != http.StatusCreated || rec.Body.String() != "fast" || rec.Header().Get("X-Handler") != "1" {
+		t.Errorf("status = %d, body = %q, headers = %v; want the buffered handler response", rec.Code, rec.Body.String(), rec.Header())
+	}
+}
+
+func TestTimeoutSlowHandler(t *testing.T) {
+	router := mux.NewRouter()
+	release := make(chan struct{})
+	finished := make(chan error, 1)
+	slow := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte("partial"))
+		<-r.Context().Done()
+		// Handler masih berjalan bersamaan dengan AccessLog yang membaca state request
+		withUserID(r, 42)
+		<-release
+		_, err := w.Write([]byte("late"))
+		finished <- err
+	})
+	h := AccessLog(router)(Timeout(router, 20*time.Millisecond)(slow))
+
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Errorf("status = %d; want %d", rec.Code, http.StatusServiceUnavailable)
+	}
+	// Isi buffer handler dibuang, client hanya menerima error timeout
+	if body := rec.Body.String(); strings.Contains(body, "partial") || strings.Contains(body, "late") || !strings.Contains(body, "Request timed out") {
+		t.Errorf("body = %q; want only the timeout error", body)
+	}
+
+	close(release)
+	select {
+	case err := <-finished:
+		if err != http.ErrHandlerTimeout {
+			t.Errorf("late Write error = %v; want http.ErrHandlerTimeout", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("handler did not finish after the timeout")
+	}
+}
@@ -0,0 +1,21 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+)
+
+type AuditLog struct {
+	ID           int64           `json:"id" db:"id"`
+	ActorID      *int            `json:"actor_id" db:"actor_id"`
+	Action       string          `json:"action" db:"action"`
+	ResourceType string          `json:"resource_type" db:"resource_type"`
+	ResourceID   string          `json:"resource_id,omitempty" db:"resource_id"`
+	Before       json.RawMessage `json:"before,omitempty" db:"before"`
+	After        json.RawMessage `json:"after,omitempty" db:"after"`
+	Changes      json.RawMessage `json:"changes,omitempty" db:"changes"`
+	IP           string          `json:"ip" db:"ip"`
+	UserAgent    string          `json:"user_agent" db:"user_agent"`
+	RequestID    string          `json:"request_id,omitempty" db:"request_id"`
+	CreatedAt    time.Time       `json:"created_at" db:"created_at"`
+}
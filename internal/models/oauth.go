@@ -0,0 +1,19 @@
+package models
+
+import "time"
+
+// OAuthClient adalah aplikasi yang boleh meminta token lewat /oauth/*.
+// Client tanpa secret (confidential=false) adalah public client (SPA, mobile) dan wajib memakai PKCE.
+type OAuthClient struct {
+	ID           int       `json:"id" db:"id"`
+	ClientID     string    `json:"client_id" db:"client_id"`
+	ClientSecret string    `json:"client_secret,omitempty" db:"-"` // Hanya dikirim saat client dibuat
+	SecretHash   string    `json:"-" db:"client_secret_hash"`
+	Name         string    `json:"name" db:"name"`
+	RedirectURIs []string  `json:"redirect_uris" db:"redirect_uris"`
+	GrantTypes   []string  `json:"grant_types" db:"grant_types"`
+	Scopes       []string  `json:"scopes" db:"scopes"`
+	Confidential bool      `json:"confidential" db:"confidential"`
+	CreatedAt    time.Time `json:"created_at" db:"created_at"`
+	UpdatedAt    time.Time `json:"updated_at" db:"updated_at"`
+}
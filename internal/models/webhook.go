@@ -0,0 +1,33 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+)
+
+type Webhook struct {
+	ID                  int        `json:"id" db:"id"`
+	URL                 string     `json:"url" db:"url"`
+	Secret              string     `json:"secret,omitempty" db:"secret"` // Hanya dikirim saat webhook dibuat
+	Events              []string   `json:"events" db:"events"`           // Kosong berarti semua event
+	Active              bool       `json:"active" db:"active"`
+	ConsecutiveFailures int        `json:"consecutive_failures" db:"consecutive_failures"`
+	DisabledAt          *time.Time `json:"disabled_at" db:"disabled_at"`
+	CreatedAt           time.Time  `json:"created_at" db:"created_at"`
+	UpdatedAt           time.Time  `json:"updated_at" db:"updated_at"`
+}
+
+type WebhookDelivery struct {
+	ID             int64           `json:"id" db:"id"`
+	WebhookID      int             `json:"webhook_id" db:"webhook_id"`
+	EventID        string          `json:"event_id" db:"event_id"`
+	EventType      string          `json:"event_type" db:"event_type"`
+	Payload        json.RawMessage `json:"payload" db:"payload"`
+	Status         string          `json:"status" db:"status"`
+	Attempts       int             `json:"attempts" db:"attempts"`
+	NextAttemptAt  *time.Time      `json:"next_attempt_at" db:"next_attempt_at"`
+	LastStatusCode *int            `json:"last_status_code" db:"last_status_code"`
+	LastError      *string         `json:"last_error" db:"last_error"`
+	CreatedAt      time.Time       `json:"created_at" db:"created_at"`
+	DeliveredAt    *time.Time      `json:"delivered_at" db:"delivered_at"`
+}
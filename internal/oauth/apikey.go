@@ -0,0 +1,41 @@
+package oauth
+
+import (
+	"betest/internal/config"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// APIKeyHeader adalah header yang berisi API key service internal
+const APIKeyHeader = "X-API-Key"
+
+// APIKeys memetakan hash API key ke nama service. Yang disimpan di memori hanya hash-nya,
+// dan lookup lewat hash tidak membocorkan isi key walaupun tidak constant time.
+type APIKeys map[string]string
+
+// NewAPIKeys membaca INTROSPECTION_API_KEYS dengan format "nama:key"
+func NewAPIKeys(cfg config.IntrospectionConfig) (APIKeys, error) {
+	keys := make(APIKeys, len(cfg.APIKeys))
+	for _, entry := range cfg.APIKeys {
+		name, key, ok := strings.Cut(entry, ":")
+		if !ok || name == "" {
+			return nil, fmt.Errorf("invalid api key entry, expected name:key")
+		}
+		if len(key) < 32 {
+			return nil, fmt.Errorf("api key for %q must be at least 32 characters", name)
+		}
+		keys[HashSecret(key)] = name
+	}
+	return keys, nil
+}
+
+// Service mengembalikan nama service pemilik API key di header X-API-Key
+func (k APIKeys) Service(r *http.Request) (string, bool) {
+	key := r.Header.Get(APIKeyHeader)
+	if key == "" {
+		return "", false
+	}
+	name, ok := k[HashSecret(key)]
+	return name, ok
+}
@@ -0,0 +1,89 @@
+package oauth
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+	"net"
+	"net/http"
+	"net/url"
+)
+
+// GenerateClientID membuat client_id acak untuk client baru
+func GenerateClientID() (string, error) {
+	return randomHex("client_", 16)
+}
+
+// GenerateSecret membuat client_secret acak. Secret hanya ditampilkan sekali saat client dibuat.
+func GenerateSecret() (string, error) {
+	return randomHex("cs_", 32)
+}
+
+func randomHex(prefix string, n int) (string, error) {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return prefix + hex.EncodeToString(b), nil
+}
+
+// HashSecret menghitung hash client_secret yang disimpan di database. Secret selalu acak 256-bit,
+// jadi SHA-256 cukup (tidak perlu bcrypt yang lambat di setiap request /oauth/token).
+func HashSecret(secret string) string {
+	sum := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(sum[:])
+}
+
+// VerifySecret membandingkan secret dengan hash tersimpan dalam waktu konstan
+func VerifySecret(secret, hash string) bool {
+	return subtle.ConstantTimeCompare([]byte(HashSecret(secret)), []byte(hash)) == 1
+}
+
+// ClientCredentials membaca client_id dan client_secret dari header Authorization: Basic
+// (client_secret_basic) atau dari form (client_secret_post, atau hanya client_id untuk public client).
+// r.ParseForm harus sudah dipanggil.
+func ClientCredentials(r *http.Request) (clientID, secret string, err error) {
+	if id, s, ok := r.BasicAuth(); ok {
+		if r.PostForm.Get("client_secret") != "" {
+			return "", "", errors.New("client authenticated with more than one method")
+		}
+		// RFC 6749 section 2.3.1: client_id dan client_secret di-encode form-urlencoded sebelum Basic
+		if clientID, err = url.QueryUnescape(id); err != nil {
+			return "", "", err
+		}
+		if secret, err = url.QueryUnescape(s); err != nil {
+			return "", "", err
+		}
+		return clientID, secret, nil
+	}
+	return r.PostForm.Get("client_id"), r.PostForm.Get("client_secret"), nil
+}
+
+// ValidRedirectURI memeriksa redirect URI yang didaftarkan: URL absolut https tanpa fragment.
+// http hanya diterima untuk loopback (localhost, 127.0.0.1, ::1) untuk aplikasi native dan development.
+func ValidRedirectURI(raw string) error {
+	u, err := url.Parse(raw)
+	if err != nil || u.Host == "" {
+		return errors.New("must be an absolute URL")
+	}
+	if u.Fragment != "" {
+		return errors.New("must not contain a fragment")
+	}
+	switch u.Scheme {
+	case "https":
+		return nil
+	case "http":
+		host := u.Hostname()
+		if host == "localhost" {
+			return nil
+		}
+		if ip := net.ParseIP(host); ip != nil && ip.IsLoopback() {
+			return nil
+		}
+		return errors.New("http is only allowed for loopback addresses")
+	default:
+		return errors.New("scheme must be https")
+	}
+}
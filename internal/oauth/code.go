@@ -0,0 +1,68 @@
+package oauth
+
+import (
+	"betest/internal/redisclient"
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+// CodeTTL adalah umur authorization code; RFC 6749 menyarankan maksimal 10 menit
+const CodeTTL = time.Minute
+
+// Code adalah data yang diikat ke authorization code dan diperiksa lagi saat ditukar di /oauth/token
+type Code struct {
+	ClientID      string `json:"client_id"`
+	UserID        int    `json:"user_id"`
+	RedirectURI   string `json:"redirect_uri"`
+	Scope         string `json:"scope"`
+	CodeChallenge string `json:"code_challenge"`
+
+	// RedirectURIProvided menandai redirect_uri dikirim eksplisit ke /oauth/authorize, sehingga
+	// /oauth/token wajib mengirim nilai yang sama (RFC 6749 section 4.1.3)
+	RedirectURIProvided bool `json:"redirect_uri_provided,omitempty"`
+}
+
+// codeKey menyimpan hash SHA-256 dari code, bukan code-nya, supaya isi Redis tidak bisa langsung dipakai
+func codeKey(code string) string {
+	sum := sha256.Sum256([]byte(code))
+	return redisclient.Key("oauth_code:" + hex.EncodeToString(sum[:]))
+}
+
+// SaveCode membuat authorization code acak dan menyimpan datanya di Redis selama CodeTTL
+func SaveCode(ctx context.Context, rdb redis.UniversalClient, c Code) (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	code := base64.RawURLEncoding.EncodeToString(b)
+
+	data, err := json.Marshal(c)
+	if err != nil {
+		return "", err
+	}
+	if err := rdb.Set(ctx, codeKey(code), data, CodeTTL).Err(); err != nil {
+		return "", err
+	}
+	return code, nil
+}
+
+// ConsumeCode mengambil sekaligus menghapus code (GETDEL), jadi code hanya bisa ditukar sekali.
+// Code yang tidak ada atau sudah kedaluwarsa mengembalikan redis.Nil.
+func ConsumeCode(ctx context.Context, rdb redis.UniversalClient, code string) (*Code, error) {
+	data, err := rdb.GetDel(ctx, codeKey(code)).Bytes()
+	if err != nil {
+		return nil, err
+	}
+	var c Code
+	if err := json.Unmarshal(data, &c); err != nil {
+		return nil, err
+	}
+	return &c, nil
+}
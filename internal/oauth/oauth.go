@@ -0,0 +1,106 @@
+package oauth
+
+import (
+	"betest/internal/middleware"
+	"slices"
+	"strings"
+)
+
+// Grant type yang didukung /oauth/token
+const (
+	GrantAuthorizationCode = "authorization_code"
+	GrantRefreshToken      = "refresh_token"
+	GrantClientCredentials = "client_credentials"
+)
+
+// Scope yang bisa diminta client, dipetakan ke route:
+// users:read untuk GET /api/users*, users:write untuk create/update/delete/import user,
+// admin untuk route admin (tetap butuh user dengan role admin)
+const (
+	ScopeUsersRead  = "users:read"
+	ScopeUsersWrite = "users:write"
+	ScopeAdmin      = "admin"
+)
+
+// Scopes adalah semua scope yang dikenal
+var Scopes = []string{ScopeUsersRead, ScopeUsersWrite, ScopeAdmin}
+
+// GrantTypes adalah semua grant type yang bisa didaftarkan untuk client
+var GrantTypes = []string{GrantAuthorizationCode, GrantRefreshToken, GrantClientCredentials}
+
+// RoleScopes mengembalikan scope maksimal yang boleh diberikan user dengan role tersebut
+func RoleScopes(role string) []string {
+	if role == middleware.RoleAdmin {
+		return Scopes
+	}
+	return []string{ScopeUsersRead, ScopeUsersWrite}
+}
+
+// ParseScope memecah parameter scope (dipisah spasi) dan membuang duplikat
+func ParseScope(scope string) []string {
+	var scopes []string
+	for _, s := range strings.Fields(scope) {
+		if !slices.Contains(scopes, s) {
+			scopes = append(scopes, s)
+		}
+	}
+	return scopes
+}
+
+// FormatScope menggabungkan scope menjadi string dipisah spasi
+func FormatScope(scopes []string) string {
+	return strings.Join(scopes, " ")
+}
+
+// Subset menandakan semua scope di requested ada di allowed
+func Subset(requested, allowed []string) bool {
+	for _, s := range requested {
+		if !slices.Contains(allowed, s) {
+			return false
+		}
+	}
+	return true
+}
+
+// Intersect mengembalikan scope di requested yang juga ada di allowed, urutan mengikuti requested
+func Intersect(requested, allowed []string) []string {
+	var scopes []string
+	for _, s := range requested {
+		if slices.Contains(allowed, s) {
+			scopes = append(scopes, s)
+		}
+	}
+	return scopes
+}
+
+// Kode error RFC 6749 section 5.2 dan 4.1.2.1
+const (
+	ErrInvalidRequest          = "invalid_request"
+	ErrInvalidClient           = "invalid_client"
+	ErrInvalidGrant            = "invalid_grant"
+	ErrUnauthorizedClient      = "unauthorized_client"
+	ErrUnsupportedGrantType    = "unsupported_grant_type"
+	ErrUnsupportedResponseType = "unsupported_response_type"
+	ErrInvalidScope            = "invalid_scope"
+	ErrAccessDenied            = "access_denied"
+	ErrServerError             = "server_error"
+	ErrTemporarilyUnavailable  = "temporarily_unavailable"
+)
+
+// Error adalah error OAuth2 yang dikirim ke client sebagai {"error", "error_description"}
+type Error struct {
+	Code        string `json:"error"`
+	Description string `json:"error_description,omitempty"`
+}
+
+func (e *Error) Error() string {
+	if e.Description == "" {
+		return e.Code
+	}
+	return e.Code + ": " + e.Description
+}
+
+// NewError membuat Error dengan kode dan deskripsi
+func NewError(code, description string) *Error {
+	return &Error{Code: code, Description: description}
+}
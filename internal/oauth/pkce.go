@@ -0,0 +1,43 @@
+package oauth
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+)
+
+// MethodS256 adalah satu-satunya code_challenge_method yang diterima (plain ditolak)
+const MethodS256 = "S256"
+
+// ValidVerifier memeriksa code_verifier sesuai RFC 7636 section 4.1:
+// 43-128 karakter dari [A-Z] [a-z] [0-9] "-" "." "_" "~"
+func ValidVerifier(verifier string) bool {
+	if len(verifier) < 43 || len(verifier) > 128 {
+		return false
+	}
+	for _, c := range verifier {
+		switch {
+		case c >= 'A' && c <= 'Z', c >= 'a' && c <= 'z', c >= '0' && c <= '9':
+		case c == '-', c == '.', c == '_', c == '~':
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// ValidChallenge memeriksa code_challenge S256: base64url tanpa padding dari SHA-256 (43 karakter)
+func ValidChallenge(challenge string) bool {
+	b, err := base64.RawURLEncoding.DecodeString(challenge)
+	return err == nil && len(b) == sha256.Size
+}
+
+// VerifyPKCE membandingkan BASE64URL(SHA256(verifier)) dengan challenge yang disimpan saat authorize
+func VerifyPKCE(verifier, challenge string) bool {
+	if !ValidVerifier(verifier) {
+		return false
+	}
+	sum := sha256.Sum256([]byte(verifier))
+	computed := base64.RawURLEncoding.EncodeToString(sum[:])
+	return subtle.ConstantTimeCompare([]byte(computed), []byte(challenge)) == 1
+}
@@ -0,0 +1,206 @@
+package outbox
+
+import (
+	"betest/internal/config"
+	"betest/internal/events"
+	"cmp"
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log/slog"
+	"slices"
+	"strings"
+	"time"
+)
+
+const (
+	minBackoff = time.Second
+	maxBackoff = 10 * time.Minute
+
+	resultTimeout = 5 * time.Second // Batas waktu menyimpan hasil publish dan melepas lease
+)
+
+// Dispatcher membaca event yang belum terkirim dari tabel outbox dan mem-publish ke semua sink.
+// Event baru ditandai published setelah semua sink berhasil, jadi pengiriman bersifat at-least-once.
+// Beberapa instance aman berjalan bersamaan: setiap batch di-claim dengan lease (locked_until)
+// dalam satu statement pendek, dipublish di luar transaksi, lalu hasil per event disimpan sendiri-sendiri.
+type Dispatcher struct {
+	db           *sql.DB
+	sinks        []Sink
+	pollInterval time.Duration
+	batchSize    int
+	maxAttempts  int
+	lease        time.Duration
+	logger       *slog.Logger
+}
+
+func NewDispatcher(db *sql.DB, sinks []Sink, cfg config.OutboxConfig) *Dispatcher {
+	return &Dispatcher{
+		db:           db,
+		sinks:        sinks,
+		pollInterval: cfg.PollInterval,
+		batchSize:    cfg.BatchSize,
+		maxAttempts:  cfg.MaxAttempts,
+		lease:        cfg.LeaseDuration,
+		logger:       slog.Default().With("component", "outbox"),
+	}
+}
+
+// Run memproses outbox sampai ctx dibatalkan
+func (d *Dispatcher) Run(ctx context.Context) {
+	ticker := time.NewTicker(d.pollInterval)
+	defer ticker.Stop()
+
+	for {
+		// Kalau batch penuh, kemungkinan masih ada event lain, langsung lanjut tanpa menunggu ticker
+		n, err := d.dispatchBatch(ctx)
+		if err != nil && ctx.Err() == nil {
+			d.logger.Error("Outbox dispatch error", "error", err)
+		}
+		if n == d.batchSize && err == nil {
+			continue
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+type pendingEvent struct {
+	events.Event
+	id       int64
+	attempts int
+}
+
+func (d *Dispatcher) dispatchBatch(ctx context.Context) (int, error) {
+	batch, err := d.claim(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	for i, e := range batch {
+		err := d.publish(ctx, e.Event)
+		if err != nil && ctx.Err() != nil {
+			// Shutdown: event yang belum selesai dilepas supaya instance lain tidak perlu menunggu lease habis
+			d.release(batch[i:])
+			return i, ctx.Err()
+		}
+
+		// Hasil disimpan walaupun ctx dibatalkan setelah publish, supaya event yang sudah terkirim tidak dikirim ulang
+		resultCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), resultTimeout)
+		if err != nil {
+			err = d.markFailedAttempt(resultCtx, e.id, e.attempts+1, err)
+		} else {
+			_, err = d.db.ExecContext(resultCtx,
+				"UPDATE outbox SET published_at = NOW(), attempts = attempts + 1, last_error = NULL, locked_until = NULL WHERE id = $1", e.id)
+		}
+		cancel()
+		// Event yang hasilnya gagal disimpan tetap ter-lease dan di-retry setelah lease habis
+		if err != nil {
+			d.release(batch[i+1:])
+			return i, err
+		}
+	}
+
+	return len(batch), nil
+}
+
+// claim mengambil event yang jatuh tempo dan memasang lease locked_until dalam satu statement,
+// jadi row lock hanya ditahan selama UPDATE. Event yang lease-nya habis (misalnya instance mati
+// di tengah batch) bisa di-claim ulang.
+func (d *Dispatcher) claim(ctx context.Context) ([]pendingEvent, error) {
+	rows, err := d.db.QueryContext(ctx,
+		`UPDATE outbox SET locked_until = NOW() + $2 * INTERVAL '1 millisecond'
+		WHERE id IN (
+			SELECT id FROM outbox
+			WHERE published_at IS NULL AND failed_at IS NULL AND next_attempt_at <= NOW()
+				AND (locked_until IS NULL OR locked_until <= NOW())
+			ORDER BY id
+			LIMIT $1
+			FOR UPDATE SKIP LOCKED)
+		RETURNING id, event_id, event_type, aggregate_type, aggregate_id, payload, occurred_at, attempts`,
+		d.batchSize, d.lease.Milliseconds())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var batch []pendingEvent
+	for rows.Next() {
+		var e pendingEvent
+		var payload []byte
+		if err := rows.Scan(&e.id, &e.ID, &e.Type, &e.AggregateType, &e.AggregateID, &payload, &e.OccurredAt, &e.attempts); err != nil {
+			return nil, err
+		}
+		e.Payload = payload
+		batch = append(batch, e)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	// RETURNING tidak menjamin urutan
+	slices.SortFunc(batch, func(a, b pendingEvent) int { return cmp.Compare(a.id, b.id) })
+	return batch, nil
+}
+
+// release melepas lease event yang belum diproses
+func (d *Dispatcher) release(batch []pendingEvent) {
+	if len(batch) == 0 {
+		return
+	}
+	ids := make([]int64, len(batch))
+	for i, e := range batch {
+		ids[i] = e.id
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), resultTimeout)
+	defer cancel()
+	if _, err := d.db.ExecContext(ctx, "UPDATE outbox SET locked_until = NULL WHERE id = ANY($1)", ids); err != nil {
+		d.logger.Warn("Error releasing outbox lease", "error", err)
+	}
+}
+
+func (d *Dispatcher) publish(ctx context.Context, e events.Event) error {
+	var errs []error
+	for _, sink := range d.sinks {
+		if err := sink.Publish(ctx, e); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", sink.Name(), err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func (d *Dispatcher) markFailedAttempt(ctx context.Context, id int64, attempts int, publishErr error) error {
+	lastError := strings.ReplaceAll(publishErr.Error(), "\n", "; ")
+
+	if d.maxAttempts > 0 && attempts >= d.maxAttempts {
+		d.logger.Error("Outbox event failed, giving up", "outbox_id", id, "attempts", attempts, "error", lastError)
+		_, err := d.db.ExecContext(ctx,
+			"UPDATE outbox SET attempts = $2, last_error = $3, failed_at = NOW(), locked_until = NULL WHERE id = $1",
+			id, attempts, lastError)
+		return err
+	}
+
+	next := time.Now().Add(Backoff(attempts))
+	d.logger.Warn("Outbox event failed, will retry", "outbox_id", id, "attempts", attempts, "next_attempt_at", next, "error", lastError)
+	_, err := d.db.ExecContext(ctx,
+		"UPDATE outbox SET attempts = $2, last_error = $3, next_attempt_at = $4, locked_until = NULL WHERE id = $1",
+		id, attempts, lastError, next)
+	return err
+}
+
+// Backoff menghitung jeda retry eksponensial: 1s, 2s, 4s, ... maksimal 10 menit
+func Backoff(attempts int) time.Duration {
+	if attempts < 1 {
+		return minBackoff
+	}
+	if attempts > 20 {
+		return maxBackoff
+	}
+	return min(minBackoff<<(attempts-1), maxBackoff)
+}
@@ -0,0 +1,51 @@
+package outbox
+
+import (
+	"betest/internal/events"
+	"context"
+	"database/sql"
+	"strconv"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+)
+
+// Execer dipenuhi oleh *sql.Tx (dan *sql.DB), supaya event ditulis di transaksi yang sama dengan perubahan datanya
+type Execer interface {
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+}
+
+// Write menyimpan event ke tabel outbox. Panggil dengan *sql.Tx yang sama dengan perubahan data
+// agar event hanya tersimpan jika transaksinya commit.
+func Write(ctx context.Context, tx Execer, e events.Event) error {
+	_, err := tx.ExecContext(ctx,
+		`INSERT INTO outbox (event_id, event_type, aggregate_type, aggregate_id, payload, occurred_at)
+		VALUES ($1, $2, $3, $4, $5, $6)`,
+		e.ID, e.Type, e.AggregateType, e.AggregateID, string(e.Payload), e.OccurredAt)
+	return err
+}
+
+// WriteUserEvent adalah shortcut untuk membuat dan menyimpan event dengan aggregate user
+func WriteUserEvent(ctx context.Context, tx Execer, eventType string, userID int, payload interface{}) error {
+	e, err := events.New(eventType, events.AggregateUser, strconv.Itoa(userID), payload)
+	if err != nil {
+		return err
+	}
+	return Write(ctx, tx, e)
+}
+
+// CopyEvents menyimpan banyak event sekaligus dengan COPY di transaksi pgx yang sama dengan
+// perubahan datanya, untuk operasi bulk seperti import
+func CopyEvents(ctx context.Context, tx pgx.Tx, evts []events.Event) error {
+	_, err := tx.CopyFrom(ctx, pgx.Identifier{"outbox"},
+		[]string{"event_id", "event_type", "aggregate_type", "aggregate_id", "payload", "occurred_at"},
+		pgx.CopyFromSlice(len(evts), func(i int) ([]interface{}, error) {
+			e := evts[i]
+			id, err := uuid.Parse(e.ID)
+			if err != nil {
+				return nil, err
+			}
+			return []interface{}{id, e.Type, e.AggregateType, e.AggregateID, []byte(e.Payload), e.OccurredAt}, nil
+		}))
+	return err
+}
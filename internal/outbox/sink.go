@@ -0,0 +1,118 @@
+package outbox
+
+import (
+	"betest/internal/config"
+	"betest/internal/events"
+	"betest/internal/redisclient"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+// Sink adalah tujuan publish event. Publish bisa dipanggil lebih dari sekali untuk event yang sama
+// (at-least-once), jadi penerima sebaiknya dedup berdasarkan event ID.
+type Sink interface {
+	Name() string
+	Publish(ctx context.Context, e events.Event) error
+}
+
+// LogSink hanya menulis event ke log, berguna untuk development
+type LogSink struct{}
+
+func (LogSink) Name() string { return "log" }
+
+func (LogSink) Publish(ctx context.Context, e events.Event) error {
+	slog.InfoContext(ctx, "Domain event",
+		"event_id", e.ID,
+		"event_type", e.Type,
+		"aggregate_type", e.AggregateType,
+		"aggregate_id", e.AggregateID,
+		"payload", e.Payload)
+	return nil
+}
+
+// RedisStreamSink menambahkan event ke Redis Stream dengan XADD
+type RedisStreamSink struct {
+	Client redis.UniversalClient
+	Stream string
+	MaxLen int64 // Perkiraan panjang maksimum stream (MAXLEN ~), 0 berarti tidak dibatasi
+}
+
+func (s *RedisStreamSink) Name() string { return "redis" }
+
+func (s *RedisStreamSink) Publish(ctx context.Context, e events.Event) error {
+	return s.Client.XAdd(ctx, &redis.XAddArgs{
+		Stream: s.Stream,
+		MaxLen: s.MaxLen,
+		Approx: s.MaxLen > 0,
+		Values: map[string]interface{}{
+			"id":             e.ID,
+			"type":           e.Type,
+			"aggregate_type": e.AggregateType,
+			"aggregate_id":   e.AggregateID,
+			"occurred_at":    e.OccurredAt.Format(time.RFC3339Nano),
+			"payload":        string(e.Payload),
+		},
+	}).Err()
+}
+
+// WebhookSink mengirim event sebagai JSON lewat HTTP POST. Status selain 2xx dianggap gagal.
+type WebhookSink struct {
+	URL    string
+	Client *http.Client
+}
+
+func (s *WebhookSink) Name() string { return "webhook" }
+
+func (s *WebhookSink) Publish(ctx context.Context, e events.Event) error {
+	body, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.URL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Event-ID", e.ID)
+	req.Header.Set("X-Event-Type", e.Type)
+
+	resp, err := s.Client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("webhook responded with status %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// SinksFromConfig membuat daftar sink sesuai OUTBOX_SINKS
+func SinksFromConfig(cfg config.OutboxConfig, rdb redis.UniversalClient) ([]Sink, error) {
+	var sinks []Sink
+	for _, name := range cfg.Sinks {
+		switch name {
+		case "log":
+			sinks = append(sinks, LogSink{})
+		case "redis":
+			sinks = append(sinks, &RedisStreamSink{Client: rdb, Stream: redisclient.Key(cfg.RedisStream), MaxLen: cfg.RedisMaxLen})
+		case "webhook":
+			if cfg.WebhookURL == "" {
+				return nil, fmt.Errorf("OUTBOX_WEBHOOK_URL is required for the webhook sink")
+			}
+			sinks = append(sinks, &WebhookSink{URL: cfg.WebhookURL, Client: &http.Client{Timeout: cfg.WebhookTimeout}})
+		default:
+			return nil, fmt.Errorf("unknown outbox sink %q", name)
+		}
+	}
+	return sinks, nil
+}
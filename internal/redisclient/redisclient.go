@@ -0,0 +1,115 @@
+package redisclient
+
+import (
+	"betest/internal/config"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/go-redis/redis/v8"
+)
+
+// keyPrefix ditambahkan di depan semua key (lihat Key), diisi oleh New
+var keyPrefix string
+
+// New membuat Redis client sesuai REDIS_MODE:
+//   - standalone: satu node (alamat pertama REDIS_ADDRS)
+//   - sentinel: failover client, REDIS_ADDRS berisi alamat sentinel dan REDIS_SENTINEL_MASTER wajib diisi
+//   - cluster: REDIS_ADDRS berisi seed node cluster
+//
+// Di mode cluster prefix key dibungkus hash tag ({prefix}) supaya semua key aplikasi ada di
+// slot yang sama; operasi multi-key (DEL beberapa key, MULTI, script Lua rotasi token)
+// tidak bisa dijalankan lintas slot.
+func New(cfg config.RedisConfig) (redis.UniversalClient, error) {
+	if len(cfg.Addrs) == 0 {
+		return nil, fmt.Errorf("REDIS_ADDRS is empty")
+	}
+
+	opts := &redis.UniversalOptions{
+		Addrs:            cfg.Addrs,
+		DB:               cfg.DB,
+		Username:         cfg.Username,
+		Password:         cfg.Password,
+		SentinelUsername: cfg.SentinelUsername,
+		SentinelPassword: cfg.SentinelPassword,
+		MasterName:       cfg.MasterName,
+		DialTimeout:      cfg.DialTimeout,
+		ReadTimeout:      cfg.ReadTimeout,
+		WriteTimeout:     cfg.WriteTimeout,
+		PoolTimeout:      cfg.PoolTimeout,
+	}
+	if cfg.TLS {
+		tlsConfig, err := newTLSConfig(cfg)
+		if err != nil {
+			return nil, err
+		}
+		opts.TLSConfig = tlsConfig
+	}
+
+	switch cfg.Mode {
+	case "standalone":
+		keyPrefix = cfg.KeyPrefix
+		return redis.NewClient(opts.Simple()), nil
+	case "sentinel":
+		if cfg.MasterName == "" {
+			return nil, fmt.Errorf("REDIS_SENTINEL_MASTER is required in sentinel mode")
+		}
+		keyPrefix = cfg.KeyPrefix
+		return redis.NewFailoverClient(opts.Failover()), nil
+	case "cluster":
+		keyPrefix = clusterPrefix(cfg.KeyPrefix)
+		return redis.NewClusterClient(opts.Cluster()), nil
+	default:
+		return nil, fmt.Errorf("unknown redis mode %q", cfg.Mode)
+	}
+}
+
+// Key mengembalikan key dengan prefix namespace (REDIS_KEY_PREFIX), supaya beberapa
+// environment bisa berbagi satu Redis tanpa bentrok
+func Key(key string) string {
+	return keyPrefix + key
+}
+
+// clusterPrefix memastikan prefix berisi hash tag. Prefix yang sudah berisi "{...}" dipakai apa adanya.
+func clusterPrefix(prefix string) string {
+	if open := strings.Index(prefix, "{"); open >= 0 && strings.Contains(prefix[open:], "}") {
+		return prefix
+	}
+	tag := strings.TrimSuffix(prefix, ":")
+	if tag == "" {
+		tag = "betest"
+	}
+	return "{" + tag + "}:"
+}
+
+func newTLSConfig(cfg config.RedisConfig) (*tls.Config, error) {
+	tlsConfig := &tls.Config{
+		MinVersion:         tls.VersionTLS12,
+		ServerName:         cfg.TLSServerName,
+		InsecureSkipVerify: cfg.TLSSkipVerify,
+	}
+
+	if cfg.TLSCAFile != "" {
+		pem, err := os.ReadFile(cfg.TLSCAFile)
+		if err != nil {
+			return nil, fmt.Errorf("read redis CA file: %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificates found in %s", cfg.TLSCAFile)
+		}
+		tlsConfig.RootCAs = pool
+	}
+
+	if cfg.TLSCertFile != "" || cfg.TLSKeyFile != "" {
+		cert, err := tls.LoadX509KeyPair(cfg.TLSCertFile, cfg.TLSKeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("load redis client certificate: %w", err)
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+
+	return tlsConfig, nil
+}
@@ -0,0 +1,48 @@
+package repository
+
+import (
+	"betest/internal/database"
+	"betest/internal/models"
+	"context"
+)
+
+// Query user yang dipakai di dalam transaksi (lewat database.WithTx) maupun langsung ke database.DB.
+// sql.ErrNoRows dikembalikan apa adanya jika user tidak ditemukan.
+
+const userColumns = "id, name, email, created_at"
+
+func scanUser(row interface{ Scan(...interface{}) error }) (models.User, error) {
+	var u models.User
+	err := row.Scan(&u.ID, &u.Name, &u.Email, &u.CreatedAt)
+	return u, err
+}
+
+// CreateUser menyimpan user baru. Password (hash) kosong disimpan sebagai NULL.
+func CreateUser(ctx context.Context, q database.DBTX, u models.User) (models.User, error) {
+	return scanUser(q.QueryRowContext(ctx,
+		"INSERT INTO users (name, email, password) VALUES ($1, $2, NULLIF($3, '')) RETURNING "+userColumns,
+		u.Name, u.Email, u.Password))
+}
+
+// GetUserForUpdate mengambil dan mengunci baris user sampai transaksinya selesai
+func GetUserForUpdate(ctx context.Context, q database.DBTX, id int) (models.User, error) {
+	return scanUser(q.QueryRowContext(ctx, "SELECT "+userColumns+" FROM users WHERE id=$1 FOR UPDATE", id))
+}
+
+// UpdateUser mengganti nama dan email user dan mengembalikan data terbarunya
+func UpdateUser(ctx context.Context, q database.DBTX, id int, name, email string) (models.User, error) {
+	return scanUser(q.QueryRowContext(ctx,
+		"UPDATE users SET name=$1, email=$2 WHERE id=$3 RETURNING "+userColumns, name, email, id))
+}
+
+// DeleteUser menghapus user dan mengembalikan data terakhirnya (untuk audit dan event)
+func DeleteUser(ctx context.Context, q database.DBTX, id int) (models.User, error) {
+	return scanUser(q.QueryRowContext(ctx, "DELETE FROM users WHERE id=$1 RETURNING "+userColumns, id))
+}
+
+// GetUserRole mengambil role user (misalnya untuk membatasi scope OAuth2 yang boleh diberikan)
+func GetUserRole(ctx context.Context, q database.DBTX, id int) (string, error) {
+	var role string
+	err := q.QueryRowContext(ctx, "SELECT role FROM users WHERE id=$1", id).Scan(&role)
+	return role, err
+}
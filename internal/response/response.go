@@ -43,3 +43,14 @@ func SendSuccessNoData(w http.ResponseWriter, statusCode int, message string) {
 		Message: message,
 	})
 }
+
+// SendErrorWithData mengirim respons error beserta data pendukung (misalnya laporan validasi)
+func SendErrorWithData(w http.ResponseWriter, statusCode int, message string, data interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(statusCode)
+	json.NewEncoder(w).Encode(Response{
+		Success: false,
+		Data:    data,
+		Error:   message,
+	})
+}
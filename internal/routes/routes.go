@@ -1,30 +1,100 @@
 package routes
 
 import (
+	"betest/internal/config"
 	"betest/internal/handlers"
+	"betest/internal/metrics"
 	"betest/internal/middleware"
+	"betest/internal/oauth"
+	"net/http"
 
 	"github.com/gorilla/mux"
 )
 
-func SetupRoutes() *mux.Router {
+func SetupRoutes(cfg *config.Config) http.Handler {
 	r := mux.NewRouter()
 
+	// Prometheus metrics
+	r.Handle("/metrics", metrics.Handler()).Methods("GET")
+
+	// Health check untuk orchestrator: liveness dan readiness
+	r.HandleFunc("/healthz", handlers.Healthz).Methods("GET")
+	r.HandleFunc("/readyz", handlers.Readyz).Methods("GET")
+
 	// Auth routes (public)
 	r.HandleFunc("/register", handlers.Register).Methods("POST")
 	r.HandleFunc("/login", handlers.Login).Methods("POST")
-	r.HandleFunc("/refresh", handlers.RefreshToken).Methods("POST")
+	// Refresh diautentikasi dengan cookie, jadi butuh CSRF token (header X-CSRF-Token)
+	r.Handle("/refresh", middleware.CSRF(handlers.Cookies)(http.HandlerFunc(handlers.RefreshToken))).Methods("POST")
+
+	// OAuth2 authorization server. /oauth/authorize dipanggil halaman consent di frontend
+	// dengan access token user; endpoint lain diautentikasi dengan kredensial client.
+	r.Handle("/oauth/authorize", middleware.JWTMiddleware(http.HandlerFunc(handlers.OAuthAuthorize))).Methods("GET", "POST")
+	r.HandleFunc("/oauth/token", handlers.OAuthToken).Methods("POST")
+	r.HandleFunc("/oauth/introspect", handlers.IntrospectToken).Methods("POST")
+	r.HandleFunc("/oauth/revoke", handlers.RevokeToken).Methods("POST")
+
+	// Introspection dan revocation untuk service lain (API key atau kredensial client)
+	r.HandleFunc("/introspect", handlers.IntrospectToken).Methods("POST")
+	r.HandleFunc("/revoke", handlers.RevokeToken).Methods("POST")
 
 	// Protected routes (require Authorization: Bearer <access_token>)
 	protected := r.PathPrefix("/api").Subrouter()
 	protected.Use(middleware.JWTMiddleware)
 
 	protected.HandleFunc("/logout", handlers.Logout).Methods("POST")
-	protected.HandleFunc("/users", handlers.GetUsers).Methods("GET")
-	protected.HandleFunc("/users/{id}", handlers.GetUser).Methods("GET")
-	protected.HandleFunc("/users", handlers.CreateUser).Methods("POST")
-	protected.HandleFunc("/users/{id}", handlers.UpdateUser).Methods("PUT")
-	protected.HandleFunc("/users/{id}", handlers.DeleteUser).Methods("DELETE")
 
-	return r
+	// Token OAuth2 dibatasi scope-nya; token first-party selalu lolos RequireScope
+	usersRead := protected.PathPrefix("").Subrouter()
+	usersRead.Use(middleware.RequireScope(oauth.ScopeUsersRead))
+
+	usersRead.HandleFunc("/users", handlers.GetUsers).Methods("GET")
+	// Export harus didaftarkan sebelum /users/{id} supaya tidak tertangkap sebagai ID
+	usersRead.HandleFunc("/users/export", handlers.ExportUsers).Methods("GET")
+	usersRead.HandleFunc("/users/{id}", handlers.GetUser).Methods("GET")
+
+	usersWrite := protected.PathPrefix("").Subrouter()
+	usersWrite.Use(middleware.RequireScope(oauth.ScopeUsersWrite))
+
+	usersWrite.HandleFunc("/users/import", handlers.ImportUsers).Methods("POST")
+	usersWrite.HandleFunc("/users", handlers.CreateUser).Methods("POST")
+	usersWrite.HandleFunc("/users/{id}", handlers.UpdateUser).Methods("PUT")
+	usersWrite.HandleFunc("/users/{id}", handlers.DeleteUser).Methods("DELETE")
+
+	// Admin routes (require role admin, dan scope admin untuk token OAuth2)
+	admin := protected.PathPrefix("").Subrouter()
+	admin.Use(middleware.RequireScope(oauth.ScopeAdmin), middleware.RequireRole(middleware.RoleAdmin))
+
+	admin.HandleFunc("/audit", handlers.GetAuditLogs).Methods("GET")
+
+	admin.HandleFunc("/webhooks", handlers.GetWebhooks).Methods("GET")
+	admin.HandleFunc("/webhooks", handlers.CreateWebhook).Methods("POST")
+	admin.HandleFunc("/webhooks/{id}", handlers.GetWebhook).Methods("GET")
+	admin.HandleFunc("/webhooks/{id}", handlers.UpdateWebhook).Methods("PUT")
+	admin.HandleFunc("/webhooks/{id}", handlers.DeleteWebhook).Methods("DELETE")
+	admin.HandleFunc("/webhooks/{id}/deliveries", handlers.GetWebhookDeliveries).Methods("GET")
+
+	admin.HandleFunc("/oauth/clients", handlers.GetOAuthClients).Methods("GET")
+	admin.HandleFunc("/oauth/clients", handlers.CreateOAuthClient).Methods("POST")
+	admin.HandleFunc("/oauth/clients/{id}", handlers.GetOAuthClient).Methods("GET")
+	admin.HandleFunc("/oauth/clients/{id}", handlers.DeleteOAuthClient).Methods("DELETE")
+
+	// Middleware di level server (berlaku juga untuk request yang tidak cocok dengan route manapun).
+	// Urutan: request ID dulu supaya access log ikut mencatatnya,
+	// lalu tracing supaya trace_id juga ada di access log.
+	// Timeout paling dalam supaya response 503-nya tetap tercatat di metric dan access log,
+	// kecuali import/export yang memproses data dalam jumlah besar (export juga di-stream).
+	// CORS di luar timeout supaya response 503 juga membawa header CORS, dan menjawab preflight
+	// OPTIONS sebelum router (route hanya terdaftar untuk method aslinya).
+	var h http.Handler = r
+	h = middleware.Timeout(r, cfg.Server.HandlerTimeout, "/api/users/import", "/api/users/export")(h)
+	h = middleware.CORS(cfg.CORS)(h)
+	h = middleware.SecurityHeaders(cfg.Security)(h)
+	h = middleware.Metrics(r)(h)
+	h = middleware.AccessLog(r)(h)
+	h = middleware.Tracing(r)(h)
+	h = middleware.ClientCert(h)
+	h = middleware.RequestIDMiddleware(h)
+
+	return h
 }
@@ -0,0 +1,202 @@
+package servertls
+
+import (
+	"betest/internal/config"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"log/slog"
+	"os"
+	"sync"
+	"time"
+)
+
+// Enabled menandakan TLS_CERT_FILE dan TLS_KEY_FILE diisi
+func Enabled(cfg config.TLSConfig) bool {
+	return cfg.CertFile != "" && cfg.KeyFile != ""
+}
+
+// New membuat tls.Config untuk http.Server. Certificate server diambil dari CertReloader,
+// jadi certificate baru di disk langsung dipakai untuk handshake berikutnya tanpa restart.
+// Jika TLS_CLIENT_CA_FILE diisi, client certificate diverifikasi sesuai TLS_CLIENT_AUTH;
+// TLS_CLIENT_AUTH selain none tanpa TLS_CLIENT_CA_FILE adalah error konfigurasi.
+func New(cfg config.TLSConfig, reloader *CertReloader) (*tls.Config, error) {
+	tlsConfig := &tls.Config{
+		GetCertificate: reloader.GetCertificate,
+	}
+
+	switch cfg.MinVersion {
+	case "1.2", "":
+		tlsConfig.MinVersion = tls.VersionTLS12
+	case "1.3":
+		tlsConfig.MinVersion = tls.VersionTLS13
+	default:
+		return nil, fmt.Errorf("unsupported TLS_MIN_VERSION %q", cfg.MinVersion)
+	}
+
+	if len(cfg.CipherSuites) > 0 {
+		ids, err := cipherSuiteIDs(cfg.CipherSuites)
+		if err != nil {
+			return nil, err
+		}
+		tlsConfig.CipherSuites = ids
+	}
+
+	clientAuth, err := clientAuthType(cfg.ClientAuth)
+	if err != nil {
+		return nil, err
+	}
+	if cfg.ClientCAFile == "" {
+		// Tanpa CA client certificate tidak bisa diverifikasi; lebih baik gagal start
+		// daripada server berjalan tanpa mTLS yang diminta
+		if cfg.ClientAuth != "" && clientAuth != tls.NoClientCert {
+			return nil, fmt.Errorf("TLS_CLIENT_AUTH %q requires TLS_CLIENT_CA_FILE", cfg.ClientAuth)
+		}
+		return tlsConfig, nil
+	}
+
+	pem, err := os.ReadFile(cfg.ClientCAFile)
+	if err != nil {
+		return nil, fmt.Errorf("read client CA file: %w", err)
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(pem) {
+		return nil, fmt.Errorf("no certificates found in %s", cfg.ClientCAFile)
+	}
+	tlsConfig.ClientCAs = pool
+	tlsConfig.ClientAuth = clientAuth
+
+	return tlsConfig, nil
+}
+
+// clientAuthType mengubah TLS_CLIENT_AUTH menjadi tls.ClientAuthType. Kosong berarti verify_if_given.
+func clientAuthType(name string) (tls.ClientAuthType, error) {
+	switch name {
+	case "none":
+		return tls.NoClientCert, nil
+	case "request":
+		return tls.RequestClientCert, nil
+	case "verify_if_given", "":
+		return tls.VerifyClientCertIfGiven, nil
+	case "require":
+		return tls.RequireAndVerifyClientCert, nil
+	default:
+		return 0, fmt.Errorf("unknown TLS_CLIENT_AUTH %q", name)
+	}
+}
+
+// cipherSuiteIDs mengubah nama cipher suite (misalnya TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256)
+// menjadi ID. Cipher suite yang dianggap tidak aman oleh Go ditolak.
+func cipherSuiteIDs(names []string) ([]uint16, error) {
+	known := make(map[string]uint16)
+	for _, s := range tls.CipherSuites() {
+		known[s.Name] = s.ID
+	}
+	ids := make([]uint16, 0, len(names))
+	for _, name := range names {
+		id, ok := known[name]
+		if !ok {
+			return nil, fmt.Errorf("unknown or insecure cipher suite %q", name)
+		}
+		ids = append(ids, id)
+	}
+	return ids, nil
+}
+
+// CertReloader menyimpan certificate server dan memuat ulang jika file cert/key berubah
+// (misalnya diperbarui cert-manager atau certbot)
+type CertReloader struct {
+	certFile string
+	keyFile  string
+
+	mu      sync.RWMutex
+	cert    *tls.Certificate
+	modTime time.Time
+}
+
+// NewCertReloader memuat certificate pertama kali; error jika file tidak valid
+func NewCertReloader(certFile, keyFile string) (*CertReloader, error) {
+	r := &CertReloader{certFile: certFile, keyFile: keyFile}
+	if err := r.reload(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// GetCertificate dipasang di tls.Config.GetCertificate
+func (r *CertReloader) GetCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.cert, nil
+}
+
+// Run mengecek waktu modifikasi file setiap interval sampai ctx dibatalkan. Jika certificate
+// baru gagal dimuat (misalnya cert dan key belum selesai ditulis) certificate lama tetap dipakai
+// dan dicoba lagi di interval berikutnya.
+func (r *CertReloader) Run(ctx context.Context, interval time.Duration) {
+	if interval <= 0 {
+		return
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		modTime, err := r.latestModTime()
+		if err != nil {
+			slog.WarnContext(ctx, "Error checking TLS certificate", "error", err)
+			continue
+		}
+		r.mu.RLock()
+		changed := modTime.After(r.modTime)
+		r.mu.RUnlock()
+		if !changed {
+			continue
+		}
+
+		if err := r.reload(); err != nil {
+			slog.ErrorContext(ctx, "Error reloading TLS certificate, keeping the current one", "error", err)
+			continue
+		}
+		slog.InfoContext(ctx, "TLS certificate reloaded", "cert_file", r.certFile)
+	}
+}
+
+func (r *CertReloader) reload() error {
+	// Waktu modifikasi dibaca sebelum file dimuat, supaya perubahan yang terjadi
+	// saat memuat tetap terdeteksi di pengecekan berikutnya
+	modTime, err := r.latestModTime()
+	if err != nil {
+		return err
+	}
+	cert, err := tls.LoadX509KeyPair(r.certFile, r.keyFile)
+	if err != nil {
+		return fmt.Errorf("load TLS certificate: %w", err)
+	}
+
+	r.mu.Lock()
+	r.cert = &cert
+	r.modTime = modTime
+	r.mu.Unlock()
+	return nil
+}
+
+func (r *CertReloader) latestModTime() (time.Time, error) {
+	var latest time.Time
+	for _, name := range []string{r.certFile, r.keyFile} {
+		info, err := os.Stat(name)
+		if err != nil {
+			return time.Time{}, err
+		}
+		if info.ModTime().After(latest) {
+			latest = info.ModTime()
+		}
+	}
+	return latest, nil
+}
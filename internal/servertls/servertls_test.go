@@ -0,0 +1,84 @@
+package servertls
+
+import (
+	"betest/internal/config"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// writeTestCA menulis certificate CA self-signed ke file sementara
+func writeTestCA(t *testing.T) string {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("GenerateKey: %v", err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "test CA"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("CreateCertificate: %v", err)
+	}
+	path := filepath.Join(t.TempDir(), "ca.pem")
+	if err := os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0o600); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+	return path
+}
+
+func TestNewClientAuth(t *testing.T) {
+	ca := writeTestCA(t)
+	tests := []struct {
+		name       string
+		caFile     string
+		clientAuth string
+		wantErr    bool
+		want       tls.ClientAuthType
+	}{
+		{"no mTLS", "", "", false, tls.NoClientCert},
+		{"explicit none without CA", "", "none", false, tls.NoClientCert},
+		{"require without CA", "", "require", true, 0},
+		{"verify_if_given without CA", "", "verify_if_given", true, 0},
+		{"request without CA", "", "request", true, 0},
+		{"unknown without CA", "", "always", true, 0},
+		{"default with CA", ca, "", false, tls.VerifyClientCertIfGiven},
+		{"require with CA", ca, "require", false, tls.RequireAndVerifyClientCert},
+		{"none with CA", ca, "none", false, tls.NoClientCert},
+		{"unknown with CA", ca, "always", true, 0},
+		{"missing CA file", filepath.Join(t.TempDir(), "missing.pem"), "require", true, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg, err := New(config.TLSConfig{ClientCAFile: tt.caFile, ClientAuth: tt.clientAuth}, nil)
+			if tt.wantErr {
+				if err == nil {
+					t.Error("New succeeded; want error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("New: %v", err)
+			}
+			if cfg.ClientAuth != tt.want || (tt.caFile != "") != (cfg.ClientCAs != nil) {
+				t.Errorf("ClientAuth = %v, ClientCAs set = %v; want %v", cfg.ClientAuth, cfg.ClientCAs != nil, tt.want)
+			}
+		})
+	}
+}
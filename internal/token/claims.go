@@ -0,0 +1,95 @@
+package token
+
+import (
+	"errors"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+)
+
+// Nilai claim token_use, supaya refresh token tidak bisa dipakai sebagai access token (dan sebaliknya)
+const (
+	UseAccess  = "access"
+	UseRefresh = "refresh"
+)
+
+// Validasi claim, di-set dari main (JWT_ISSUER, JWT_AUDIENCE, JWT_LEEWAY)
+var (
+	Issuer   = "betest"
+	Audience = "betest-api"
+	Leeway   = 30 * time.Second // Toleransi selisih jam untuk exp, nbf dan iat
+)
+
+var (
+	ErrWrongTokenUse = errors.New("token: wrong token_use")
+	ErrMissingClaims = errors.New("token: missing subject or jti")
+)
+
+// Claims adalah claim access dan refresh token. Token first-party (login langsung) tidak punya
+// client_id dan scope; token OAuth2 membawa client_id dan scope yang diberikan ke client tersebut.
+// Token client_credentials tidak mewakili user, jadi user_id-nya 0.
+// auth_time (waktu login) dan remember_me dibawa dari token ke token saat refresh token di-rotate,
+// supaya umur maksimal sesi dihitung dari login pertama.
+type Claims struct {
+	UserID     int              `json:"user_id,omitempty"`
+	TokenUse   string           `json:"token_use"`
+	ClientID   string           `json:"client_id,omitempty"`
+	Scope      string           `json:"scope,omitempty"`
+	AuthTime   *jwt.NumericDate `json:"auth_time,omitempty"`
+	RememberMe bool             `json:"remember_me,omitempty"`
+	jwt.RegisteredClaims
+}
+
+// HasScope menandakan token boleh dipakai untuk scope. Token first-party punya akses penuh
+// sesuai role user-nya, jadi selalu true.
+func (c *Claims) HasScope(scope string) bool {
+	if c.ClientID == "" {
+		return true
+	}
+	return slices.Contains(strings.Fields(c.Scope), scope)
+}
+
+// NewClaims membuat claim baru dengan jti acak, iss, aud, iat, nbf dan exp = sekarang + ttl
+func NewClaims(userID int, use string, ttl time.Duration) *Claims {
+	now := time.Now()
+	return &Claims{
+		UserID:   userID,
+		TokenUse: use,
+		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        uuid.New().String(),
+			Subject:   strconv.Itoa(userID),
+			Issuer:    Issuer,
+			Audience:  jwt.ClaimStrings{Audience},
+			IssuedAt:  jwt.NewNumericDate(now),
+			NotBefore: jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(now.Add(ttl)),
+		},
+	}
+}
+
+// Parse memverifikasi signature (hanya algoritma milik v) dan semua claim: exp (wajib), nbf, iat, iss, aud,
+// token_use harus sama dengan use, serta jti dan user_id (atau client_id untuk token client_credentials) harus ada.
+func Parse(tokenString string, v Verifier, use string) (*Claims, error) {
+	claims := &Claims{}
+	err := v.Verify(tokenString, claims,
+		jwt.WithIssuer(Issuer),
+		jwt.WithAudience(Audience),
+		jwt.WithLeeway(Leeway),
+		jwt.WithExpirationRequired(),
+		jwt.WithIssuedAt(),
+	)
+	if err != nil {
+		return nil, err
+	}
+	if claims.TokenUse != use {
+		return nil, ErrWrongTokenUse
+	}
+	if (claims.UserID <= 0 && claims.ClientID == "") || claims.ID == "" {
+		return nil, ErrMissingClaims
+	}
+	return claims, nil
+}
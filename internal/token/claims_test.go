@@ -0,0 +1,179 @@
+package token
+
+import (
+	"betest/internal/config"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+func newTestKeys(t *testing.T) *Keys {
+	t.Helper()
+	keys, err := NewKeys(config.JWTConfig{Algorithm: "HS256", Secret: "0123456789abcdef0123456789abcdef"})
+	if err != nil {
+		t.Fatalf("NewKeys: %v", err)
+	}
+	return keys
+}
+
+func TestParse(t *testing.T) {
+	keys := newTestKeys(t)
+
+	tests := []struct {
+		name    string
+		claims  func() *Claims
+		use     string
+		wantErr error
+	}{
+		{
+			name:   "valid access token",
+			claims: func() *Claims { return NewClaims(42, UseAccess, time.Minute) },
+			use:    UseAccess,
+		},
+		{
+			name:   "valid refresh token",
+			claims: func() *Claims { return NewClaims(42, UseRefresh, time.Hour) },
+			use:    UseRefresh,
+		},
+		{
+			name: "client_credentials token without user",
+			claims: func() *Claims {
+				c := NewClaims(0, UseAccess, time.Minute)
+				c.ClientID = "reports"
+				return c
+			},
+			use: UseAccess,
+		},
+		{
+			name:    "refresh token used as access token",
+			claims:  func() *Claims { return NewClaims(42, UseRefresh, time.Hour) },
+			use:     UseAccess,
+			wantErr: ErrWrongTokenUse,
+		},
+		{
+			name:    "access token used as refresh token",
+			claims:  func() *Claims { return NewClaims(42, UseAccess, time.Minute) },
+			use:     UseRefresh,
+			wantErr: ErrWrongTokenUse,
+		},
+		{
+			name: "wrong issuer",
+			claims: func() *Claims {
+				c := NewClaims(42, UseAccess, time.Minute)
+				c.Issuer = "someone-else"
+				return c
+			},
+			use:     UseAccess,
+			wantErr: jwt.ErrTokenInvalidIssuer,
+		},
+		{
+			name: "wrong audience",
+			claims: func() *Claims {
+				c := NewClaims(42, UseAccess, time.Minute)
+				c.Audience = jwt.ClaimStrings{"other-api"}
+				return c
+			},
+			use:     UseAccess,
+			wantErr: jwt.ErrTokenInvalidAudience,
+		},
+		{
+			name: "missing exp",
+			claims: func() *Claims {
+				c := NewClaims(42, UseAccess, time.Minute)
+				c.ExpiresAt = nil
+				return c
+			},
+			use:     UseAccess,
+			wantErr: jwt.ErrTokenRequiredClaimMissing,
+		},
+		{
+			name:    "expired beyond leeway",
+			claims:  func() *Claims { return NewClaims(42, UseAccess, -Leeway-time.Minute) },
+			use:     UseAccess,
+			wantErr: jwt.ErrTokenExpired,
+		},
+		{
+			name:   "expired within leeway",
+			claims: func() *Claims { return NewClaims(42, UseAccess, -Leeway/2) },
+			use:    UseAccess,
+		},
+		{
+			name: "not valid yet",
+			claims: func() *Claims {
+				c := NewClaims(42, UseAccess, time.Hour)
+				c.NotBefore = jwt.NewNumericDate(time.Now().Add(Leeway + time.Minute))
+				return c
+			},
+			use:     UseAccess,
+			wantErr: jwt.ErrTokenNotValidYet,
+		},
+		{
+			name: "issued in the future",
+			claims: func() *Claims {
+				c := NewClaims(42, UseAccess, time.Hour)
+				c.IssuedAt = jwt.NewNumericDate(time.Now().Add(Leeway + time.Minute))
+				return c
+			},
+			use:     UseAccess,
+			wantErr: jwt.ErrTokenUsedBeforeIssued,
+		},
+		{
+			name: "missing jti",
+			claims: func() *Claims {
+				c := NewClaims(42, UseAccess, time.Minute)
+				c.ID = ""
+				return c
+			},
+			use:     UseAccess,
+			wantErr: ErrMissingClaims,
+		},
+		{
+			name:    "missing user and client",
+			claims:  func() *Claims { return NewClaims(0, UseAccess, time.Minute) },
+			use:     UseAccess,
+			wantErr: ErrMissingClaims,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			want := tt.claims()
+			signed, err := keys.Sign(want)
+			if err != nil {
+				t.Fatalf("Sign: %v", err)
+			}
+
+			got, err := Parse(signed, keys, tt.use)
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Errorf("err = %v; want %v", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Parse: %v", err)
+			}
+			if got.ID != want.ID || got.UserID != want.UserID || got.ClientID != want.ClientID || got.TokenUse != want.TokenUse {
+				t.Errorf("claims = %+v; want %+v", got, want)
+			}
+		})
+	}
+}
+
+func TestHasScope(t *testing.T) {
+	firstParty := NewClaims(42, UseAccess, time.Minute)
+	if !firstParty.HasScope("users:write") {
+		t.Error("first-party token must have every scope")
+	}
+
+	client := NewClaims(42, UseAccess, time.Minute)
+	client.ClientID = "reports"
+	client.Scope = "users:read profile"
+	if !client.HasScope("users:read") || !client.HasScope("profile") {
+		t.Error("client token is missing a granted scope")
+	}
+	if client.HasScope("users:write") || client.HasScope("users") {
+		t.Error("client token has a scope that was not granted")
+	}
+}
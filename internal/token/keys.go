@@ -0,0 +1,156 @@
+package token
+
+import (
+	"betest/internal/config"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"fmt"
+	"log/slog"
+	"os"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// Signer menandatangani claim menjadi JWT
+type Signer interface {
+	Alg() string
+	Sign(claims jwt.Claims) (string, error)
+}
+
+// Verifier memverifikasi signature JWT dan mengisi claims. Hanya algoritma yang dikonfigurasi
+// yang diterima, jadi token dengan header alg lain (misalnya "none" atau HS256 yang
+// ditandatangani dengan public key RSA) selalu ditolak.
+type Verifier interface {
+	Verify(tokenString string, claims jwt.Claims, opts ...jwt.ParserOption) error
+}
+
+// Keys adalah Signer dan Verifier untuk satu algoritma dan satu key
+type Keys struct {
+	method     jwt.SigningMethod
+	signKey    interface{}
+	verifyKey  interface{}
+	publicKey  crypto.PublicKey // nil untuk HMAC
+	validAlgos []string
+}
+
+// NewKeys membuat Keys sesuai JWT_ALGORITHM:
+//   - RS256, ES256, EdDSA: private key PEM dari JWT_PRIVATE_KEY_FILE; jika kosong key dibuat acak
+//     saat startup (hanya untuk development, token tidak berlaku setelah restart dan tidak bisa
+//     diverifikasi instance lain)
+//   - HS256: secret bersama dari JWT_SECRET (minimal 32 byte), hanya untuk deployment satu service
+//     karena siapa pun yang bisa memverifikasi juga bisa menerbitkan token
+func NewKeys(cfg config.JWTConfig) (*Keys, error) {
+	switch cfg.Algorithm {
+	case "HS256":
+		if len(cfg.Secret) < 32 {
+			return nil, fmt.Errorf("JWT_SECRET must be at least 32 bytes for HS256")
+		}
+		secret := []byte(cfg.Secret)
+		return newKeys(jwt.SigningMethodHS256, secret, secret, nil), nil
+	case "RS256", "ES256", "EdDSA":
+	default:
+		return nil, fmt.Errorf("unsupported JWT_ALGORITHM %q", cfg.Algorithm)
+	}
+
+	var private crypto.Signer
+	var err error
+	if cfg.PrivateKeyFile != "" {
+		private, err = loadPrivateKey(cfg.Algorithm, cfg.PrivateKeyFile)
+	} else {
+		slog.Warn("JWT_PRIVATE_KEY_FILE is not set, generating an ephemeral signing key", "alg", cfg.Algorithm)
+		private, err = generatePrivateKey(cfg.Algorithm)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	switch cfg.Algorithm {
+	case "RS256":
+		return newKeys(jwt.SigningMethodRS256, private, private.Public(), private.Public()), nil
+	case "ES256":
+		return newKeys(jwt.SigningMethodES256, private, private.Public(), private.Public()), nil
+	default:
+		return newKeys(jwt.SigningMethodEdDSA, private, private.Public(), private.Public()), nil
+	}
+}
+
+func newKeys(method jwt.SigningMethod, signKey, verifyKey interface{}, public crypto.PublicKey) *Keys {
+	return &Keys{
+		method:     method,
+		signKey:    signKey,
+		verifyKey:  verifyKey,
+		publicKey:  public,
+		validAlgos: []string{method.Alg()},
+	}
+}
+
+func (k *Keys) Alg() string {
+	return k.method.Alg()
+}
+
+func (k *Keys) Sign(claims jwt.Claims) (string, error) {
+	return jwt.NewWithClaims(k.method, claims).SignedString(k.signKey)
+}
+
+func (k *Keys) Verify(tokenString string, claims jwt.Claims, opts ...jwt.ParserOption) error {
+	opts = append(opts, jwt.WithValidMethods(k.validAlgos))
+	_, err := jwt.ParseWithClaims(tokenString, claims, func(*jwt.Token) (interface{}, error) {
+		return k.verifyKey, nil
+	}, opts...)
+	return err
+}
+
+// PublicKey mengembalikan public key untuk dipublikasikan (nil untuk HS256)
+func (k *Keys) PublicKey() crypto.PublicKey {
+	return k.publicKey
+}
+
+func loadPrivateKey(alg, path string) (crypto.Signer, error) {
+	pem, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read JWT private key: %w", err)
+	}
+
+	switch alg {
+	case "RS256":
+		key, err := jwt.ParseRSAPrivateKeyFromPEM(pem)
+		if err != nil {
+			return nil, fmt.Errorf("parse RSA private key: %w", err)
+		}
+		if key.N.BitLen() < 2048 {
+			return nil, fmt.Errorf("RSA key must be at least 2048 bits")
+		}
+		return key, nil
+	case "ES256":
+		key, err := jwt.ParseECPrivateKeyFromPEM(pem)
+		if err != nil {
+			return nil, fmt.Errorf("parse EC private key: %w", err)
+		}
+		if key.Curve != elliptic.P256() {
+			return nil, fmt.Errorf("ES256 requires a P-256 key")
+		}
+		return key, nil
+	default:
+		key, err := jwt.ParseEdPrivateKeyFromPEM(pem)
+		if err != nil {
+			return nil, fmt.Errorf("parse Ed25519 private key: %w", err)
+		}
+		return key.(crypto.Signer), nil
+	}
+}
+
+func generatePrivateKey(alg string) (crypto.Signer, error) {
+	switch alg {
+	case "RS256":
+		return rsa.GenerateKey(rand.Reader, 2048)
+	case "ES256":
+		return ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	default:
+		_, key, err := ed25519.GenerateKey(rand.Reader)
+		return key, err
+	}
+}
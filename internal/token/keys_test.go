@@ -0,0 +1,236 @@
+package token
+
+import (
+	"betest/internal/config"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+var testAlgorithms = []string{"RS256", "ES256", "EdDSA", "HS256"}
+
+func newKeysFor(t *testing.T, alg string) *Keys {
+	t.Helper()
+	keys, err := NewKeys(config.JWTConfig{Algorithm: alg, Secret: "0123456789abcdef0123456789abcdef"})
+	if err != nil {
+		t.Fatalf("NewKeys(%s): %v", alg, err)
+	}
+	return keys
+}
+
+func TestKeysRoundTrip(t *testing.T) {
+	for _, alg := range testAlgorithms {
+		t.Run(alg, func(t *testing.T) {
+			keys := newKeysFor(t, alg)
+			if keys.Alg() != alg {
+				t.Errorf("Alg() = %s; want %s", keys.Alg(), alg)
+			}
+			if (keys.PublicKey() == nil) != (alg == "HS256") {
+				t.Errorf("PublicKey() = %v; want nil only for HS256", keys.PublicKey())
+			}
+
+			want := NewClaims(42, UseAccess, time.Minute)
+			signed, err := keys.Sign(want)
+			if err != nil {
+				t.Fatalf("Sign: %v", err)
+			}
+			got, err := Parse(signed, keys, UseAccess)
+			if err != nil {
+				t.Fatalf("Parse: %v", err)
+			}
+			if got.ID != want.ID || got.UserID != 42 {
+				t.Errorf("claims = %+v; want %+v", got, want)
+			}
+		})
+	}
+}
+
+func TestKeysRejectOtherAlgorithms(t *testing.T) {
+	signers := make(map[string]*Keys, len(testAlgorithms))
+	for _, alg := range testAlgorithms {
+		signers[alg] = newKeysFor(t, alg)
+	}
+
+	for _, verifyAlg := range testAlgorithms {
+		verifier := signers[verifyAlg]
+		for _, signAlg := range testAlgorithms {
+			if signAlg == verifyAlg {
+				continue
+			}
+			t.Run(signAlg+" to "+verifyAlg, func(t *testing.T) {
+				signed, err := signers[signAlg].Sign(NewClaims(42, UseAccess, time.Minute))
+				if err != nil {
+					t.Fatalf("Sign: %v", err)
+				}
+				if _, err := Parse(signed, verifier, UseAccess); err == nil {
+					t.Error("token signed with another algorithm was accepted")
+				}
+			})
+		}
+	}
+}
+
+// HS256 yang ditandatangani dengan public key (yang memang dipublikasikan) sebagai secret HMAC
+// adalah serangan algorithm confusion klasik
+func TestKeysRejectHS256SignedWithPublicKey(t *testing.T) {
+	for _, alg := range []string{"RS256", "ES256", "EdDSA"} {
+		t.Run(alg, func(t *testing.T) {
+			keys := newKeysFor(t, alg)
+			der, err := x509.MarshalPKIXPublicKey(keys.PublicKey())
+			if err != nil {
+				t.Fatalf("marshal public key: %v", err)
+			}
+			publicPEM := pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der})
+
+			for name, secret := range map[string][]byte{"pem": publicPEM, "der": der} {
+				forged, err := jwt.NewWithClaims(jwt.SigningMethodHS256, NewClaims(42, UseAccess, time.Minute)).SignedString(secret)
+				if err != nil {
+					t.Fatalf("sign forged token: %v", err)
+				}
+				if _, err := Parse(forged, keys, UseAccess); err == nil {
+					t.Errorf("HS256 token signed with the %s public key was accepted", name)
+				}
+			}
+		})
+	}
+}
+
+// Algoritma lain dengan tipe key yang sama (PS256 dengan key RSA, HS512 dengan secret yang sama)
+// lolos pengecekan tipe key di jwt, jadi hanya ditolak karena algoritma dipin lewat WithValidMethods
+func TestKeysRejectSameKeyOtherAlgorithm(t *testing.T) {
+	tests := []struct {
+		alg    string
+		method jwt.SigningMethod
+	}{
+		{"RS256", jwt.SigningMethodPS256},
+		{"RS256", jwt.SigningMethodRS512},
+		{"HS256", jwt.SigningMethodHS512},
+	}
+	for _, tt := range tests {
+		t.Run(tt.alg+" with "+tt.method.Alg(), func(t *testing.T) {
+			keys := newKeysFor(t, tt.alg)
+			forged, err := jwt.NewWithClaims(tt.method, NewClaims(42, UseAccess, time.Minute)).SignedString(keys.signKey)
+			if err != nil {
+				t.Fatalf("sign forged token: %v", err)
+			}
+			if _, err := Parse(forged, keys, UseAccess); err == nil {
+				t.Errorf("token signed with %s was accepted by a %s verifier", tt.method.Alg(), tt.alg)
+			}
+		})
+	}
+}
+
+func TestKeysRejectAlgNone(t *testing.T) {
+	keys := newKeysFor(t, "HS256")
+	forged, err := jwt.NewWithClaims(jwt.SigningMethodNone, NewClaims(42, UseAccess, time.Minute)).
+		SignedString(jwt.UnsafeAllowNoneSignatureType)
+	if err != nil {
+		t.Fatalf("sign forged token: %v", err)
+	}
+	if _, err := Parse(forged, keys, UseAccess); err == nil {
+		t.Error(`token with alg "none" was accepted`)
+	}
+}
+
+func TestKeysRejectOtherKey(t *testing.T) {
+	for _, alg := range testAlgorithms {
+		t.Run(alg, func(t *testing.T) {
+			keys := newKeysFor(t, alg)
+			signed, err := keys.Sign(NewClaims(42, UseAccess, time.Minute))
+			if err != nil {
+				t.Fatalf("Sign: %v", err)
+			}
+			// Algoritma sama, key berbeda
+			other := newKeysFor(t, alg)
+			if alg == "HS256" {
+				other, err = NewKeys(config.JWTConfig{Algorithm: alg, Secret: "another-secret-of-at-least-32-bytes"})
+				if err != nil {
+					t.Fatalf("NewKeys: %v", err)
+				}
+			}
+			if _, err := Parse(signed, other, UseAccess); err == nil {
+				t.Error("token signed with another key was accepted")
+			}
+		})
+	}
+}
+
+func TestNewKeysConfigErrors(t *testing.T) {
+	dir := t.TempDir()
+	writePEM := func(name, typ string, der []byte) string {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: typ, Bytes: der}), 0o600); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+
+	small, err := rsa.GenerateKey(rand.Reader, 1024)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p384, err := ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p384DER, err := x509.MarshalECPrivateKey(p384)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name string
+		cfg  config.JWTConfig
+	}{
+		{"unknown algorithm", config.JWTConfig{Algorithm: "HS512"}},
+		{"short HS256 secret", config.JWTConfig{Algorithm: "HS256", Secret: "too-short"}},
+		{"missing key file", config.JWTConfig{Algorithm: "RS256", PrivateKeyFile: filepath.Join(dir, "missing.pem")}},
+		{"RSA key below 2048 bits", config.JWTConfig{Algorithm: "RS256", PrivateKeyFile: writePEM("small.pem", "RSA PRIVATE KEY", x509.MarshalPKCS1PrivateKey(small))}},
+		{"ES256 with a P-384 key", config.JWTConfig{Algorithm: "ES256", PrivateKeyFile: writePEM("p384.pem", "EC PRIVATE KEY", p384DER)}},
+		{"EdDSA with an RSA key", config.JWTConfig{Algorithm: "EdDSA", PrivateKeyFile: filepath.Join(dir, "small.pem")}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := NewKeys(tt.cfg); err == nil {
+				t.Error("NewKeys succeeded; want error")
+			}
+		})
+	}
+}
+
+func TestNewKeysFromFile(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	der, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(t.TempDir(), "es256.pem")
+	if err := os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: der}), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	keys, err := NewKeys(config.JWTConfig{Algorithm: "ES256", PrivateKeyFile: path})
+	if err != nil {
+		t.Fatalf("NewKeys: %v", err)
+	}
+	signed, err := keys.Sign(NewClaims(42, UseAccess, time.Minute))
+	if err != nil {
+		t.Fatalf("Sign: %v", err)
+	}
+	// Verifikasi dengan public key dari file membuktikan key yang dipakai adalah key dari file itu
+	if _, err := jwt.Parse(signed, func(*jwt.Token) (interface{}, error) { return &key.PublicKey, nil }); err != nil {
+		t.Errorf("token was not signed with the configured key: %v", err)
+	}
+}
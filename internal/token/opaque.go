@@ -0,0 +1,86 @@
+package token
+
+import (
+	"betest/internal/redisclient"
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+// Format access token (TOKEN_ACCESS_FORMAT). Refresh token selalu JWT.
+const (
+	FormatJWT    = "jwt"    // Self-contained, bisa diverifikasi service lain dengan public key
+	FormatOpaque = "opaque" // String acak, data sesi hanya ada di Redis
+)
+
+// opaquePrefix membedakan opaque token dari JWT (yang selalu diawali "eyJ")
+const opaquePrefix = "bto_"
+
+var ErrOpaqueNotFound = errors.New("token: opaque token not found or revoked")
+
+// ValidFormat memeriksa nilai TOKEN_ACCESS_FORMAT
+func ValidFormat(format string) error {
+	if format != FormatJWT && format != FormatOpaque {
+		return fmt.Errorf("unsupported access token format %q, expected %s or %s", format, FormatJWT, FormatOpaque)
+	}
+	return nil
+}
+
+// IsOpaque menandakan tokenString adalah opaque access token
+func IsOpaque(tokenString string) bool {
+	return strings.HasPrefix(tokenString, opaquePrefix)
+}
+
+// OpaqueID menghitung jti opaque token: SHA-256 dari token, jadi token aslinya tidak pernah disimpan
+func OpaqueID(tokenString string) string {
+	sum := sha256.Sum256([]byte(tokenString))
+	return hex.EncodeToString(sum[:])
+}
+
+// NewOpaque membuat opaque token acak untuk claims. claims.ID diganti dengan hash token,
+// dan session adalah claim dalam bentuk JSON yang disimpan di key access_token:<jti>.
+func NewOpaque(claims *Claims) (tokenString, session string, err error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", "", err
+	}
+	tokenString = opaquePrefix + base64.RawURLEncoding.EncodeToString(b)
+	claims.ID = OpaqueID(tokenString)
+
+	data, err := json.Marshal(claims)
+	if err != nil {
+		return "", "", err
+	}
+	return tokenString, string(data), nil
+}
+
+// LookupOpaque mengambil claim opaque access token dari Redis. Token yang sudah dicabut
+// (key-nya dihapus) atau kedaluwarsa mengembalikan ErrOpaqueNotFound, tanpa perlu cek blacklist.
+func LookupOpaque(ctx context.Context, rdb redis.UniversalClient, tokenString string) (*Claims, error) {
+	data, err := rdb.Get(ctx, redisclient.Key(fmt.Sprintf("access_token:%s", OpaqueID(tokenString)))).Bytes()
+	if err == redis.Nil {
+		return nil, ErrOpaqueNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	claims := &Claims{}
+	if err := json.Unmarshal(data, claims); err != nil {
+		return nil, err
+	}
+	// Key dibuat dengan TTL yang sama dengan exp, tapi tetap dicek jika TTL gagal diset
+	if claims.TokenUse != UseAccess || claims.ExpiresAt == nil || time.Now().After(claims.ExpiresAt.Time) {
+		return nil, ErrOpaqueNotFound
+	}
+	return claims, nil
+}
@@ -0,0 +1,126 @@
+package token
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/go-redis/redis/v8"
+)
+
+func TestNewOpaque(t *testing.T) {
+	claims := NewClaims(42, UseAccess, time.Minute)
+	claims.ClientID = "reports"
+	claims.Scope = "users:read"
+	jwtID := claims.ID
+
+	tokenString, session, err := NewOpaque(claims)
+	if err != nil {
+		t.Fatalf("NewOpaque: %v", err)
+	}
+	if !IsOpaque(tokenString) || len(tokenString) != len(opaquePrefix)+43 {
+		t.Errorf("token = %q; want %s followed by 32 random bytes in base64url", tokenString, opaquePrefix)
+	}
+
+	// jti adalah SHA-256 dari token, jadi token asli tidak pernah tersimpan di Redis
+	sum := sha256.Sum256([]byte(tokenString))
+	if claims.ID != hex.EncodeToString(sum[:]) || claims.ID != OpaqueID(tokenString) || claims.ID == jwtID {
+		t.Errorf("claims.ID = %q; want SHA-256 of the token", claims.ID)
+	}
+	if len(session) == 0 || strings.Contains(session, tokenString) {
+		t.Errorf("session = %s; must not contain the token itself", session)
+	}
+
+	other, _, err := NewOpaque(NewClaims(42, UseAccess, time.Minute))
+	if err != nil || other == tokenString {
+		t.Errorf("second token = %q, %v; want a different token", other, err)
+	}
+}
+
+func TestIsOpaque(t *testing.T) {
+	keys := newTestKeys(t)
+	signed, err := keys.Sign(NewClaims(42, UseAccess, time.Minute))
+	if err != nil {
+		t.Fatalf("Sign: %v", err)
+	}
+	if IsOpaque(signed) {
+		t.Error("JWT was detected as an opaque token")
+	}
+	if IsOpaque("") || IsOpaque("bto") || !IsOpaque("bto_x") {
+		t.Error("IsOpaque must only match the bto_ prefix")
+	}
+}
+
+func TestLookupOpaque(t *testing.T) {
+	mr := miniredis.RunT(t)
+	rdb := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	t.Cleanup(func() { rdb.Close() })
+	ctx := context.Background()
+
+	store := func(claims *Claims) string {
+		t.Helper()
+		tokenString, session, err := NewOpaque(claims)
+		if err != nil {
+			t.Fatalf("NewOpaque: %v", err)
+		}
+		mr.Set("access_token:"+claims.ID, session)
+		return tokenString
+	}
+
+	want := NewClaims(42, UseAccess, time.Minute)
+	want.Scope = "users:read"
+	valid := store(want)
+	got, err := LookupOpaque(ctx, rdb, valid)
+	if err != nil {
+		t.Fatalf("LookupOpaque: %v", err)
+	}
+	if got.ID != want.ID || got.UserID != 42 || got.Scope != "users:read" || got.TokenUse != UseAccess {
+		t.Errorf("claims = %+v; want %+v", got, want)
+	}
+
+	tests := []struct {
+		name  string
+		token string
+	}{
+		{"unknown token", opaquePrefix + "unknown"},
+		{"revoked token", func() string {
+			s := store(NewClaims(42, UseAccess, time.Minute))
+			mr.Del("access_token:" + OpaqueID(s))
+			return s
+		}()},
+		// Key dengan TTL yang gagal diset tidak boleh membuat token berlaku selamanya
+		{"expired claims", store(NewClaims(42, UseAccess, -time.Second))},
+		{"refresh token claims", store(NewClaims(42, UseRefresh, time.Minute))},
+		{"claims without exp", func() string {
+			c := NewClaims(42, UseAccess, time.Minute)
+			c.ExpiresAt = nil
+			return store(c)
+		}()},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := LookupOpaque(ctx, rdb, tt.token); !errors.Is(err, ErrOpaqueNotFound) {
+				t.Errorf("err = %v; want ErrOpaqueNotFound", err)
+			}
+		})
+	}
+
+	// Error Redis bukan ErrOpaqueNotFound, supaya pemanggil tidak menganggapnya token yang dicabut
+	mr.Close()
+	if _, err := LookupOpaque(ctx, rdb, valid); err == nil || errors.Is(err, ErrOpaqueNotFound) {
+		t.Errorf("err = %v; want a Redis error", err)
+	}
+}
+
+func TestValidFormat(t *testing.T) {
+	for format, wantErr := range map[string]bool{FormatJWT: false, FormatOpaque: false, "": true, "JWT": true, "paseto": true} {
+		if err := ValidFormat(format); (err != nil) != wantErr {
+			t.Errorf("ValidFormat(%q) = %v; want error %v", format, err, wantErr)
+		}
+	}
+}
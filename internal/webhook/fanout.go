@@ -0,0 +1,32 @@
+package webhook
+
+import (
+	"betest/internal/events"
+	"context"
+	"database/sql"
+	"encoding/json"
+)
+
+// FanoutSink adalah sink outbox yang membuat satu delivery untuk setiap webhook aktif
+// yang berlangganan event tersebut. Pengiriman HTTP-nya dilakukan oleh Worker.
+type FanoutSink struct {
+	DB *sql.DB
+}
+
+func (s *FanoutSink) Name() string { return "webhooks" }
+
+func (s *FanoutSink) Publish(ctx context.Context, e events.Event) error {
+	payload, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+
+	// ON CONFLICT membuat publish ulang event yang sama (at-least-once) tidak menggandakan delivery
+	_, err = s.DB.ExecContext(ctx,
+		`INSERT INTO webhook_deliveries (webhook_id, event_id, event_type, payload)
+		SELECT id, $1, $2, $3 FROM webhooks
+		WHERE active AND (events = '[]'::jsonb OR events @> jsonb_build_array($2::text))
+		ON CONFLICT (webhook_id, event_id) DO NOTHING`,
+		e.ID, e.Type, string(payload))
+	return err
+}
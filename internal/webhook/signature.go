@@ -0,0 +1,48 @@
+package webhook
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Header yang dikirim ke penerima webhook
+const (
+	HeaderSignature = "X-Webhook-Signature"
+	HeaderTimestamp = "X-Webhook-Timestamp"
+	HeaderEventID   = "X-Webhook-Event-ID"
+	HeaderEventType = "X-Webhook-Event-Type"
+	HeaderDelivery  = "X-Webhook-Delivery-ID"
+)
+
+// Sign menghitung signature HMAC-SHA256 dari "<timestamp>.<body>" dengan format "sha256=<hex>".
+// Timestamp ikut ditandatangani supaya payload lama tidak bisa di-replay.
+func Sign(secret string, timestamp int64, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(strconv.FormatInt(timestamp, 10)))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// Verify dipakai penerima untuk memeriksa signature dan umur timestamp (toleransi tolerance)
+func Verify(secret, signature, timestamp string, body []byte, tolerance time.Duration) error {
+	ts, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return fmt.Errorf("invalid timestamp")
+	}
+	if age := time.Since(time.Unix(ts, 0)); age > tolerance || age < -tolerance {
+		return fmt.Errorf("timestamp outside tolerance")
+	}
+	if !strings.HasPrefix(signature, "sha256=") {
+		return fmt.Errorf("unsupported signature scheme")
+	}
+	if !hmac.Equal([]byte(signature), []byte(Sign(secret, ts, body))) {
+		return fmt.Errorf("signature mismatch")
+	}
+	return nil
+}
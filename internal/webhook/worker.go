@@ -0,0 +1,283 @@
+package webhook
+
+import (
+	"betest/internal/config"
+	"betest/internal/outbox"
+	"bytes"
+	"cmp"
+	"context"
+	"database/sql"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"slices"
+	"strconv"
+	"time"
+)
+
+const (
+	StatusPending   = "pending"
+	StatusSucceeded = "succeeded"
+	StatusFailed    = "failed"
+
+	maxResponseBody = 1024            // Potongan body response yang disimpan di attempt untuk debugging
+	recordTimeout   = 5 * time.Second // Batas waktu menyimpan hasil attempt dan melepas lease
+)
+
+// Worker mengirim webhook_deliveries yang sudah jatuh tempo ke endpoint partner.
+// Delivery yang gagal di-retry dengan exponential backoff, dan endpoint yang gagal
+// berturut-turut sebanyak DisableAfter kali otomatis dinonaktifkan.
+//
+// Setiap batch di-claim dengan lease (locked_until) dalam satu statement pendek. Request HTTP
+// dikirim di luar transaksi, dan hasil setiap delivery disimpan di transaksi pendeknya sendiri,
+// jadi delivery yang sudah terkirim tidak ikut di-rollback jika delivery lain gagal disimpan.
+type Worker struct {
+	DB            *sql.DB
+	Client        *http.Client
+	PollInterval  time.Duration
+	BatchSize     int
+	MaxAttempts   int
+	DisableAfter  int
+	LeaseDuration time.Duration
+	Now           func() time.Time // Bisa diganti saat testing
+	Logger        *slog.Logger
+}
+
+func NewWorker(db *sql.DB, cfg config.WebhookConfig) *Worker {
+	return &Worker{
+		DB:            db,
+		Client:        &http.Client{Timeout: cfg.Timeout},
+		PollInterval:  cfg.PollInterval,
+		BatchSize:     cfg.BatchSize,
+		MaxAttempts:   cfg.MaxAttempts,
+		DisableAfter:  cfg.DisableAfter,
+		LeaseDuration: cfg.LeaseDuration,
+		Now:           time.Now,
+		Logger:        slog.Default().With("component", "webhook"),
+	}
+}
+
+// Run memproses delivery sampai ctx dibatalkan
+func (wk *Worker) Run(ctx context.Context) {
+	ticker := time.NewTicker(wk.PollInterval)
+	defer ticker.Stop()
+
+	for {
+		n, err := wk.ProcessBatch(ctx)
+		if err != nil && ctx.Err() == nil {
+			wk.Logger.Error("Webhook worker error", "error", err)
+		}
+		if n == wk.BatchSize && err == nil {
+			continue
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+type dueDelivery struct {
+	id        int64
+	webhookID int
+	url       string
+	secret    string
+	eventID   string
+	eventType string
+	payload   []byte
+	attempts  int
+}
+
+// ProcessBatch mengirim satu batch delivery yang jatuh tempo dan mengembalikan jumlah yang diproses
+func (wk *Worker) ProcessBatch(ctx context.Context) (int, error) {
+	batch, err := wk.claim(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	for i, d := range batch {
+		statusCode, respBody, duration, sendErr := wk.send(ctx, d)
+		if sendErr != nil && ctx.Err() != nil {
+			// Shutdown: delivery yang belum selesai dilepas supaya instance lain tidak perlu menunggu lease habis
+			wk.release(batch[i:])
+			return i, ctx.Err()
+		}
+
+		// Hasil disimpan walaupun ctx dibatalkan setelah request terkirim, supaya tidak dikirim ulang
+		recordCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), recordTimeout)
+		err := wk.record(recordCtx, d, statusCode, respBody, duration, sendErr)
+		cancel()
+		// Delivery yang hasilnya gagal disimpan tetap ter-lease dan di-retry setelah lease habis
+		if err != nil {
+			wk.release(batch[i+1:])
+			return i, err
+		}
+	}
+
+	return len(batch), nil
+}
+
+// claim mengambil delivery yang jatuh tempo dan memasang lease locked_until dalam satu statement,
+// jadi row lock hanya ditahan selama UPDATE. Delivery yang lease-nya habis (misalnya instance mati
+// di tengah batch) bisa di-claim ulang.
+func (wk *Worker) claim(ctx context.Context) ([]dueDelivery, error) {
+	rows, err := wk.DB.QueryContext(ctx,
+		`UPDATE webhook_deliveries d SET locked_until = NOW() + $3 * INTERVAL '1 millisecond'
+		FROM webhooks w
+		WHERE w.id = d.webhook_id AND d.id IN (
+			SELECT dd.id FROM webhook_deliveries dd
+			JOIN webhooks ww ON ww.id = dd.webhook_id
+			WHERE dd.status = $1 AND dd.next_attempt_at <= NOW() AND ww.active
+				AND (dd.locked_until IS NULL OR dd.locked_until <= NOW())
+			ORDER BY dd.id
+			LIMIT $2
+			FOR UPDATE OF dd SKIP LOCKED)
+		RETURNING d.id, d.webhook_id, w.url, w.secret, d.event_id, d.event_type, d.payload, d.attempts`,
+		StatusPending, wk.BatchSize, wk.LeaseDuration.Milliseconds())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var batch []dueDelivery
+	for rows.Next() {
+		var d dueDelivery
+		if err := rows.Scan(&d.id, &d.webhookID, &d.url, &d.secret, &d.eventID, &d.eventType, &d.payload, &d.attempts); err != nil {
+			return nil, err
+		}
+		batch = append(batch, d)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	// RETURNING tidak menjamin urutan
+	slices.SortFunc(batch, func(a, b dueDelivery) int { return cmp.Compare(a.id, b.id) })
+	return batch, nil
+}
+
+// release melepas lease delivery yang belum diproses
+func (wk *Worker) release(batch []dueDelivery) {
+	if len(batch) == 0 {
+		return
+	}
+	ids := make([]int64, len(batch))
+	for i, d := range batch {
+		ids[i] = d.id
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), recordTimeout)
+	defer cancel()
+	if _, err := wk.DB.ExecContext(ctx, "UPDATE webhook_deliveries SET locked_until = NULL WHERE id = ANY($1)", ids); err != nil {
+		wk.Logger.Warn("Error releasing webhook delivery lease", "error", err)
+	}
+}
+
+func (wk *Worker) send(ctx context.Context, d dueDelivery) (int, string, time.Duration, error) {
+	timestamp := wk.Now().Unix()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, d.url, bytes.NewReader(d.payload))
+	if err != nil {
+		return 0, "", 0, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "betest-webhooks/1.0")
+	req.Header.Set(HeaderTimestamp, strconv.FormatInt(timestamp, 10))
+	req.Header.Set(HeaderSignature, Sign(d.secret, timestamp, d.payload))
+	req.Header.Set(HeaderEventID, d.eventID)
+	req.Header.Set(HeaderEventType, d.eventType)
+	req.Header.Set(HeaderDelivery, strconv.FormatInt(d.id, 10))
+
+	start := time.Now()
+	resp, err := wk.Client.Do(req)
+	duration := time.Since(start)
+	if err != nil {
+		return 0, "", duration, err
+	}
+	defer resp.Body.Close()
+
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, maxResponseBody))
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return resp.StatusCode, string(body), duration, fmt.Errorf("endpoint responded with status %d", resp.StatusCode)
+	}
+	return resp.StatusCode, string(body), duration, nil
+}
+
+// record menyimpan hasil attempt dan memperbarui status delivery serta health webhook-nya dalam satu transaksi
+func (wk *Worker) record(ctx context.Context, d dueDelivery, statusCode int, respBody string, duration time.Duration, sendErr error) error {
+	tx, err := wk.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	attempts := d.attempts + 1
+
+	var statusCodeArg, errArg interface{}
+	if statusCode != 0 {
+		statusCodeArg = statusCode
+	}
+	if sendErr != nil {
+		errArg = sendErr.Error()
+	}
+
+	_, err = tx.ExecContext(ctx,
+		`INSERT INTO webhook_delivery_attempts (delivery_id, attempt, status_code, error, response_body, duration_ms)
+		VALUES ($1, $2, $3, $4, $5, $6)`,
+		d.id, attempts, statusCodeArg, errArg, respBody, duration.Milliseconds())
+	if err != nil {
+		return err
+	}
+
+	if sendErr == nil {
+		_, err = tx.ExecContext(ctx,
+			`UPDATE webhook_deliveries SET status = $2, attempts = $3, last_status_code = $4, last_error = NULL, delivered_at = NOW(), locked_until = NULL
+			WHERE id = $1`, d.id, StatusSucceeded, attempts, statusCodeArg)
+		if err != nil {
+			return err
+		}
+		if _, err = tx.ExecContext(ctx, "UPDATE webhooks SET consecutive_failures = 0 WHERE id = $1", d.webhookID); err != nil {
+			return err
+		}
+		return tx.Commit()
+	}
+
+	status := StatusPending
+	next := wk.Now().Add(outbox.Backoff(attempts))
+	if wk.MaxAttempts > 0 && attempts >= wk.MaxAttempts {
+		status = StatusFailed
+		wk.Logger.Error("Webhook delivery failed, giving up", "delivery_id", d.id, "webhook_id", d.webhookID, "attempts", attempts, "error", sendErr)
+	}
+
+	_, err = tx.ExecContext(ctx,
+		`UPDATE webhook_deliveries SET status = $2, attempts = $3, last_status_code = $4, last_error = $5, next_attempt_at = $6, locked_until = NULL
+		WHERE id = $1`, d.id, status, attempts, statusCodeArg, errArg, next)
+	if err != nil {
+		return err
+	}
+
+	// Nonaktifkan endpoint yang terus-menerus gagal. Lock baris webhook_deliveries di atas tidak
+	// melindungi baris webhooks; yang menyerialkan worker lain adalah UPDATE increment di bawah, yang
+	// mengunci baris webhooks sampai commit. Jadi hitungan yang dikembalikan RETURNING dan
+	// penonaktifannya konsisten walaupun beberapa delivery untuk webhook yang sama gagal bersamaan.
+	var failures int
+	err = tx.QueryRowContext(ctx,
+		"UPDATE webhooks SET consecutive_failures = consecutive_failures + 1 WHERE id = $1 RETURNING consecutive_failures",
+		d.webhookID).Scan(&failures)
+	if err != nil {
+		return err
+	}
+	if wk.DisableAfter > 0 && failures >= wk.DisableAfter {
+		res, err := tx.ExecContext(ctx, "UPDATE webhooks SET active = FALSE, disabled_at = NOW() WHERE id = $1 AND active", d.webhookID)
+		if err != nil {
+			return err
+		}
+		if n, _ := res.RowsAffected(); n > 0 {
+			wk.Logger.Warn("Webhook disabled after consecutive failures", "webhook_id", d.webhookID, "failures", failures)
+		}
+	}
+	return tx.Commit()
+}
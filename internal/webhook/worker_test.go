@@ -0,0 +1,199 @@
+package webhook
+
+import (
+	"betest/internal/outbox"
+	"context"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+const (
+	testSecret  = "whsec_test_secret_0123456789"
+	testPayload = `{"id":"6f1c2a7e-3b0e-4c55-9a57-0b6f7f0f2d11","type":"UserRegistered"}`
+	testEventID = "6f1c2a7e-3b0e-4c55-9a57-0b6f7f0f2d11"
+)
+
+var testNow = time.Date(2026, 1, 20, 9, 31, 9, 0, time.UTC)
+
+func newTestWorker(t *testing.T) (*Worker, sqlmock.Sqlmock) {
+	t.Helper()
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("sqlmock: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	wk := &Worker{
+		DB:            db,
+		Client:        &http.Client{Timeout: time.Second},
+		BatchSize:     10,
+		MaxAttempts:   5,
+		DisableAfter:  3,
+		LeaseDuration: time.Minute,
+		Now:           func() time.Time { return testNow },
+		Logger:        slog.New(slog.NewTextHandler(io.Discard, nil)),
+	}
+	return wk, mock
+}
+
+// expectClaim mengembalikan satu delivery ke url dengan jumlah attempt sebelumnya attempts
+func expectClaim(mock sqlmock.Sqlmock, url string, attempts int) {
+	mock.ExpectQuery(`UPDATE webhook_deliveries d SET locked_until`).
+		WithArgs(StatusPending, 10, int64(60000)).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "webhook_id", "url", "secret", "event_id", "event_type", "payload", "attempts"}).
+			AddRow(int64(42), 7, url, testSecret, testEventID, "UserRegistered", []byte(testPayload), attempts))
+}
+
+// expectFailure mengharapkan attempt gagal dengan status 500 dicatat dan consecutive_failures menjadi failures
+func expectFailure(mock sqlmock.Sqlmock, attempt int, status string, failures int) {
+	mock.ExpectBegin()
+	mock.ExpectExec(`INSERT INTO webhook_delivery_attempts`).
+		WithArgs(int64(42), attempt, 500, "endpoint responded with status 500", "boom", sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectExec(`UPDATE webhook_deliveries SET status = \$2, attempts = \$3, last_status_code = \$4, last_error = \$5, next_attempt_at = \$6`).
+		WithArgs(int64(42), status, attempt, 500, "endpoint responded with status 500", testNow.Add(outbox.Backoff(attempt))).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectQuery(`UPDATE webhooks SET consecutive_failures = consecutive_failures \+ 1`).
+		WithArgs(7).
+		WillReturnRows(sqlmock.NewRows([]string{"consecutive_failures"}).AddRow(failures))
+}
+
+func failingReceiver(t *testing.T, hits *atomic.Int32) *httptest.Server {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits.Add(1)
+		w.WriteHeader(http.StatusInternalServerError)
+		io.WriteString(w, "boom")
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func TestProcessBatchSendsSignedRequest(t *testing.T) {
+	wk, mock := newTestWorker(t)
+
+	var got *http.Request
+	var gotBody []byte
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = r
+		gotBody, _ = io.ReadAll(r.Body)
+		io.WriteString(w, "ok")
+	}))
+	defer srv.Close()
+
+	expectClaim(mock, srv.URL, 0)
+	mock.ExpectBegin()
+	mock.ExpectExec(`INSERT INTO webhook_delivery_attempts`).
+		WithArgs(int64(42), 1, 200, nil, "ok", sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectExec(`UPDATE webhook_deliveries SET status = \$2, attempts = \$3, last_status_code = \$4, last_error = NULL, delivered_at = NOW\(\)`).
+		WithArgs(int64(42), StatusSucceeded, 1, 200).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(`UPDATE webhooks SET consecutive_failures = 0`).
+		WithArgs(7).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	n, err := wk.ProcessBatch(context.Background())
+	if err != nil || n != 1 {
+		t.Fatalf("ProcessBatch = %d, %v; want 1, nil", n, err)
+	}
+	if got == nil {
+		t.Fatal("receiver was not called")
+	}
+
+	if string(gotBody) != testPayload {
+		t.Errorf("body = %s; want %s", gotBody, testPayload)
+	}
+	if ts := got.Header.Get(HeaderTimestamp); ts != strconv.FormatInt(testNow.Unix(), 10) {
+		t.Errorf("%s = %q; want %d", HeaderTimestamp, ts, testNow.Unix())
+	}
+	if sig := got.Header.Get(HeaderSignature); sig != Sign(testSecret, testNow.Unix(), []byte(testPayload)) {
+		t.Errorf("%s = %q does not match HMAC-SHA256 of timestamp and body", HeaderSignature, sig)
+	}
+	if got.Header.Get(HeaderEventID) != testEventID || got.Header.Get(HeaderEventType) != "UserRegistered" || got.Header.Get(HeaderDelivery) != "42" {
+		t.Errorf("event headers = %v", got.Header)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestProcessBatchRetriesWithBackoff(t *testing.T) {
+	tests := []struct {
+		name       string
+		attempts   int // attempt sebelumnya
+		wantStatus string
+	}{
+		{"first failure", 0, StatusPending},
+		{"third failure", 2, StatusPending},
+		{"max attempts reached", 4, StatusFailed},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			wk, mock := newTestWorker(t)
+			var hits atomic.Int32
+			srv := failingReceiver(t, &hits)
+
+			expectClaim(mock, srv.URL, tt.attempts)
+			expectFailure(mock, tt.attempts+1, tt.wantStatus, 1)
+			mock.ExpectCommit()
+
+			if _, err := wk.ProcessBatch(context.Background()); err != nil {
+				t.Fatalf("ProcessBatch: %v", err)
+			}
+			if hits.Load() != 1 {
+				t.Errorf("receiver hits = %d; want 1", hits.Load())
+			}
+			if err := mock.ExpectationsWereMet(); err != nil {
+				t.Error(err)
+			}
+		})
+	}
+}
+
+func TestBackoffIsExponential(t *testing.T) {
+	for attempts, want := range map[int]time.Duration{1: time.Second, 2: 2 * time.Second, 4: 8 * time.Second, 30: 10 * time.Minute} {
+		if got := outbox.Backoff(attempts); got != want {
+			t.Errorf("Backoff(%d) = %v; want %v", attempts, got, want)
+		}
+	}
+}
+
+func TestProcessBatchDisablesWebhookAfterFailures(t *testing.T) {
+	wk, mock := newTestWorker(t)
+	wk.MaxAttempts = 0
+	var hits atomic.Int32
+	srv := failingReceiver(t, &hits)
+
+	for failures := 1; failures <= wk.DisableAfter; failures++ {
+		expectClaim(mock, srv.URL, failures-1)
+		expectFailure(mock, failures, StatusPending, failures)
+		// Sebelum ambang batas tidak boleh ada UPDATE lain sebelum commit
+		if failures == wk.DisableAfter {
+			mock.ExpectExec(`UPDATE webhooks SET active = FALSE, disabled_at = NOW\(\)`).
+				WithArgs(7).
+				WillReturnResult(sqlmock.NewResult(0, 1))
+		}
+		mock.ExpectCommit()
+
+		if _, err := wk.ProcessBatch(context.Background()); err != nil {
+			t.Fatalf("ProcessBatch (failure %d): %v", failures, err)
+		}
+	}
+
+	if int(hits.Load()) != wk.DisableAfter {
+		t.Errorf("receiver hits = %d; want %d", hits.Load(), wk.DisableAfter)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
@@ -1,40 +1,176 @@
 package main
 
 import (
+	"betest/internal/cache"
+	"betest/internal/config"
+	"betest/internal/cookie"
 	"betest/internal/database"
 	"betest/internal/handlers"
+	"betest/internal/logger"
+	"betest/internal/metrics"
 	"betest/internal/middleware"
+	"betest/internal/oauth"
+	"betest/internal/outbox"
 	"betest/internal/routes"
+	"betest/internal/servertls"
+	"betest/internal/token"
+	"betest/internal/tracing"
+	"betest/internal/webhook"
 	"context"
-	"log"
+	"fmt"
+	"log/slog"
 	"net/http"
 	"os"
 	"os/signal"
+	"sync"
 	"syscall"
 	"time"
 )
 
 func main() {
+	cfg := config.Load()
+	logger.Init(cfg.Log)
+
+	// Tracing harus dipasang sebelum DB dan Redis dipakai
+	shutdownTracing, err := tracing.Init(context.Background(), cfg.Tracing)
+	if err != nil {
+		slog.Error("Error initializing tracing", "error", err)
+		os.Exit(1)
+	}
+
 	// Init DB
-	database.InitDB()
-	// kalau nanti ada CloseDB(), taruh di shutdown
+	database.InitDB(cfg.Database)
+	if err := handlers.InitRedis(cfg.Redis); err != nil {
+		slog.Error("Invalid redis config", "error", err)
+		os.Exit(1)
+	}
+
+	// Statistik pool database dan Redis untuk /metrics
+	metrics.RegisterDBPoolStats(database.Pool, "main")
+	for i, replica := range database.ReplicaPools {
+		metrics.RegisterDBPoolStats(replica, fmt.Sprintf("replica-%d", i+1))
+	}
+	metrics.RegisterRedisStats(handlers.Rdb, "main")
+
+	// Claim iss/aud dan clock skew untuk token yang diterbitkan dan divalidasi
+	token.Issuer = cfg.JWT.Issuer
+	token.Audience = cfg.JWT.Audience
+	token.Leeway = cfg.JWT.Leeway
+
+	// Key dan algoritma JWT; verifier hanya menerima algoritma yang dikonfigurasi
+	keys, err := token.NewKeys(cfg.JWT)
+	if err != nil {
+		slog.Error("Invalid JWT config", "error", err)
+		os.Exit(1)
+	}
+	handlers.TokenSigner = keys
+	handlers.TokenVerifier = keys
+
+	// Format access token (JWT atau opaque token di Redis), umur token dan batas sesi
+	if err := handlers.InitTokens(cfg.Token); err != nil {
+		slog.Error("Invalid token config", "error", err)
+		os.Exit(1)
+	}
 
-	// Set public key and Redis client for middleware
-	middleware.PublicKey = handlers.PublicKey
+	// Set token verifier and Redis client for middleware
+	middleware.TokenVerifier = keys
 	middleware.Rdb = handlers.Rdb
+	handlers.HealthCheckTimeout = cfg.Health.CheckTimeout
+	handlers.MaxBodyBytes = cfg.Server.MaxBodyBytes
+	handlers.ImportMaxBytes = cfg.Server.ImportMaxBytes
+
+	// API key service internal untuk /introspect dan /revoke
+	handlers.ServiceAPIKeys, err = oauth.NewAPIKeys(cfg.Introspection)
+	if err != nil {
+		slog.Error("Invalid introspection config", "error", err)
+		os.Exit(1)
+	}
+
+	// Atribut cookie refresh token dan CSRF
+	handlers.Cookies, err = cookie.NewPolicy(cfg.Cookie)
+	if err != nil {
+		slog.Error("Invalid cookie config", "error", err)
+		os.Exit(1)
+	}
+
+	// Cache read-through untuk GET /api/users dan GET /api/users/{id}
+	pingCtx, cancelPing := context.WithTimeout(context.Background(), cfg.Health.CheckTimeout)
+	cacheStore, err := cache.StoreFromConfig(pingCtx, cfg.Cache, handlers.Rdb)
+	cancelPing()
+	if err != nil {
+		slog.Error("Invalid cache config", "error", err)
+		os.Exit(1)
+	}
+	handlers.UserCache = cache.New(cacheStore, "user", cfg.Cache.UserTTL, cfg.Cache.Beta)
+	handlers.UserListCache = cache.New(cacheStore, "user_list", cfg.Cache.UserListTTL, cfg.Cache.Beta)
+	handlers.CacheReplicaLag = cfg.Cache.ReplicaLag
+
+	// Dispatcher outbox: publish domain event ke sink (log, Redis Stream, webhook)
+	sinks, err := outbox.SinksFromConfig(cfg.Outbox, handlers.Rdb)
+	if err != nil {
+		slog.Error("Invalid outbox config", "error", err)
+		os.Exit(1)
+	}
+	if cfg.Webhook.Enabled {
+		// Event juga di-fan-out menjadi delivery untuk setiap webhook partner yang berlangganan
+		sinks = append(sinks, &webhook.FanoutSink{DB: database.DB})
+	}
 
-	r := routes.SetupRoutes()
+	workerCtx, stopWorkers := context.WithCancel(context.Background())
+	var workers sync.WaitGroup
+	workers.Go(func() {
+		outbox.NewDispatcher(database.DB, sinks, cfg.Outbox).Run(workerCtx)
+	})
+	if cfg.Webhook.Enabled {
+		workers.Go(func() {
+			webhook.NewWorker(database.DB, cfg.Webhook).Run(workerCtx)
+		})
+	}
+
+	r := routes.SetupRoutes(cfg)
 
 	server := &http.Server{
-		Addr:    ":8080",
-		Handler: r,
+		Addr:              ":8080",
+		Handler:           r,
+		ReadHeaderTimeout: cfg.Server.ReadHeaderTimeout,
+		ReadTimeout:       cfg.Server.ReadTimeout,
+		WriteTimeout:      cfg.Server.WriteTimeout,
+		IdleTimeout:       cfg.Server.IdleTimeout,
+		MaxHeaderBytes:    cfg.Server.MaxHeaderBytes,
+	}
+
+	// HTTPS (dan mTLS) jika TLS_CERT_FILE dan TLS_KEY_FILE diisi.
+	// Certificate dicek ulang di background dan dipakai tanpa restart jika file berubah.
+	useTLS := servertls.Enabled(cfg.TLS)
+	if useTLS {
+		reloader, err := servertls.NewCertReloader(cfg.TLS.CertFile, cfg.TLS.KeyFile)
+		if err != nil {
+			slog.Error("Error loading TLS certificate", "error", err)
+			os.Exit(1)
+		}
+		server.TLSConfig, err = servertls.New(cfg.TLS, reloader)
+		if err != nil {
+			slog.Error("Invalid TLS config", "error", err)
+			os.Exit(1)
+		}
+		workers.Go(func() {
+			reloader.Run(workerCtx, cfg.TLS.ReloadInterval)
+		})
 	}
 
 	// Jalankan server di goroutine
 	go func() {
-		log.Println("Server starting on :8080")
-		if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
-			log.Fatalf("listen error: %v", err)
+		slog.Info("Server starting", "addr", server.Addr, "tls", useTLS)
+		var err error
+		if useTLS {
+			// Cert dan key sudah ada di TLSConfig.GetCertificate
+			err = server.ListenAndServeTLS("", "")
+		} else {
+			err = server.ListenAndServe()
+		}
+		if err != nil && err != http.ErrServerClosed {
+			slog.Error("Listen error", "error", err)
+			os.Exit(1)
 		}
 	}()
 
@@ -43,15 +179,34 @@ func main() {
 	signal.Notify(quit, os.Interrupt, syscall.SIGTERM)
 
 	<-quit
-	log.Println("Shutdown signal received...")
+	slog.Info("Shutdown signal received")
+
+	// Gagalkan /readyz dulu dan beri waktu load balancer berhenti mengirim traffic baru
+	handlers.SetShuttingDown()
+	slog.Info("Draining traffic", "delay", cfg.Health.DrainDelay.String())
+	time.Sleep(cfg.Health.DrainDelay)
 
 	// Timeout shutdown
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	ctx, cancel := context.WithTimeout(context.Background(), cfg.Server.ShutdownTimeout)
 	defer cancel()
 
 	if err := server.Shutdown(ctx); err != nil {
-		log.Fatalf("Server forced to shutdown: %v", err)
+		slog.Error("Server forced to shutdown", "error", err)
+		os.Exit(1)
+	}
+
+	// Hentikan worker background setelah request selesai,
+	// event dan webhook yang belum terkirim akan diproses saat start berikutnya
+	stopWorkers()
+	workers.Wait()
+
+	// Koneksi database ditutup paling akhir, setelah request dan worker tidak memakainya lagi
+	database.CloseDB()
+
+	// Kirim span yang masih tertahan di batcher
+	if err := shutdownTracing(ctx); err != nil {
+		slog.Error("Error shutting down tracing", "error", err)
 	}
 
-	log.Println("Server exited gracefully")
+	slog.Info("Server exited gracefully")
 }